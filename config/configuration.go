@@ -17,6 +17,9 @@
 package config
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/devicechain-io/dc-microservice/config"
 )
 
@@ -24,8 +27,174 @@ const (
 	KAFKA_TOPIC_PERSISTED_EVENTS = "persisted-events"
 )
 
+// Actions taken for events that fail a validation rule. An empty action disables the rule.
+const (
+	VALIDATION_ACCEPT = "accept" // Event is stored as-is
+	VALIDATION_REJECT = "reject" // Event (with all of its entries) is sent to the failed events topic
+	VALIDATION_FLAG   = "flag"   // Event is stored but marked as suspect
+	VALIDATION_DROP   = "drop"   // Event is discarded without notice
+)
+
+// Retention settings for event data. Each value is a postgres interval (e.g. "90 days")
+// after which data is dropped. Unset values keep data indefinitely. Retention, compression and
+// partitioning settings are applied to the database at startup, so changes to them take effect
+// once the service is restarted.
+type RetentionConfiguration struct {
+	Events             *string
+	Locations          *string
+	Measurements       *string
+	MeasurementsHourly *string
+	MeasurementsDaily  *string
+	Alerts             *string
+	Geofences          *string
+	Anomalies          *string
+}
+
+// Native compression settings for event hypertables. Chunks older than the compress-after
+// interval (e.g. "7 days") are compressed in the background.
+type CompressionConfiguration struct {
+	Enabled       bool
+	CompressAfter string
+}
+
+// Settings for tuning chunk intervals based on observed ingest rate. A zero target size
+// derives the target from database memory. Intervals are postgres interval literals.
+type ChunkTuningConfiguration struct {
+	Enabled          bool
+	TargetChunkBytes int64
+	MinInterval      string
+	MaxInterval      string
+	ScheduleInterval string
+}
+
+// Partitioning settings for event hypertables. Device partitions add a hash dimension on
+// device id (0 for time partitioning only); afterward the number of partitions may be changed.
+// The dimension can only be added to hypertables that do not hold chunks yet. Hypertables with
+// chunks cause startup to fail unless migrate existing is set, in which case they are rebuilt by
+// copying data in batches to a new partitioned hypertable (writes are blocked only while rows
+// written during the copy are reconciled). Migration needs disk space for an uncompressed copy
+// of the data.
+type PartitioningConfiguration struct {
+	DevicePartitions int32
+	MigrateExisting  bool
+	ChunkTuning      ChunkTuningConfiguration
+}
+
+// Settings for evaluating location events against geofences. Geofence definitions are
+// reloaded at the refresh interval (a duration such as "30s") so changes apply without restart.
+type GeofencingConfiguration struct {
+	Enabled         bool
+	RefreshInterval string
+}
+
+// Settings for splitting location streams into trips and stops. A device is moving at or above
+// the moving speed (meters per second) and stopped once it stays within the stop radius (meters)
+// for the stop duration (a duration such as "5m").
+type TripDetectionConfiguration struct {
+	Enabled      bool
+	MovingSpeed  float64
+	StopRadius   float64
+	StopDuration string
+}
+
+// Settings for checking measurements against registered definitions. Actions are taken for
+// measurements without a definition and for values that do not match the data type or valid
+// range. Definitions are reloaded at the refresh interval (a duration such as "30s").
+type MeasurementRegistryConfiguration struct {
+	Enabled         bool
+	Unknown         string
+	InvalidValues   string
+	RefreshInterval string
+}
+
+// Settings for evaluating measurements against threshold rules. Rules are reloaded at the
+// refresh interval (a duration such as "30s") so changes apply without restart.
+type ThresholdRuleConfiguration struct {
+	Enabled         bool
+	RefreshInterval string
+}
+
+// Settings for detecting anomalies in measurement series. Rolling statistics are updated with
+// the given smoothing factor (alpha between 0 and 1) and values beyond the z-score are anomalies
+// once a series has seen the warmup number of values. Statistics are saved at the snapshot
+// interval (a duration such as "5m").
+type AnomalyDetectionConfiguration struct {
+	Enabled          bool
+	Alpha            float64
+	ZScore           float64
+	Warmup           int64
+	SnapshotInterval string
+}
+
+// Settings for computing virtual measurements from measurements reported together. Virtual
+// measurements are reloaded at the refresh interval (a duration such as "30s").
+type VirtualMeasurementConfiguration struct {
+	Enabled         bool
+	RefreshInterval string
+}
+
+// Settings for attaching the last known device location to measurements reported without one.
+// Locations older than the max age (a duration such as "1h") are not used.
+type MeasurementLocationConfiguration struct {
+	Enabled bool
+	MaxAge  string
+}
+
+// Settings for tracking daily time devices spend inside areas and geofences. Time between
+// locations further apart than the max gap (a duration such as "1h") is not counted.
+type DwellConfiguration struct {
+	Enabled bool
+	MaxGap  string
+}
+
+// Rules applied to locations before they are stored. Each rule holds the action taken when it
+// fails. Jumps are implausible when reaching them from the previous location requires a speed
+// above the max speed (meters per second).
+type LocationValidationConfiguration struct {
+	Enabled         bool
+	OutOfRange      string
+	NotFinite       string
+	NullIsland      string
+	ImplausibleJump string
+	MaxSpeed        float64
+}
+
+// Settings for resolving place names and region codes for locations as they are stored. Boundaries
+// are GeoJSON files and places are CSV files on the local filesystem. Places are matched within
+// the place distance (meters).
+type GeocodingConfiguration struct {
+	Enabled       bool
+	Boundaries    []string
+	Places        []string
+	PlaceDistance float64
+}
+
+// Unit added to the built-in conversion table (or replacing a built-in unit with the same name).
+// Values are converted to the base unit of the quantity as value * factor + offset.
+type UnitConfiguration struct {
+	Quantity string
+	Unit     string
+	Aliases  []string
+	Factor   float64
+	Offset   float64
+}
+
 type EventManagementConfiguration struct {
-	TsdbConfiguration config.MicroserviceDatastoreConfiguration
+	TsdbConfiguration   config.MicroserviceDatastoreConfiguration
+	Retention           RetentionConfiguration
+	Compression         CompressionConfiguration
+	Partitioning        PartitioningConfiguration
+	Geofencing          GeofencingConfiguration
+	TripDetection       TripDetectionConfiguration
+	LocationValidation  LocationValidationConfiguration
+	Geocoding           GeocodingConfiguration
+	Dwell               DwellConfiguration
+	Measurements        MeasurementRegistryConfiguration
+	ThresholdRules      ThresholdRuleConfiguration
+	AnomalyDetection    AnomalyDetectionConfiguration
+	VirtualMeasurements VirtualMeasurementConfiguration
+	MeasurementLocation MeasurementLocationConfiguration
+	Units               []UnitConfiguration
 }
 
 // Creates the default device management configuration
@@ -34,5 +203,129 @@ func NewEventManagementConfiguration() *EventManagementConfiguration {
 		TsdbConfiguration: config.MicroserviceDatastoreConfiguration{
 			SqlDebug: true,
 		},
+		Compression: CompressionConfiguration{
+			Enabled:       true,
+			CompressAfter: "7 days",
+		},
+		Partitioning: PartitioningConfiguration{
+			ChunkTuning: ChunkTuningConfiguration{
+				Enabled:          true,
+				MinInterval:      "1 hour",
+				MaxInterval:      "7 days",
+				ScheduleInterval: "1 hour",
+			},
+		},
+		Geofencing: GeofencingConfiguration{
+			Enabled:         true,
+			RefreshInterval: "30s",
+		},
+		TripDetection: TripDetectionConfiguration{
+			Enabled:      true,
+			MovingSpeed:  1.5,
+			StopRadius:   50,
+			StopDuration: "5m",
+		},
+		LocationValidation: LocationValidationConfiguration{
+			Enabled:         true,
+			OutOfRange:      VALIDATION_REJECT,
+			NotFinite:       VALIDATION_REJECT,
+			NullIsland:      VALIDATION_FLAG,
+			ImplausibleJump: VALIDATION_FLAG,
+			MaxSpeed:        350,
+		},
+		Dwell: DwellConfiguration{
+			Enabled: true,
+			MaxGap:  "1h",
+		},
+		Measurements: MeasurementRegistryConfiguration{
+			Enabled:         true,
+			Unknown:         VALIDATION_ACCEPT,
+			InvalidValues:   VALIDATION_FLAG,
+			RefreshInterval: "30s",
+		},
+		ThresholdRules: ThresholdRuleConfiguration{
+			Enabled:         true,
+			RefreshInterval: "30s",
+		},
+		AnomalyDetection: AnomalyDetectionConfiguration{
+			Alpha:            0.05,
+			ZScore:           4,
+			Warmup:           30,
+			SnapshotInterval: "5m",
+		},
+		VirtualMeasurements: VirtualMeasurementConfiguration{
+			Enabled:         true,
+			RefreshInterval: "30s",
+		},
+		MeasurementLocation: MeasurementLocationConfiguration{
+			Enabled: true,
+			MaxAge:  "1h",
+		},
+	}
+}
+
+// Check that a validation action is known (empty disables the rule).
+func validateAction(setting string, action string) error {
+	switch action {
+	case "", VALIDATION_ACCEPT, VALIDATION_REJECT, VALIDATION_FLAG, VALIDATION_DROP:
+		return nil
+	}
+	return fmt.Errorf("unknown action '%s' for %s (expected %s, %s, %s or %s)", action, setting,
+		VALIDATION_ACCEPT, VALIDATION_REJECT, VALIDATION_FLAG, VALIDATION_DROP)
+}
+
+// Check that a duration is positive (empty uses the default).
+func validateDuration(setting string, value string) error {
+	if value == "" {
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration '%s' for %s: %v", value, setting, err)
+	}
+	if parsed <= 0 {
+		return fmt.Errorf("duration '%s' for %s must be positive", value, setting)
+	}
+	return nil
+}
+
+// Check settings that are not verified when the configuration is parsed.
+func (c *EventManagementConfiguration) Validate() error {
+	actions := []struct {
+		setting string
+		action  string
+	}{
+		{"locationValidation.outOfRange", c.LocationValidation.OutOfRange},
+		{"locationValidation.notFinite", c.LocationValidation.NotFinite},
+		{"locationValidation.nullIsland", c.LocationValidation.NullIsland},
+		{"locationValidation.implausibleJump", c.LocationValidation.ImplausibleJump},
+		{"measurements.unknown", c.Measurements.Unknown},
+		{"measurements.invalidValues", c.Measurements.InvalidValues},
+	}
+	for _, check := range actions {
+		err := validateAction(check.setting, check.action)
+		if err != nil {
+			return err
+		}
+	}
+	durations := []struct {
+		setting string
+		value   string
+	}{
+		{"geofencing.refreshInterval", c.Geofencing.RefreshInterval},
+		{"thresholdRules.refreshInterval", c.ThresholdRules.RefreshInterval},
+		{"virtualMeasurements.refreshInterval", c.VirtualMeasurements.RefreshInterval},
+		{"anomalyDetection.snapshotInterval", c.AnomalyDetection.SnapshotInterval},
+		{"tripDetection.stopDuration", c.TripDetection.StopDuration},
+		{"dwell.maxGap", c.Dwell.MaxGap},
+		{"measurementLocation.maxAge", c.MeasurementLocation.MaxAge},
+		{"measurements.refreshInterval", c.Measurements.RefreshInterval},
+	}
+	for _, check := range durations {
+		err := validateDuration(check.setting, check.value)
+		if err != nil {
+			return err
+		}
 	}
+	return nil
 }
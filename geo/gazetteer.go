@@ -0,0 +1,372 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package geo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DEFAULT_PLACE_DISTANCE = 25000.0 // Meters within which the nearest place is matched
+	METERS_PER_DEGREE      = 111195.0
+
+	MAX_PLACE_NAME_LENGTH   = 128 // Longest place name (in characters) that can be stored with a location
+	MAX_COUNTRY_CODE_LENGTH = 8   // Longest country code that can be stored with a location
+	MAX_REGION_CODE_LENGTH  = 16  // Longest region code that can be stored with a location
+)
+
+// Place name and region codes resolved for a point.
+type PlaceMatch struct {
+	Name        string
+	CountryCode string
+	RegionCode  string
+}
+
+// Check that the name and codes fit the columns they are stored in.
+func (m PlaceMatch) validate() error {
+	fields := []struct {
+		field string
+		value string
+		max   int
+	}{
+		{"name", m.Name, MAX_PLACE_NAME_LENGTH},
+		{"country code", m.CountryCode, MAX_COUNTRY_CODE_LENGTH},
+		{"region code", m.RegionCode, MAX_REGION_CODE_LENGTH},
+	}
+	for _, check := range fields {
+		if utf8.RuneCountInString(check.value) > check.max {
+			return fmt.Errorf("%s '%s' is longer than %d characters", check.field, check.value, check.max)
+		}
+	}
+	return nil
+}
+
+// Named boundary with codes of the region it describes. Each area is an outer ring followed by
+// any holes.
+type Boundary struct {
+	PlaceMatch
+	Areas [][]Polygon
+
+	south, west, north, east float64
+}
+
+// Named place at a point.
+type Place struct {
+	PlaceMatch
+	Point
+}
+
+// Indicates whether a point is within the boundary.
+func (b *Boundary) Contains(point Point) bool {
+	if point.Latitude < b.south || point.Latitude > b.north || point.Longitude < b.west || point.Longitude > b.east {
+		return false
+	}
+	for _, area := range b.Areas {
+		if len(area) == 0 || !area[0].Contains(point) {
+			continue
+		}
+		inHole := false
+		for _, hole := range area[1:] {
+			if hole.Contains(point) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// Compute bounding box of outer rings.
+func (b *Boundary) computeBounds() {
+	b.south, b.west, b.north, b.east = 90, 180, -90, -180
+	for _, area := range b.Areas {
+		if len(area) == 0 {
+			continue
+		}
+		for _, vertex := range area[0] {
+			b.south = math.Min(b.south, vertex.Latitude)
+			b.north = math.Max(b.north, vertex.Latitude)
+			b.west = math.Min(b.west, vertex.Longitude)
+			b.east = math.Max(b.east, vertex.Longitude)
+		}
+	}
+}
+
+// Area of the bounding box in square degrees (used to rank nested boundaries).
+func (b *Boundary) extent() float64 {
+	return (b.north - b.south) * (b.east - b.west)
+}
+
+// Resolves place names and region codes for points from boundaries and places loaded from local
+// files, so no network access is needed. A gazetteer is read-only once loaded and may be shared.
+type Gazetteer struct {
+	Boundaries    []*Boundary
+	Places        []Place
+	PlaceDistance float64
+
+	cells map[[2]int][]int
+}
+
+// Create an empty gazetteer matching places within the given distance in meters.
+func NewGazetteer(placeDistance float64) *Gazetteer {
+	if placeDistance <= 0 {
+		placeDistance = DEFAULT_PLACE_DISTANCE
+	}
+	return &Gazetteer{
+		Boundaries:    make([]*Boundary, 0),
+		Places:        make([]Place, 0),
+		PlaceDistance: placeDistance,
+		cells:         make(map[[2]int][]int),
+	}
+}
+
+// Get string value of a feature property (empty if missing).
+func stringProperty(properties map[string]interface{}, names ...string) string {
+	for _, name := range names {
+		if value, ok := properties[name]; ok && value != nil {
+			return fmt.Sprint(value)
+		}
+	}
+	return ""
+}
+
+// Convert GeoJSON positions (longitude first) into a polygon.
+func ringOf(positions [][]float64) (Polygon, error) {
+	ring := make(Polygon, 0, len(positions))
+	for _, position := range positions {
+		if len(position) < 2 {
+			return nil, fmt.Errorf("invalid position in boundary")
+		}
+		ring = append(ring, Point{Latitude: position[1], Longitude: position[0]})
+	}
+	return ring, nil
+}
+
+// Convert GeoJSON polygon rings into an area.
+func areaOf(rings [][][]float64) ([]Polygon, error) {
+	area := make([]Polygon, 0, len(rings))
+	for _, positions := range rings {
+		ring, err := ringOf(positions)
+		if err != nil {
+			return nil, err
+		}
+		area = append(area, ring)
+	}
+	return area, nil
+}
+
+// Load boundaries from a GeoJSON feature collection of polygons or multipolygons. Features are
+// named by the "name" property with codes taken from "country_code" (or "iso_a2") and
+// "region_code" (or "iso_3166_2"). Other geometry types are ignored. Names or codes too long to
+// be stored reject the whole file.
+func (g *Gazetteer) LoadBoundaries(reader io.Reader) error {
+	collection := struct {
+		Features []struct {
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}{}
+	err := json.NewDecoder(reader).Decode(&collection)
+	if err != nil {
+		return err
+	}
+	boundaries := make([]*Boundary, 0)
+	for index, feature := range collection.Features {
+		boundary := &Boundary{
+			PlaceMatch: PlaceMatch{
+				Name:        stringProperty(feature.Properties, "name"),
+				CountryCode: stringProperty(feature.Properties, "country_code", "iso_a2"),
+				RegionCode:  stringProperty(feature.Properties, "region_code", "iso_3166_2"),
+			},
+		}
+		if err := boundary.validate(); err != nil {
+			return fmt.Errorf("invalid boundary for feature %d: %w", index, err)
+		}
+		switch feature.Geometry.Type {
+		case "Polygon":
+			rings := make([][][]float64, 0)
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &rings); err != nil {
+				return err
+			}
+			area, err := areaOf(rings)
+			if err != nil {
+				return err
+			}
+			boundary.Areas = [][]Polygon{area}
+		case "MultiPolygon":
+			polygons := make([][][][]float64, 0)
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &polygons); err != nil {
+				return err
+			}
+			for _, rings := range polygons {
+				area, err := areaOf(rings)
+				if err != nil {
+					return err
+				}
+				boundary.Areas = append(boundary.Areas, area)
+			}
+		default:
+			continue
+		}
+		boundary.computeBounds()
+		boundaries = append(boundaries, boundary)
+	}
+	g.Boundaries = append(g.Boundaries, boundaries...)
+
+	// Most specific (smallest) boundaries are checked first.
+	sort.SliceStable(g.Boundaries, func(i, j int) bool {
+		return g.Boundaries[i].extent() < g.Boundaries[j].extent()
+	})
+	return nil
+}
+
+// Get index cell (one degree square) for a point.
+func cellOf(point Point) [2]int {
+	return [2]int{int(math.Floor(point.Latitude)), int(math.Floor(point.Longitude))}
+}
+
+// Load places from CSV with a header row. Columns "name", "latitude" and "longitude" are
+// required while "country_code" and "region_code" are optional. Names or codes too long to be
+// stored reject the whole file.
+func (g *Gazetteer) LoadPlaces(reader io.Reader) error {
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	columns := make(map[string]int)
+	for index, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = index
+	}
+	for _, required := range []string{"name", "latitude", "longitude"} {
+		if _, ok := columns[required]; !ok {
+			return fmt.Errorf("places are missing required column: %s", required)
+		}
+	}
+	value := func(record []string, column string) string {
+		if index, ok := columns[column]; ok && index < len(record) {
+			return strings.TrimSpace(record[index])
+		}
+		return ""
+	}
+	places := make([]Place, 0, len(records)-1)
+	for line, record := range records[1:] {
+		lat, err := strconv.ParseFloat(value(record, "latitude"), 64)
+		if err != nil {
+			return fmt.Errorf("invalid latitude for place on line %d: %w", line+2, err)
+		}
+		lon, err := strconv.ParseFloat(value(record, "longitude"), 64)
+		if err != nil {
+			return fmt.Errorf("invalid longitude for place on line %d: %w", line+2, err)
+		}
+		place := Place{
+			PlaceMatch: PlaceMatch{
+				Name:        value(record, "name"),
+				CountryCode: value(record, "country_code"),
+				RegionCode:  value(record, "region_code"),
+			},
+			Point: Point{Latitude: lat, Longitude: lon},
+		}
+		if err := place.validate(); err != nil {
+			return fmt.Errorf("invalid place on line %d: %w", line+2, err)
+		}
+		places = append(places, place)
+	}
+	for _, place := range places {
+		cell := cellOf(place.Point)
+		g.cells[cell] = append(g.cells[cell], len(g.Places))
+		g.Places = append(g.Places, place)
+	}
+	return nil
+}
+
+// Find the nearest place within the place distance of a point (nil if none).
+func (g *Gazetteer) nearestPlace(point Point) *Place {
+	// Search cells that may hold places within range.
+	dlat := int(math.Ceil(g.PlaceDistance / METERS_PER_DEGREE))
+	dlon := dlat
+	if cos := math.Cos(radians(point.Latitude)); cos > 0.01 {
+		dlon = int(math.Ceil(g.PlaceDistance / (METERS_PER_DEGREE * cos)))
+	}
+	if dlon > 180 {
+		dlon = 180
+	}
+	center := cellOf(point)
+	var nearest *Place
+	best := g.PlaceDistance
+	for lat := center[0] - dlat; lat <= center[0]+dlat; lat++ {
+		for lon := center[1] - dlon; lon <= center[1]+dlon; lon++ {
+			// Wrap cells across the antimeridian.
+			wrapped := ((lon+180)%360+360)%360 - 180
+			for _, index := range g.cells[[2]int{lat, wrapped}] {
+				if distance := Distance(point, g.Places[index].Point); distance <= best {
+					best = distance
+					nearest = &g.Places[index]
+				}
+			}
+		}
+	}
+	return nearest
+}
+
+// Resolve the place for a point (nil if nothing matches). The name comes from the nearest place
+// if one is in range and otherwise from the most specific boundary containing the point. Codes
+// are filled from the place and then from containing boundaries, most specific first.
+func (g *Gazetteer) Lookup(point Point) *PlaceMatch {
+	var match *PlaceMatch
+	if place := g.nearestPlace(point); place != nil {
+		found := place.PlaceMatch
+		match = &found
+	}
+	for _, boundary := range g.Boundaries {
+		if match != nil && match.CountryCode != "" && match.RegionCode != "" {
+			break
+		}
+		if !boundary.Contains(point) {
+			continue
+		}
+		if match == nil {
+			match = &PlaceMatch{}
+		}
+		if match.Name == "" {
+			match.Name = boundary.Name
+		}
+		if match.CountryCode == "" {
+			match.CountryCode = boundary.CountryCode
+		}
+		if match.RegionCode == "" {
+			match.RegionCode = boundary.RegionCode
+		}
+	}
+	return match
+}
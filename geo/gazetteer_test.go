@@ -0,0 +1,86 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package geo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testBoundaries = `{"type": "FeatureCollection", "features": [
+	{"type": "Feature", "properties": {"name": "Country", "iso_a2": "CC"},
+		"geometry": {"type": "Polygon", "coordinates": [[[0, 0], [10, 0], [10, 10], [0, 10], [0, 0]]]}},
+	{"type": "Feature", "properties": {"name": "Region", "region_code": "CC-R"},
+		"geometry": {"type": "MultiPolygon", "coordinates": [
+			[[[1, 1], [5, 1], [5, 5], [1, 5], [1, 1]], [[2, 2], [3, 2], [3, 3], [2, 3], [2, 2]]]]}},
+	{"type": "Feature", "properties": {"name": "Line"},
+		"geometry": {"type": "LineString", "coordinates": [[0, 0], [1, 1]]}}
+]}`
+
+const testPlaces = `name,latitude,longitude,country_code
+Town,4,4,CC
+Far Away,-40,179.95,FA
+`
+
+// Test resolving places from boundaries and nearby places.
+func TestGazetteerLookup(t *testing.T) {
+	gazetteer := NewGazetteer(0)
+	assert.Nil(t, gazetteer.LoadBoundaries(strings.NewReader(testBoundaries)))
+	assert.Nil(t, gazetteer.LoadPlaces(strings.NewReader(testPlaces)))
+	assert.Equal(t, 2, len(gazetteer.Boundaries))
+	assert.Equal(t, 2, len(gazetteer.Places))
+
+	// Nearby place provides the name while boundaries provide missing codes.
+	match := gazetteer.Lookup(Point{Latitude: 4.1, Longitude: 4.1})
+	assert.Equal(t, PlaceMatch{Name: "Town", CountryCode: "CC", RegionCode: "CC-R"}, *match)
+
+	// Most specific boundary is used without a nearby place.
+	match = gazetteer.Lookup(Point{Latitude: 1.5, Longitude: 1.5})
+	assert.Equal(t, PlaceMatch{Name: "Region", CountryCode: "CC", RegionCode: "CC-R"}, *match)
+
+	// Holes are excluded from boundaries.
+	match = gazetteer.Lookup(Point{Latitude: 2.5, Longitude: 2.5})
+	assert.Equal(t, PlaceMatch{Name: "Country", CountryCode: "CC"}, *match)
+
+	// Places across the antimeridian are found.
+	match = gazetteer.Lookup(Point{Latitude: -40, Longitude: -179.95})
+	assert.Equal(t, "Far Away", match.Name)
+
+	// Nothing matches far from all places and boundaries.
+	assert.Nil(t, gazetteer.Lookup(Point{Latitude: 50, Longitude: 50}))
+}
+
+// Test places and boundaries with missing columns or invalid values.
+func TestGazetteerInvalidPlaces(t *testing.T) {
+	gazetteer := NewGazetteer(0)
+	assert.NotNil(t, gazetteer.LoadPlaces(strings.NewReader("name,latitude\nTown,4\n")))
+	assert.NotNil(t, gazetteer.LoadPlaces(strings.NewReader("name,latitude,longitude\nTown,4,east\n")))
+
+	// Names and codes too long to be stored reject the whole file.
+	places := "name,latitude,longitude,country_code\nTown,4,5,US\nCity,6,7,UNITED-STATES\n"
+	assert.NotNil(t, gazetteer.LoadPlaces(strings.NewReader(places)))
+	long := strings.Repeat("x", MAX_PLACE_NAME_LENGTH+1)
+	assert.NotNil(t, gazetteer.LoadPlaces(strings.NewReader("name,latitude,longitude\n"+long+",4,5\n")))
+	assert.Empty(t, gazetteer.Places)
+
+	boundaries := `{"features": [{"properties": {"name": "Region", "region_code": "REGION-CODE-TOO-LONG"},
+		"geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}}]}`
+	assert.NotNil(t, gazetteer.LoadBoundaries(strings.NewReader(boundaries)))
+	assert.Empty(t, gazetteer.Boundaries)
+}
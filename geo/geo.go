@@ -0,0 +1,89 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package geo
+
+import (
+	"math"
+)
+
+const (
+	EARTH_RADIUS_METERS = 6371008.8 // Mean earth radius
+)
+
+// Point in decimal degrees (WGS 84).
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Shape that can be tested for containment of a point.
+type Shape interface {
+	Contains(point Point) bool
+}
+
+// Convert degrees to radians.
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// Great-circle distance between two points in meters (haversine formula).
+func Distance(from Point, to Point) float64 {
+	dlat := radians(to.Latitude - from.Latitude)
+	dlon := radians(to.Longitude - from.Longitude)
+	a := math.Sin(dlat/2)*math.Sin(dlat/2) +
+		math.Cos(radians(from.Latitude))*math.Cos(radians(to.Latitude))*math.Sin(dlon/2)*math.Sin(dlon/2)
+	return 2 * EARTH_RADIUS_METERS * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Initial bearing in degrees clockwise from north (0-360) when traveling between two points.
+func Bearing(from Point, to Point) float64 {
+	dlon := radians(to.Longitude - from.Longitude)
+	lat1, lat2 := radians(from.Latitude), radians(to.Latitude)
+	y := math.Sin(dlon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dlon)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+// Circle described by a center point and radius in meters.
+type Circle struct {
+	Center Point
+	Radius float64
+}
+
+// Indicates whether a point is within the circle.
+func (c Circle) Contains(point Point) bool {
+	return Distance(c.Center, point) <= c.Radius
+}
+
+// Polygon described by its vertices (ring does not need to be closed). Vertices are treated
+// as planar coordinates, which is accurate for fences that are small relative to the earth.
+type Polygon []Point
+
+// Indicates whether a point is within the polygon (ray casting).
+func (p Polygon) Contains(point Point) bool {
+	inside := false
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		vi, vj := p[i], p[j]
+		if (vi.Latitude > point.Latitude) != (vj.Latitude > point.Latitude) {
+			cross := (vj.Longitude-vi.Longitude)*(point.Latitude-vi.Latitude)/(vj.Latitude-vi.Latitude) + vi.Longitude
+			if point.Longitude < cross {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
@@ -0,0 +1,60 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test great-circle distance.
+func TestDistance(t *testing.T) {
+	atlanta := Point{Latitude: 33.7490, Longitude: -84.3880}
+	nyc := Point{Latitude: 40.7128, Longitude: -74.0060}
+	assert.InDelta(t, 1200000, Distance(atlanta, nyc), 10000)
+	assert.Equal(t, 0.0, Distance(atlanta, atlanta))
+}
+
+// Test initial bearing.
+func TestBearing(t *testing.T) {
+	origin := Point{Latitude: 0, Longitude: 0}
+	assert.InDelta(t, 0, Bearing(origin, Point{Latitude: 1, Longitude: 0}), 0.001)
+	assert.InDelta(t, 90, Bearing(origin, Point{Latitude: 0, Longitude: 1}), 0.001)
+	assert.InDelta(t, 180, Bearing(origin, Point{Latitude: -1, Longitude: 0}), 0.001)
+	assert.InDelta(t, 270, Bearing(origin, Point{Latitude: 0, Longitude: -1}), 0.001)
+}
+
+// Test circle containment.
+func TestCircleContains(t *testing.T) {
+	circle := Circle{Center: Point{Latitude: 33.7490, Longitude: -84.3880}, Radius: 500}
+	assert.True(t, circle.Contains(Point{Latitude: 33.7500, Longitude: -84.3880}))
+	assert.False(t, circle.Contains(Point{Latitude: 33.7600, Longitude: -84.3880}))
+}
+
+// Test polygon containment.
+func TestPolygonContains(t *testing.T) {
+	square := Polygon{{0, 0}, {0, 1}, {1, 1}, {1, 0}}
+	assert.True(t, square.Contains(Point{0.5, 0.5}))
+	assert.False(t, square.Contains(Point{1.5, 0.5}))
+	assert.False(t, square.Contains(Point{0.5, -0.5}))
+
+	// Concave polygon with a notch on the east side.
+	notched := Polygon{{0, 0}, {0, 2}, {1, 1}, {2, 2}, {2, 0}}
+	assert.True(t, notched.Contains(Point{0.2, 1}))
+	assert.False(t, notched.Contains(Point{1, 1.8}))
+}
@@ -0,0 +1,200 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package geo
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"math"
+	"time"
+)
+
+// Location in a device track.
+type TrackPoint struct {
+	Point
+	Elevation *float64
+	Time      time.Time
+}
+
+// Distance in meters from a point to the segment between two others, using an
+// equirectangular projection around the segment start.
+func segmentDistance(point Point, start Point, end Point) float64 {
+	scale := math.Cos(radians(start.Latitude))
+	project := func(p Point) (float64, float64) {
+		return radians(p.Longitude-start.Longitude) * scale * EARTH_RADIUS_METERS,
+			radians(p.Latitude-start.Latitude) * EARTH_RADIUS_METERS
+	}
+	px, py := project(point)
+	ex, ey := project(end)
+	length := ex*ex + ey*ey
+	if length == 0 {
+		return math.Hypot(px, py)
+	}
+	t := math.Max(0, math.Min(1, (px*ex+py*ey)/length))
+	return math.Hypot(px-t*ex, py-t*ey)
+}
+
+// Simplify a track with the Douglas-Peucker algorithm. Points deviating less than the
+// tolerance (in meters) from the simplified line are removed. End points are always kept.
+func Simplify(points []TrackPoint, tolerance float64) []TrackPoint {
+	if tolerance <= 0 || len(points) < 3 {
+		return points
+	}
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+
+	// Process ranges iteratively to avoid deep recursion on long tracks.
+	ranges := [][2]int{{0, len(points) - 1}}
+	for len(ranges) > 0 {
+		first, last := ranges[len(ranges)-1][0], ranges[len(ranges)-1][1]
+		ranges = ranges[:len(ranges)-1]
+
+		index, max := -1, tolerance
+		for i := first + 1; i < last; i++ {
+			distance := segmentDistance(points[i].Point, points[first].Point, points[last].Point)
+			if distance > max {
+				index, max = i, distance
+			}
+		}
+		if index > 0 {
+			keep[index] = true
+			ranges = append(ranges, [2]int{first, index}, [2]int{index, last})
+		}
+	}
+
+	simplified := make([]TrackPoint, 0)
+	for i, point := range points {
+		if keep[i] {
+			simplified = append(simplified, point)
+		}
+	}
+	return simplified
+}
+
+// GeoJSON geometry.
+type geoJsonGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// GeoJSON feature.
+type geoJsonFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJsonGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GeoJSON feature collection.
+type geoJsonFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJsonFeature `json:"features"`
+}
+
+// Get GeoJSON position (longitude first) for a track point.
+func geoJsonPosition(point TrackPoint) []float64 {
+	if point.Elevation != nil {
+		return []float64{point.Longitude, point.Latitude, *point.Elevation}
+	}
+	return []float64{point.Longitude, point.Latitude}
+}
+
+// Build a GeoJSON LineString feature for a track. Point times are included in the
+// coordTimes property.
+func trackLineFeature(name string, points []TrackPoint) geoJsonFeature {
+	coords := make([][]float64, 0, len(points))
+	times := make([]string, 0, len(points))
+	for _, point := range points {
+		coords = append(coords, geoJsonPosition(point))
+		times = append(times, point.Time.UTC().Format(time.RFC3339))
+	}
+	return geoJsonFeature{
+		Type:       "Feature",
+		Geometry:   geoJsonGeometry{Type: "LineString", Coordinates: coords},
+		Properties: map[string]interface{}{"name": name, "coordTimes": times},
+	}
+}
+
+// Encode a track as a GeoJSON LineString feature. A LineString needs at least two points, so
+// shorter tracks are encoded as an empty FeatureCollection.
+func TrackGeoJsonLineString(name string, points []TrackPoint) ([]byte, error) {
+	if len(points) < 2 {
+		return json.Marshal(geoJsonFeatureCollection{Type: "FeatureCollection", Features: []geoJsonFeature{}})
+	}
+	return json.Marshal(trackLineFeature(name, points))
+}
+
+// Encode a track as a GeoJSON FeatureCollection holding the LineString (if the track has at
+// least two points) followed by a Point feature for each location.
+func TrackGeoJsonFeatureCollection(name string, points []TrackPoint) ([]byte, error) {
+	features := make([]geoJsonFeature, 0, len(points)+1)
+	if len(points) >= 2 {
+		features = append(features, trackLineFeature(name, points))
+	}
+	for _, point := range points {
+		features = append(features, geoJsonFeature{
+			Type:       "Feature",
+			Geometry:   geoJsonGeometry{Type: "Point", Coordinates: geoJsonPosition(point)},
+			Properties: map[string]interface{}{"time": point.Time.UTC().Format(time.RFC3339)},
+		})
+	}
+	return json.Marshal(geoJsonFeatureCollection{Type: "FeatureCollection", Features: features})
+}
+
+// GPX track point.
+type gpxPoint struct {
+	Latitude  float64  `xml:"lat,attr"`
+	Longitude float64  `xml:"lon,attr"`
+	Elevation *float64 `xml:"ele,omitempty"`
+	Time      string   `xml:"time"`
+}
+
+// GPX document with a single track.
+type gpxDocument struct {
+	XMLName xml.Name `xml:"gpx"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Version string   `xml:"version,attr"`
+	Creator string   `xml:"creator,attr"`
+	Track   struct {
+		Name    string `xml:"name"`
+		Segment struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+// Encode a track as a GPX 1.1 document.
+func TrackGpx(name string, points []TrackPoint) ([]byte, error) {
+	doc := gpxDocument{
+		Xmlns:   "http://www.topografix.com/GPX/1/1",
+		Version: "1.1",
+		Creator: "devicechain",
+	}
+	doc.Track.Name = name
+	for _, point := range points {
+		doc.Track.Segment.Points = append(doc.Track.Segment.Points, gpxPoint{
+			Latitude:  point.Latitude,
+			Longitude: point.Longitude,
+			Elevation: point.Elevation,
+			Time:      point.Time.UTC().Format(time.RFC3339),
+		})
+	}
+	content, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), content...), nil
+}
@@ -0,0 +1,79 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package geo
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Build a track point at the given position.
+func trackPoint(lat float64, lon float64, offset int) TrackPoint {
+	start := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	return TrackPoint{
+		Point: Point{Latitude: lat, Longitude: lon},
+		Time:  start.Add(time.Duration(offset) * time.Minute),
+	}
+}
+
+// Test track simplification.
+func TestSimplify(t *testing.T) {
+	// Nearly straight line loses intermediate points.
+	line := []TrackPoint{trackPoint(0, 0, 0), trackPoint(0.00001, 0.001, 1), trackPoint(0, 0.002, 2)}
+	assert.Len(t, Simplify(line, 10), 2)
+
+	// Significant detours are kept.
+	detour := []TrackPoint{trackPoint(0, 0, 0), trackPoint(0.01, 0.001, 1), trackPoint(0, 0.002, 2)}
+	assert.Len(t, Simplify(detour, 10), 3)
+
+	// Zero tolerance leaves track untouched.
+	assert.Len(t, Simplify(line, 0), 3)
+}
+
+// Test GeoJSON and GPX encoding.
+func TestTrackEncoding(t *testing.T) {
+	ele := 738.0
+	points := []TrackPoint{trackPoint(33.749, -84.388, 0), trackPoint(33.75, -84.389, 1)}
+	points[0].Elevation = &ele
+
+	line, err := TrackGeoJsonLineString("device", points)
+	assert.Nil(t, err)
+	assert.Contains(t, string(line), `"coordinates":[[-84.388,33.749,738],[-84.389,33.75]]`)
+
+	features, err := TrackGeoJsonFeatureCollection("device", points)
+	assert.Nil(t, err)
+	assert.Contains(t, string(features), `"type":"FeatureCollection"`)
+	assert.Equal(t, 3, strings.Count(string(features), `"type":"Feature"`))
+
+	gpx, err := TrackGpx("device", points)
+	assert.Nil(t, err)
+	assert.Contains(t, string(gpx), `<trkpt lat="33.749" lon="-84.388">`)
+	assert.Contains(t, string(gpx), `<ele>738</ele>`)
+	assert.Contains(t, string(gpx), `<time>2022-06-01T00:01:00Z</time>`)
+
+	// Tracks too short for a LineString only hold points.
+	line, err = TrackGeoJsonLineString("device", points[:1])
+	assert.Nil(t, err)
+	assert.Equal(t, `{"type":"FeatureCollection","features":[]}`, string(line))
+	features, err = TrackGeoJsonFeatureCollection("device", points[:1])
+	assert.Nil(t, err)
+	assert.NotContains(t, string(features), "LineString")
+	assert.Equal(t, 1, strings.Count(string(features), `"type":"Feature"`))
+}
@@ -0,0 +1,45 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+
+	"github.com/devicechain-io/dc-microservice/rdb"
+)
+
+// ----------------------------------
+// Search results pagination resolver
+// ----------------------------------
+
+type SearchResultsPaginationResolver struct {
+	M rdb.SearchResultsPagination
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *SearchResultsPaginationResolver) PageStart() *int32 {
+	return &r.M.PageStart
+}
+
+func (r *SearchResultsPaginationResolver) PageEnd() *int32 {
+	return &r.M.PageEnd
+}
+
+func (r *SearchResultsPaginationResolver) TotalRecords() *int32 {
+	return &r.M.TotalRecords
+}
@@ -0,0 +1,163 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/geo"
+	"github.com/devicechain-io/dc-event-management/model"
+	gqlcore "github.com/devicechain-io/dc-microservice/graphql"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	TRACK_FORMAT_GEOJSON          = "GEOJSON"
+	TRACK_FORMAT_GEOJSON_FEATURES = "GEOJSON_FEATURES"
+	TRACK_FORMAT_GPX              = "GPX"
+
+	TRACK_EXPORT_PATH = "/graphql/tracks" // Served under the graphql path so that it is exposed (and secured) the same way
+)
+
+// Check that a track format is known.
+func validateTrackFormat(format string) error {
+	switch format {
+	case TRACK_FORMAT_GEOJSON, TRACK_FORMAT_GEOJSON_FEATURES, TRACK_FORMAT_GPX:
+		return nil
+	}
+	return fmt.Errorf("unknown track format: %s", format)
+}
+
+// Exported device track.
+type TrackExport struct {
+	Format      string
+	ContentType string
+	Extension   string
+	PointCount  int
+	Content     []byte
+}
+
+// Export locations for a device as a track document, optionally simplified with the given
+// tolerance in meters.
+func exportTrack(ctx context.Context, api *model.Api, deviceId uint, start time.Time, end time.Time,
+	format string, tolerance float64) (*TrackExport, error) {
+	if err := validateTrackFormat(format); err != nil {
+		return nil, err
+	}
+	points, err := api.DeviceTrack(ctx, deviceId, start, end)
+	if err != nil {
+		return nil, err
+	}
+	points = geo.Simplify(points, tolerance)
+
+	name := fmt.Sprintf("device-%d", deviceId)
+	export := &TrackExport{
+		Format:     format,
+		PointCount: len(points),
+	}
+	switch format {
+	case TRACK_FORMAT_GEOJSON:
+		export.ContentType, export.Extension = "application/geo+json", "geojson"
+		export.Content, err = geo.TrackGeoJsonLineString(name, points)
+	case TRACK_FORMAT_GEOJSON_FEATURES:
+		export.ContentType, export.Extension = "application/geo+json", "geojson"
+		export.Content, err = geo.TrackGeoJsonFeatureCollection(name, points)
+	case TRACK_FORMAT_GPX:
+		export.ContentType, export.Extension = "application/gpx+xml", "gpx"
+		export.Content, err = geo.TrackGpx(name, points)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// Http handler that exports device tracks as downloadable files. Expects device, start and
+// end (RFC3339) query parameters along with optional format and tolerance (meters). Requests
+// get the same context providers as graphql requests and the api is taken from the context.
+type TrackExportHandler struct {
+	ContextProviders map[gqlcore.ContextKey]interface{}
+}
+
+// Create a new track export handler.
+func NewTrackExportHandler(providers map[gqlcore.ContextKey]interface{}) *TrackExportHandler {
+	return &TrackExportHandler{
+		ContextProviders: providers,
+	}
+}
+
+func (h *TrackExportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for key, value := range h.ContextProviders {
+		r = r.WithContext(context.WithValue(r.Context(), key, value))
+	}
+	params := r.URL.Query()
+	deviceId, err := strconv.ParseUint(params.Get("device"), 0, 64)
+	if err != nil {
+		http.Error(w, "invalid device id", http.StatusBadRequest)
+		return
+	}
+	start, err := time.Parse(time.RFC3339, params.Get("start"))
+	if err != nil {
+		http.Error(w, "invalid start time", http.StatusBadRequest)
+		return
+	}
+	end, err := time.Parse(time.RFC3339, params.Get("end"))
+	if err != nil {
+		http.Error(w, "invalid end time", http.StatusBadRequest)
+		return
+	}
+	format := TRACK_FORMAT_GEOJSON
+	if params.Get("format") != "" {
+		format = strings.ToUpper(params.Get("format"))
+	}
+	if err := validateTrackFormat(format); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tolerance := 0.0
+	if params.Get("tolerance") != "" {
+		tolerance, err = strconv.ParseFloat(params.Get("tolerance"), 64)
+		if err != nil {
+			http.Error(w, "invalid tolerance", http.StatusBadRequest)
+			return
+		}
+	}
+
+	api, ok := r.Context().Value(gqlcore.ContextApiKey).(*model.Api)
+	if !ok {
+		http.Error(w, "unable to export device track", http.StatusInternalServerError)
+		return
+	}
+	export, err := exportTrack(r.Context(), api, uint(deviceId), start, end, format, tolerance)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to export device track.")
+		http.Error(w, "unable to export device track", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", export.ContentType)
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"device-%d.%s\"", deviceId, export.Extension))
+	_, err = w.Write(export.Content)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to write device track.")
+	}
+}
@@ -0,0 +1,46 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gqlcore "github.com/devicechain-io/dc-microservice/graphql"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test track export requests that fail before reaching the datastore.
+func TestTrackExportErrors(t *testing.T) {
+	handler := NewTrackExportHandler(map[gqlcore.ContextKey]interface{}{})
+	request := func(query string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, TRACK_EXPORT_PATH+"?"+query, nil))
+		return recorder
+	}
+	params := "device=1&start=2022-06-01T00:00:00Z&end=2022-06-02T00:00:00Z"
+
+	// Invalid parameters are reported as bad requests.
+	assert.Equal(t, http.StatusBadRequest, request("device=x").Code)
+	assert.Equal(t, http.StatusBadRequest, request(params+"&format=kml").Code)
+
+	// Other failures are reported without details.
+	recorder := request(params)
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, "unable to export device track\n", recorder.Body.String())
+}
@@ -0,0 +1,129 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	gql "github.com/graph-gophers/graphql-go"
+)
+
+// Data required to create a geofence as passed via graphql.
+type GeofenceCreateRequest struct {
+	Token       string
+	Name        *string
+	Description *string
+	AreaId      *gql.ID
+	Vertices    *[]GeoPoint
+	Center      *GeoPoint
+	Radius      *float64
+	Metadata    *string
+}
+
+// Convert graphql request into api request.
+func (r *SchemaResolver) asGeofenceCreateRequest(request GeofenceCreateRequest) (*model.GeofenceCreateRequest, error) {
+	result := &model.GeofenceCreateRequest{
+		Token:       request.Token,
+		Name:        request.Name,
+		Description: request.Description,
+		Radius:      request.Radius,
+		Metadata:    request.Metadata,
+		Vertices:    make([]model.GeoPoint, 0),
+	}
+	if request.AreaId != nil {
+		id, err := strconv.ParseUint(string(*request.AreaId), 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		areaId := uint(id)
+		result.AreaId = &areaId
+	}
+	if request.Vertices != nil {
+		for _, vertex := range *request.Vertices {
+			result.Vertices = append(result.Vertices, model.GeoPoint{Latitude: vertex.Latitude, Longitude: vertex.Longitude})
+		}
+	}
+	if request.Center != nil {
+		result.Center = &model.GeoPoint{Latitude: request.Center.Latitude, Longitude: request.Center.Longitude}
+	}
+	return result, nil
+}
+
+// Create a new geofence.
+func (r *SchemaResolver) CreateGeofence(ctx context.Context, args struct {
+	Request GeofenceCreateRequest
+}) (*GeofenceResolver, error) {
+	api := r.GetApi(ctx)
+	request, err := r.asGeofenceCreateRequest(args.Request)
+	if err != nil {
+		return nil, err
+	}
+	created, err := api.CreateGeofence(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	dt := &GeofenceResolver{
+		M: *created,
+		S: r,
+		C: ctx,
+	}
+	return dt, nil
+}
+
+// Update an existing geofence.
+func (r *SchemaResolver) UpdateGeofence(ctx context.Context, args struct {
+	Token   string
+	Request GeofenceCreateRequest
+}) (*GeofenceResolver, error) {
+	api := r.GetApi(ctx)
+	request, err := r.asGeofenceCreateRequest(args.Request)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := api.UpdateGeofence(ctx, args.Token, request)
+	if err != nil {
+		return nil, err
+	}
+
+	dt := &GeofenceResolver{
+		M: *updated,
+		S: r,
+		C: ctx,
+	}
+	return dt, nil
+}
+
+// Delete an existing geofence.
+func (r *SchemaResolver) DeleteGeofence(ctx context.Context, args struct {
+	Token string
+}) (*GeofenceResolver, error) {
+	api := r.GetApi(ctx)
+	deleted, err := api.DeleteGeofence(ctx, args.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	dt := &GeofenceResolver{
+		M: *deleted,
+		S: r,
+		C: ctx,
+	}
+	return dt, nil
+}
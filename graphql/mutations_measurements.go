@@ -0,0 +1,191 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+
+	"github.com/devicechain-io/dc-event-management/model"
+)
+
+// Data required to create a measurement definition as passed via graphql.
+type MeasurementDefinitionCreateRequest struct {
+	Token       string
+	Name        *string
+	Description *string
+	Unit        *string
+	DataType    string
+	MinValue    *float64
+	MaxValue    *float64
+	Precision   *int32
+	Metadata    *string
+}
+
+// Convert graphql request into api request.
+func (r *SchemaResolver) asMeasurementDefinitionCreateRequest(
+	request MeasurementDefinitionCreateRequest) *model.MeasurementDefinitionCreateRequest {
+	result := &model.MeasurementDefinitionCreateRequest{
+		Token:       request.Token,
+		Name:        request.Name,
+		Description: request.Description,
+		Unit:        request.Unit,
+		DataType:    request.DataType,
+		MinValue:    request.MinValue,
+		MaxValue:    request.MaxValue,
+		Metadata:    request.Metadata,
+	}
+	if request.Precision != nil {
+		precision := int64(*request.Precision)
+		result.Precision = &precision
+	}
+	return result
+}
+
+// Create a new measurement definition.
+func (r *SchemaResolver) CreateMeasurementDefinition(ctx context.Context, args struct {
+	Request MeasurementDefinitionCreateRequest
+}) (*MeasurementDefinitionResolver, error) {
+	api := r.GetApi(ctx)
+	created, err := api.CreateMeasurementDefinition(ctx, r.asMeasurementDefinitionCreateRequest(args.Request))
+	if err != nil {
+		return nil, err
+	}
+
+	dt := &MeasurementDefinitionResolver{
+		M: *created,
+		S: r,
+		C: ctx,
+	}
+	return dt, nil
+}
+
+// Update an existing measurement definition.
+func (r *SchemaResolver) UpdateMeasurementDefinition(ctx context.Context, args struct {
+	Token   string
+	Request MeasurementDefinitionCreateRequest
+}) (*MeasurementDefinitionResolver, error) {
+	api := r.GetApi(ctx)
+	updated, err := api.UpdateMeasurementDefinition(ctx, args.Token, r.asMeasurementDefinitionCreateRequest(args.Request))
+	if err != nil {
+		return nil, err
+	}
+
+	dt := &MeasurementDefinitionResolver{
+		M: *updated,
+		S: r,
+		C: ctx,
+	}
+	return dt, nil
+}
+
+// Delete an existing measurement definition.
+func (r *SchemaResolver) DeleteMeasurementDefinition(ctx context.Context, args struct {
+	Token string
+}) (*MeasurementDefinitionResolver, error) {
+	api := r.GetApi(ctx)
+	deleted, err := api.DeleteMeasurementDefinition(ctx, args.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	dt := &MeasurementDefinitionResolver{
+		M: *deleted,
+		S: r,
+		C: ctx,
+	}
+	return dt, nil
+}
+
+// Data required to create a virtual measurement as passed via graphql.
+type VirtualMeasurementCreateRequest struct {
+	Token       string
+	Name        *string
+	Description *string
+	Expression  string
+	Unit        *string
+	Enabled     bool
+	Metadata    *string
+}
+
+// Convert graphql request into api request.
+func (r *SchemaResolver) asVirtualMeasurementCreateRequest(
+	request VirtualMeasurementCreateRequest) *model.VirtualMeasurementCreateRequest {
+	return &model.VirtualMeasurementCreateRequest{
+		Token:       request.Token,
+		Name:        request.Name,
+		Description: request.Description,
+		Expression:  request.Expression,
+		Unit:        request.Unit,
+		Enabled:     request.Enabled,
+		Metadata:    request.Metadata,
+	}
+}
+
+// Create a new virtual measurement.
+func (r *SchemaResolver) CreateVirtualMeasurement(ctx context.Context, args struct {
+	Request VirtualMeasurementCreateRequest
+}) (*VirtualMeasurementResolver, error) {
+	api := r.GetApi(ctx)
+	created, err := api.CreateVirtualMeasurement(ctx, r.asVirtualMeasurementCreateRequest(args.Request))
+	if err != nil {
+		return nil, err
+	}
+
+	dt := &VirtualMeasurementResolver{
+		M: *created,
+		S: r,
+		C: ctx,
+	}
+	return dt, nil
+}
+
+// Update an existing virtual measurement.
+func (r *SchemaResolver) UpdateVirtualMeasurement(ctx context.Context, args struct {
+	Token   string
+	Request VirtualMeasurementCreateRequest
+}) (*VirtualMeasurementResolver, error) {
+	api := r.GetApi(ctx)
+	updated, err := api.UpdateVirtualMeasurement(ctx, args.Token, r.asVirtualMeasurementCreateRequest(args.Request))
+	if err != nil {
+		return nil, err
+	}
+
+	dt := &VirtualMeasurementResolver{
+		M: *updated,
+		S: r,
+		C: ctx,
+	}
+	return dt, nil
+}
+
+// Delete an existing virtual measurement.
+func (r *SchemaResolver) DeleteVirtualMeasurement(ctx context.Context, args struct {
+	Token string
+}) (*VirtualMeasurementResolver, error) {
+	api := r.GetApi(ctx)
+	deleted, err := api.DeleteVirtualMeasurement(ctx, args.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	dt := &VirtualMeasurementResolver{
+		M: *deleted,
+		S: r,
+		C: ctx,
+	}
+	return dt, nil
+}
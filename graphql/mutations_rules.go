@@ -0,0 +1,147 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	gql "github.com/graph-gophers/graphql-go"
+)
+
+// Data required to create a threshold rule as passed via graphql. Durations are strings such as "5m".
+type ThresholdRuleCreateRequest struct {
+	Token           string
+	Name            *string
+	Description     *string
+	Measurement     string
+	Operator        string
+	Threshold       float64
+	ClearThreshold  *float64
+	Unit            *string
+	Duration        *string
+	ClearDuration   *string
+	DeviceGroupId   *gql.ID
+	AssetGroupId    *gql.ID
+	CustomerGroupId *gql.ID
+	AreaGroupId     *gql.ID
+	AlertType       *string
+	AlertLevel      int32
+	Enabled         bool
+	Metadata        *string
+}
+
+// Convert graphql request into api request.
+func (r *SchemaResolver) asThresholdRuleCreateRequest(request ThresholdRuleCreateRequest) (*model.ThresholdRuleCreateRequest, error) {
+	result := &model.ThresholdRuleCreateRequest{
+		Token:          request.Token,
+		Name:           request.Name,
+		Description:    request.Description,
+		Measurement:    request.Measurement,
+		Operator:       request.Operator,
+		Threshold:      request.Threshold,
+		ClearThreshold: request.ClearThreshold,
+		Unit:           request.Unit,
+		AlertType:      request.AlertType,
+		AlertLevel:     uint32(request.AlertLevel),
+		Enabled:        request.Enabled,
+		Metadata:       request.Metadata,
+	}
+	var err error
+	if result.Duration, err = r.asDuration(request.Duration); err != nil {
+		return nil, err
+	}
+	if result.ClearDuration, err = r.asDuration(request.ClearDuration); err != nil {
+		return nil, err
+	}
+	if result.DeviceGroupId, err = r.asUintId(request.DeviceGroupId); err != nil {
+		return nil, err
+	}
+	if result.AssetGroupId, err = r.asUintId(request.AssetGroupId); err != nil {
+		return nil, err
+	}
+	if result.CustomerGroupId, err = r.asUintId(request.CustomerGroupId); err != nil {
+		return nil, err
+	}
+	if result.AreaGroupId, err = r.asUintId(request.AreaGroupId); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Create a new threshold rule.
+func (r *SchemaResolver) CreateThresholdRule(ctx context.Context, args struct {
+	Request ThresholdRuleCreateRequest
+}) (*ThresholdRuleResolver, error) {
+	api := r.GetApi(ctx)
+	request, err := r.asThresholdRuleCreateRequest(args.Request)
+	if err != nil {
+		return nil, err
+	}
+	created, err := api.CreateThresholdRule(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	dt := &ThresholdRuleResolver{
+		M: *created,
+		S: r,
+		C: ctx,
+	}
+	return dt, nil
+}
+
+// Update an existing threshold rule.
+func (r *SchemaResolver) UpdateThresholdRule(ctx context.Context, args struct {
+	Token   string
+	Request ThresholdRuleCreateRequest
+}) (*ThresholdRuleResolver, error) {
+	api := r.GetApi(ctx)
+	request, err := r.asThresholdRuleCreateRequest(args.Request)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := api.UpdateThresholdRule(ctx, args.Token, request)
+	if err != nil {
+		return nil, err
+	}
+
+	dt := &ThresholdRuleResolver{
+		M: *updated,
+		S: r,
+		C: ctx,
+	}
+	return dt, nil
+}
+
+// Delete an existing threshold rule.
+func (r *SchemaResolver) DeleteThresholdRule(ctx context.Context, args struct {
+	Token string
+}) (*ThresholdRuleResolver, error) {
+	api := r.GetApi(ctx)
+	deleted, err := api.DeleteThresholdRule(ctx, args.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	dt := &ThresholdRuleResolver{
+		M: *deleted,
+		S: r,
+		C: ctx,
+	}
+	return dt, nil
+}
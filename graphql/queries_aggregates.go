@@ -0,0 +1,269 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	gql "github.com/graph-gophers/graphql-go"
+)
+
+// Criteria for measurement aggregate queries as passed via graphql.
+type MeasurementAggregateCriteria struct {
+	DeviceIds *[]gql.ID
+	Names     *[]string
+	StartTime string
+	EndTime   string
+	Bucket    string
+	Unit      *string
+	Fill      *string
+}
+
+// Convert graphql criteria into api criteria.
+func (r *SchemaResolver) asMeasurementAggregateCriteria(criteria MeasurementAggregateCriteria) (*model.MeasurementAggregateCriteria, error) {
+	ids, err := r.asUintIds(criteria.DeviceIds)
+	if err != nil {
+		return nil, err
+	}
+	start, err := r.asTime(criteria.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	end, err := r.asTime(criteria.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := time.ParseDuration(criteria.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	fill := ""
+	if criteria.Fill != nil {
+		fill = *criteria.Fill
+	}
+	return &model.MeasurementAggregateCriteria{
+		DeviceIds: ids,
+		Names:     r.asStrings(criteria.Names),
+		StartTime: start,
+		EndTime:   end,
+		Bucket:    bucket,
+		Unit:      criteria.Unit,
+		Fill:      fill,
+	}, nil
+}
+
+// Aggregate measurements into time buckets.
+func (r *SchemaResolver) MeasurementAggregates(ctx context.Context, args struct {
+	Criteria MeasurementAggregateCriteria
+}) (*MeasurementAggregateResultsResolver, error) {
+	api := r.GetApi(ctx)
+	criteria, err := r.asMeasurementAggregateCriteria(args.Criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := api.MeasurementAggregates(ctx, *criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return as resolver.
+	return &MeasurementAggregateResultsResolver{
+		M: *found,
+		S: r,
+		C: ctx,
+	}, nil
+}
+
+// Histogram bins as passed via graphql.
+type HistogramSettings struct {
+	Min  float64
+	Max  float64
+	Bins int32
+}
+
+// Criteria for measurement distribution queries as passed via graphql.
+type MeasurementDistributionCriteria struct {
+	Name             string
+	StartTime        string
+	EndTime          string
+	Bucket           string
+	GroupBy          string
+	DeviceIds        *[]gql.ID
+	DeviceGroupIds   *[]gql.ID
+	AssetIds         *[]gql.ID
+	AssetGroupIds    *[]gql.ID
+	CustomerIds      *[]gql.ID
+	CustomerGroupIds *[]gql.ID
+	Percentiles      *[]float64
+	Histogram        *HistogramSettings
+	Unit             *string
+}
+
+// Convert graphql criteria into api criteria.
+func (r *SchemaResolver) asMeasurementDistributionCriteria(criteria MeasurementDistributionCriteria) (*model.MeasurementDistributionCriteria, error) {
+	result := &model.MeasurementDistributionCriteria{
+		Name:    criteria.Name,
+		GroupBy: criteria.GroupBy,
+		Unit:    criteria.Unit,
+	}
+	var err error
+	if result.StartTime, err = r.asTime(criteria.StartTime); err != nil {
+		return nil, err
+	}
+	if result.EndTime, err = r.asTime(criteria.EndTime); err != nil {
+		return nil, err
+	}
+	if result.Bucket, err = time.ParseDuration(criteria.Bucket); err != nil {
+		return nil, err
+	}
+	if result.DeviceIds, err = r.asUintIds(criteria.DeviceIds); err != nil {
+		return nil, err
+	}
+	if result.DeviceGroupIds, err = r.asUintIds(criteria.DeviceGroupIds); err != nil {
+		return nil, err
+	}
+	if result.AssetIds, err = r.asUintIds(criteria.AssetIds); err != nil {
+		return nil, err
+	}
+	if result.AssetGroupIds, err = r.asUintIds(criteria.AssetGroupIds); err != nil {
+		return nil, err
+	}
+	if result.CustomerIds, err = r.asUintIds(criteria.CustomerIds); err != nil {
+		return nil, err
+	}
+	if result.CustomerGroupIds, err = r.asUintIds(criteria.CustomerGroupIds); err != nil {
+		return nil, err
+	}
+	if criteria.Percentiles != nil {
+		result.Percentiles = *criteria.Percentiles
+	}
+	if criteria.Histogram != nil {
+		result.Histogram = &model.HistogramSettings{
+			Min:  criteria.Histogram.Min,
+			Max:  criteria.Histogram.Max,
+			Bins: criteria.Histogram.Bins,
+		}
+	}
+	return result, nil
+}
+
+// Compute percentiles and histograms of a measurement per time bucket.
+func (r *SchemaResolver) MeasurementDistributions(ctx context.Context, args struct {
+	Criteria MeasurementDistributionCriteria
+}) (*MeasurementDistributionResultsResolver, error) {
+	api := r.GetApi(ctx)
+	criteria, err := r.asMeasurementDistributionCriteria(args.Criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := api.MeasurementDistributions(ctx, *criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return as resolver.
+	return &MeasurementDistributionResultsResolver{
+		M: *found,
+		S: r,
+		C: ctx,
+	}, nil
+}
+
+// Settings for a Holt-Winters forecast as passed via graphql.
+type HoltWintersSettings struct {
+	Alpha        float64
+	Beta         float64
+	Gamma        *float64
+	SeasonLength *int32
+}
+
+// Criteria for measurement trend queries as passed via graphql.
+type MeasurementTrendCriteria struct {
+	DeviceId  gql.ID
+	Name      string
+	StartTime string
+	EndTime   string
+	Bucket    string
+	Horizon   *string
+	Threshold *float64
+	Forecast  *HoltWintersSettings
+}
+
+// Convert graphql criteria into api criteria.
+func (r *SchemaResolver) asMeasurementTrendCriteria(criteria MeasurementTrendCriteria) (*model.MeasurementTrendCriteria, error) {
+	result := &model.MeasurementTrendCriteria{
+		Name:      criteria.Name,
+		Threshold: criteria.Threshold,
+	}
+	deviceId, err := r.asUintId(&criteria.DeviceId)
+	if err != nil {
+		return nil, err
+	}
+	result.DeviceId = *deviceId
+	if result.StartTime, err = r.asTime(criteria.StartTime); err != nil {
+		return nil, err
+	}
+	if result.EndTime, err = r.asTime(criteria.EndTime); err != nil {
+		return nil, err
+	}
+	if result.Bucket, err = time.ParseDuration(criteria.Bucket); err != nil {
+		return nil, err
+	}
+	if result.Horizon, err = r.asDuration(criteria.Horizon); err != nil {
+		return nil, err
+	}
+	if criteria.Forecast != nil {
+		result.Forecast = &model.HoltWintersSettings{
+			Alpha: criteria.Forecast.Alpha,
+			Beta:  criteria.Forecast.Beta,
+		}
+		if criteria.Forecast.Gamma != nil {
+			result.Forecast.Gamma = *criteria.Forecast.Gamma
+		}
+		if criteria.Forecast.SeasonLength != nil {
+			result.Forecast.SeasonLength = *criteria.Forecast.SeasonLength
+		}
+	}
+	return result, nil
+}
+
+// Fit a trend (and optionally a forecast) to the measurement series of a device.
+func (r *SchemaResolver) MeasurementTrend(ctx context.Context, args struct {
+	Criteria MeasurementTrendCriteria
+}) (*MeasurementTrendResolver, error) {
+	api := r.GetApi(ctx)
+	criteria, err := r.asMeasurementTrendCriteria(args.Criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := api.MeasurementTrend(ctx, *criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return as resolver.
+	return &MeasurementTrendResolver{
+		M: *found,
+		S: r,
+		C: ctx,
+	}, nil
+}
@@ -0,0 +1,99 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	gql "github.com/graph-gophers/graphql-go"
+)
+
+const (
+	DAY_FORMAT = "2006-01-02"
+)
+
+// Criteria for dwell reports as passed via graphql.
+type AreaDwellCriteria struct {
+	StartDay    string
+	EndDay      string
+	GroupBy     string
+	Daily       *bool
+	AreaType    *string
+	AreaIds     *[]gql.ID
+	DeviceIds   *[]gql.ID
+	AssetIds    *[]gql.ID
+	CustomerIds *[]gql.ID
+}
+
+// Convert graphql criteria into api criteria.
+func (r *SchemaResolver) asAreaDwellCriteria(criteria AreaDwellCriteria) (*model.AreaDwellCriteria, error) {
+	start, err := time.Parse(DAY_FORMAT, criteria.StartDay)
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.Parse(DAY_FORMAT, criteria.EndDay)
+	if err != nil {
+		return nil, err
+	}
+	result := &model.AreaDwellCriteria{
+		StartDay: start,
+		EndDay:   end,
+		GroupBy:  criteria.GroupBy,
+		Daily:    criteria.Daily != nil && *criteria.Daily,
+		AreaType: criteria.AreaType,
+	}
+	if result.AreaIds, err = r.asUintIds(criteria.AreaIds); err != nil {
+		return nil, err
+	}
+	if result.DeviceIds, err = r.asUintIds(criteria.DeviceIds); err != nil {
+		return nil, err
+	}
+	if result.AssetIds, err = r.asUintIds(criteria.AssetIds); err != nil {
+		return nil, err
+	}
+	if result.CustomerIds, err = r.asUintIds(criteria.CustomerIds); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Report time spent by devices inside areas and geofences.
+func (r *SchemaResolver) AreaDwellReport(ctx context.Context, args struct {
+	Criteria AreaDwellCriteria
+}) ([]*AreaDwellTotalResolver, error) {
+	api := r.GetApi(ctx)
+	criteria, err := r.asAreaDwellCriteria(args.Criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := api.AreaDwellReport(ctx, *criteria)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*AreaDwellTotalResolver, 0)
+	for _, total := range found {
+		result = append(result, &AreaDwellTotalResolver{
+			M: total,
+			S: r,
+			C: ctx,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,142 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	gql "github.com/graph-gophers/graphql-go"
+)
+
+// Criteria for fleet snapshots as passed via graphql.
+type FleetCriteria struct {
+	DeviceIds        *[]gql.ID
+	DeviceGroupIds   *[]gql.ID
+	CustomerIds      *[]gql.ID
+	CustomerGroupIds *[]gql.ID
+	AreaIds          *[]gql.ID
+	AreaGroupIds     *[]gql.ID
+	MaxResults       *int32
+	MaxAge           *string
+}
+
+// Convert graphql criteria into api criteria and max age.
+func (r *SchemaResolver) asFleetCriteria(criteria *FleetCriteria) (*model.LocationSearchCriteria, time.Duration, error) {
+	if criteria == nil {
+		return &model.LocationSearchCriteria{}, 0, nil
+	}
+	var maxAge time.Duration
+	if criteria.MaxAge != nil {
+		parsed, err := time.ParseDuration(*criteria.MaxAge)
+		if err != nil {
+			return nil, 0, err
+		}
+		maxAge = parsed
+	}
+	result := &model.LocationSearchCriteria{}
+	var err error
+	if result.DeviceIds, err = r.asUintIds(criteria.DeviceIds); err != nil {
+		return nil, 0, err
+	}
+	if result.DeviceGroupIds, err = r.asUintIds(criteria.DeviceGroupIds); err != nil {
+		return nil, 0, err
+	}
+	if result.CustomerIds, err = r.asUintIds(criteria.CustomerIds); err != nil {
+		return nil, 0, err
+	}
+	if result.CustomerGroupIds, err = r.asUintIds(criteria.CustomerGroupIds); err != nil {
+		return nil, 0, err
+	}
+	if result.AreaIds, err = r.asUintIds(criteria.AreaIds); err != nil {
+		return nil, 0, err
+	}
+	if result.AreaGroupIds, err = r.asUintIds(criteria.AreaGroupIds); err != nil {
+		return nil, 0, err
+	}
+	if criteria.MaxResults != nil {
+		result.MaxResults = int(*criteria.MaxResults)
+	}
+	return result, maxAge, nil
+}
+
+// Get last known location of each device at a point in time.
+func (r *SchemaResolver) FleetSnapshot(ctx context.Context, args struct {
+	Time     string
+	Criteria *FleetCriteria
+}) (*FleetSnapshotResolver, error) {
+	api := r.GetApi(ctx)
+	at, err := r.asTime(args.Time)
+	if err != nil {
+		return nil, err
+	}
+	criteria, maxAge, err := r.asFleetCriteria(args.Criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := api.FleetSnapshot(ctx, at, maxAge, *criteria)
+	if err != nil {
+		return nil, err
+	}
+	return &FleetSnapshotResolver{
+		M: *found,
+		S: r,
+		C: ctx,
+	}, nil
+}
+
+// Get fleet snapshots at fixed intervals across a time window.
+func (r *SchemaResolver) FleetPlayback(ctx context.Context, args struct {
+	StartTime string
+	EndTime   string
+	Interval  string
+	Criteria  *FleetCriteria
+}) ([]*FleetSnapshotResolver, error) {
+	api := r.GetApi(ctx)
+	start, err := r.asTime(args.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	end, err := r.asTime(args.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := time.ParseDuration(args.Interval)
+	if err != nil {
+		return nil, err
+	}
+	criteria, maxAge, err := r.asFleetCriteria(args.Criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := api.FleetPlayback(ctx, start, end, interval, maxAge, *criteria)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*FleetSnapshotResolver, 0)
+	for _, snapshot := range found {
+		result = append(result, &FleetSnapshotResolver{
+			M: snapshot,
+			S: r,
+			C: ctx,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,245 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	gql "github.com/graph-gophers/graphql-go"
+)
+
+// Point as passed via graphql.
+type GeoPoint struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Bounding box as passed via graphql.
+type GeoBoundingBox struct {
+	South float64
+	West  float64
+	North float64
+	East  float64
+}
+
+// Criteria for location searches as passed via graphql.
+type LocationSearchCriteria struct {
+	StartTime        string
+	EndTime          string
+	DeviceIds        *[]gql.ID
+	CustomerIds      *[]gql.ID
+	CustomerGroupIds *[]gql.ID
+	AreaIds          *[]gql.ID
+	AreaGroupIds     *[]gql.ID
+	MaxResults       *int32
+	IncludeSuspect   *bool
+}
+
+// Convert graphql criteria into api criteria.
+func (r *SchemaResolver) asLocationSearchCriteria(criteria LocationSearchCriteria) (*model.LocationSearchCriteria, error) {
+	start, err := r.asTime(criteria.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	end, err := r.asTime(criteria.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	result := &model.LocationSearchCriteria{
+		StartTime: start,
+		EndTime:   end,
+	}
+	if result.DeviceIds, err = r.asUintIds(criteria.DeviceIds); err != nil {
+		return nil, err
+	}
+	if result.CustomerIds, err = r.asUintIds(criteria.CustomerIds); err != nil {
+		return nil, err
+	}
+	if result.CustomerGroupIds, err = r.asUintIds(criteria.CustomerGroupIds); err != nil {
+		return nil, err
+	}
+	if result.AreaIds, err = r.asUintIds(criteria.AreaIds); err != nil {
+		return nil, err
+	}
+	if result.AreaGroupIds, err = r.asUintIds(criteria.AreaGroupIds); err != nil {
+		return nil, err
+	}
+	if criteria.MaxResults != nil {
+		result.MaxResults = int(*criteria.MaxResults)
+	}
+	if criteria.IncludeSuspect != nil {
+		result.IncludeSuspect = *criteria.IncludeSuspect
+	}
+	return result, nil
+}
+
+// Convert located events into resolvers.
+func (r *SchemaResolver) asLocatedEventResolvers(ctx context.Context, found []model.LocatedEvent) []*LocatedEventResolver {
+	result := make([]*LocatedEventResolver, 0)
+	for _, event := range found {
+		result = append(result, &LocatedEventResolver{
+			M: event,
+			S: r,
+			C: ctx,
+		})
+	}
+	return result
+}
+
+// Find location events within a bounding box during a time range.
+func (r *SchemaResolver) LocationsInBoundingBox(ctx context.Context, args struct {
+	Box      GeoBoundingBox
+	Criteria LocationSearchCriteria
+}) ([]*LocatedEventResolver, error) {
+	api := r.GetApi(ctx)
+	criteria, err := r.asLocationSearchCriteria(args.Criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	box := model.GeoBoundingBox{
+		South: args.Box.South,
+		West:  args.Box.West,
+		North: args.Box.North,
+		East:  args.Box.East,
+	}
+	found, err := api.LocationsInBoundingBox(ctx, box, *criteria)
+	if err != nil {
+		return nil, err
+	}
+	return r.asLocatedEventResolvers(ctx, found), nil
+}
+
+// Find location events within a polygon during a time range.
+func (r *SchemaResolver) LocationsInPolygon(ctx context.Context, args struct {
+	Polygon  []GeoPoint
+	Criteria LocationSearchCriteria
+}) ([]*LocatedEventResolver, error) {
+	api := r.GetApi(ctx)
+	criteria, err := r.asLocationSearchCriteria(args.Criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	polygon := make([]model.GeoPoint, 0)
+	for _, point := range args.Polygon {
+		polygon = append(polygon, model.GeoPoint{Latitude: point.Latitude, Longitude: point.Longitude})
+	}
+	found, err := api.LocationsInPolygon(ctx, polygon, *criteria)
+	if err != nil {
+		return nil, err
+	}
+	return r.asLocatedEventResolvers(ctx, found), nil
+}
+
+// Find devices within a radius (in meters) of a point during a time range.
+func (r *SchemaResolver) DevicesNearPoint(ctx context.Context, args struct {
+	Point    GeoPoint
+	Radius   float64
+	Criteria LocationSearchCriteria
+}) ([]*DeviceProximityResolver, error) {
+	api := r.GetApi(ctx)
+	criteria, err := r.asLocationSearchCriteria(args.Criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	point := model.GeoPoint{Latitude: args.Point.Latitude, Longitude: args.Point.Longitude}
+	found, err := api.DevicesNearPoint(ctx, point, args.Radius, *criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*DeviceProximityResolver, 0)
+	for _, proximity := range found {
+		result = append(result, &DeviceProximityResolver{
+			M: proximity,
+			S: r,
+			C: ctx,
+		})
+	}
+	return result, nil
+}
+
+// Export locations for a device as a GeoJSON or GPX track.
+func (r *SchemaResolver) DeviceTrack(ctx context.Context, args struct {
+	DeviceId  gql.ID
+	StartTime string
+	EndTime   string
+	Format    string
+	Tolerance *float64
+}) (*TrackExportResolver, error) {
+	api := r.GetApi(ctx)
+	deviceId, err := strconv.ParseUint(string(args.DeviceId), 0, 64)
+	if err != nil {
+		return nil, err
+	}
+	start, err := r.asTime(args.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	end, err := r.asTime(args.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	tolerance := 0.0
+	if args.Tolerance != nil {
+		tolerance = *args.Tolerance
+	}
+
+	export, err := exportTrack(ctx, api, uint(deviceId), start, end, args.Format, tolerance)
+	if err != nil {
+		return nil, err
+	}
+	return &TrackExportResolver{
+		M: *export,
+		S: r,
+		C: ctx,
+	}, nil
+}
+
+// Aggregate location counts into geohash or H3 cells for a heatmap.
+func (r *SchemaResolver) LocationHeatmap(ctx context.Context, args struct {
+	Grid        string
+	Precision   int32
+	Measurement *string
+	Criteria    LocationSearchCriteria
+}) ([]*HeatmapCellResolver, error) {
+	api := r.GetApi(ctx)
+	criteria, err := r.asLocationSearchCriteria(args.Criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	grid := model.HeatmapGrid{Type: args.Grid, Precision: int(args.Precision)}
+	found, err := api.LocationHeatmap(ctx, grid, args.Measurement, *criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*HeatmapCellResolver, 0)
+	for _, cell := range found {
+		result = append(result, &HeatmapCellResolver{
+			M: cell,
+			S: r,
+			C: ctx,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,87 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	"github.com/devicechain-io/dc-microservice/rdb"
+	gql "github.com/graph-gophers/graphql-go"
+)
+
+// Criteria for geofence searches as passed via graphql.
+type GeofenceSearchCriteria struct {
+	PageNumber int32
+	PageSize   int32
+	AreaId     *gql.ID
+}
+
+// Find geofences by unique token.
+func (r *SchemaResolver) GeofencesByToken(ctx context.Context, args struct {
+	Tokens []string
+}) ([]*GeofenceResolver, error) {
+	api := r.GetApi(ctx)
+	found, err := api.GeofencesByToken(ctx, args.Tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*GeofenceResolver, 0)
+	for _, dt := range found {
+		dtr := &GeofenceResolver{
+			M: *dt,
+			S: r,
+			C: ctx,
+		}
+		result = append(result, dtr)
+	}
+	return result, nil
+}
+
+// List all geofences that match the given criteria.
+func (r *SchemaResolver) Geofences(ctx context.Context, args struct {
+	Criteria GeofenceSearchCriteria
+}) (*GeofenceSearchResultsResolver, error) {
+	api := r.GetApi(ctx)
+	criteria := model.GeofenceSearchCriteria{
+		Pagination: rdb.Pagination{
+			PageNumber: args.Criteria.PageNumber,
+			PageSize:   args.Criteria.PageSize,
+		},
+	}
+	if args.Criteria.AreaId != nil {
+		id, err := strconv.ParseUint(string(*args.Criteria.AreaId), 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		areaId := uint(id)
+		criteria.AreaId = &areaId
+	}
+
+	found, err := api.Geofences(ctx, criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GeofenceSearchResultsResolver{
+		M: *found,
+		S: r,
+		C: ctx,
+	}, nil
+}
@@ -0,0 +1,128 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	"github.com/devicechain-io/dc-microservice/rdb"
+)
+
+// Criteria for measurement definition searches as passed via graphql.
+type MeasurementDefinitionSearchCriteria struct {
+	PageNumber int32
+	PageSize   int32
+}
+
+// Find measurement definitions by unique token.
+func (r *SchemaResolver) MeasurementDefinitionsByToken(ctx context.Context, args struct {
+	Tokens []string
+}) ([]*MeasurementDefinitionResolver, error) {
+	api := r.GetApi(ctx)
+	found, err := api.MeasurementDefinitionsByToken(ctx, args.Tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*MeasurementDefinitionResolver, 0)
+	for _, dt := range found {
+		dtr := &MeasurementDefinitionResolver{
+			M: *dt,
+			S: r,
+			C: ctx,
+		}
+		result = append(result, dtr)
+	}
+	return result, nil
+}
+
+// List all measurement definitions that match the given criteria.
+func (r *SchemaResolver) MeasurementDefinitions(ctx context.Context, args struct {
+	Criteria MeasurementDefinitionSearchCriteria
+}) (*MeasurementDefinitionSearchResultsResolver, error) {
+	api := r.GetApi(ctx)
+	criteria := model.MeasurementDefinitionSearchCriteria{
+		Pagination: rdb.Pagination{
+			PageNumber: args.Criteria.PageNumber,
+			PageSize:   args.Criteria.PageSize,
+		},
+	}
+
+	found, err := api.MeasurementDefinitions(ctx, criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MeasurementDefinitionSearchResultsResolver{
+		M: *found,
+		S: r,
+		C: ctx,
+	}, nil
+}
+
+// Criteria for virtual measurement searches as passed via graphql.
+type VirtualMeasurementSearchCriteria struct {
+	PageNumber int32
+	PageSize   int32
+}
+
+// Find virtual measurements by unique token.
+func (r *SchemaResolver) VirtualMeasurementsByToken(ctx context.Context, args struct {
+	Tokens []string
+}) ([]*VirtualMeasurementResolver, error) {
+	api := r.GetApi(ctx)
+	found, err := api.VirtualMeasurementsByToken(ctx, args.Tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*VirtualMeasurementResolver, 0)
+	for _, dt := range found {
+		dtr := &VirtualMeasurementResolver{
+			M: *dt,
+			S: r,
+			C: ctx,
+		}
+		result = append(result, dtr)
+	}
+	return result, nil
+}
+
+// List all virtual measurements that match the given criteria.
+func (r *SchemaResolver) VirtualMeasurements(ctx context.Context, args struct {
+	Criteria VirtualMeasurementSearchCriteria
+}) (*VirtualMeasurementSearchResultsResolver, error) {
+	api := r.GetApi(ctx)
+	criteria := model.VirtualMeasurementSearchCriteria{
+		Pagination: rdb.Pagination{
+			PageNumber: args.Criteria.PageNumber,
+			PageSize:   args.Criteria.PageSize,
+		},
+	}
+
+	found, err := api.VirtualMeasurements(ctx, criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VirtualMeasurementSearchResultsResolver{
+		M: *found,
+		S: r,
+		C: ctx,
+	}, nil
+}
@@ -0,0 +1,61 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+)
+
+// List retention policies applied to event data.
+func (r *SchemaResolver) RetentionPolicies(ctx context.Context) ([]*RetentionPolicyResolver, error) {
+	api := r.GetApi(ctx)
+	found, err := api.RetentionPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*RetentionPolicyResolver, 0)
+	for _, policy := range found {
+		pr := &RetentionPolicyResolver{
+			M: policy,
+			S: r,
+			C: ctx,
+		}
+		result = append(result, pr)
+	}
+	return result, nil
+}
+
+// Get compression statistics for event hypertables.
+func (r *SchemaResolver) CompressionStats(ctx context.Context) ([]*CompressionStatsResolver, error) {
+	api := r.GetApi(ctx)
+	found, err := api.CompressionStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*CompressionStatsResolver, 0)
+	for _, stats := range found {
+		sr := &CompressionStatsResolver{
+			M: stats,
+			S: r,
+			C: ctx,
+		}
+		result = append(result, sr)
+	}
+	return result, nil
+}
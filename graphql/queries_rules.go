@@ -0,0 +1,78 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	"github.com/devicechain-io/dc-microservice/rdb"
+)
+
+// Criteria for threshold rule searches as passed via graphql.
+type ThresholdRuleSearchCriteria struct {
+	PageNumber  int32
+	PageSize    int32
+	Measurement *string
+}
+
+// Find threshold rules by unique token.
+func (r *SchemaResolver) ThresholdRulesByToken(ctx context.Context, args struct {
+	Tokens []string
+}) ([]*ThresholdRuleResolver, error) {
+	api := r.GetApi(ctx)
+	found, err := api.ThresholdRulesByToken(ctx, args.Tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*ThresholdRuleResolver, 0)
+	for _, dt := range found {
+		dtr := &ThresholdRuleResolver{
+			M: *dt,
+			S: r,
+			C: ctx,
+		}
+		result = append(result, dtr)
+	}
+	return result, nil
+}
+
+// List all threshold rules that match the given criteria.
+func (r *SchemaResolver) ThresholdRules(ctx context.Context, args struct {
+	Criteria ThresholdRuleSearchCriteria
+}) (*ThresholdRuleSearchResultsResolver, error) {
+	api := r.GetApi(ctx)
+	criteria := model.ThresholdRuleSearchCriteria{
+		Pagination: rdb.Pagination{
+			PageNumber: args.Criteria.PageNumber,
+			PageSize:   args.Criteria.PageSize,
+		},
+		Measurement: args.Criteria.Measurement,
+	}
+
+	found, err := api.ThresholdRules(ctx, criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ThresholdRuleSearchResultsResolver{
+		M: *found,
+		S: r,
+		C: ctx,
+	}, nil
+}
@@ -0,0 +1,109 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	"github.com/devicechain-io/dc-microservice/rdb"
+	gql "github.com/graph-gophers/graphql-go"
+)
+
+// Criteria for trip and stop searches as passed via graphql.
+type TripSearchCriteria struct {
+	PageNumber  int32
+	PageSize    int32
+	DeviceIds   *[]gql.ID
+	CustomerIds *[]gql.ID
+	StartTime   *string
+	EndTime     *string
+}
+
+// Convert graphql criteria into api criteria.
+func (r *SchemaResolver) asTripSearchCriteria(criteria TripSearchCriteria) (*model.TripSearchCriteria, error) {
+	result := &model.TripSearchCriteria{
+		Pagination: rdb.Pagination{
+			PageNumber: criteria.PageNumber,
+			PageSize:   criteria.PageSize,
+		},
+	}
+	var err error
+	if result.DeviceIds, err = r.asUintIds(criteria.DeviceIds); err != nil {
+		return nil, err
+	}
+	if result.CustomerIds, err = r.asUintIds(criteria.CustomerIds); err != nil {
+		return nil, err
+	}
+	if criteria.StartTime != nil {
+		start, err := r.asTime(*criteria.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		result.StartTime = &start
+	}
+	if criteria.EndTime != nil {
+		end, err := r.asTime(*criteria.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		result.EndTime = &end
+	}
+	return result, nil
+}
+
+// List trips that match the given criteria.
+func (r *SchemaResolver) Trips(ctx context.Context, args struct {
+	Criteria TripSearchCriteria
+}) (*TripSearchResultsResolver, error) {
+	api := r.GetApi(ctx)
+	criteria, err := r.asTripSearchCriteria(args.Criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := api.Trips(ctx, *criteria)
+	if err != nil {
+		return nil, err
+	}
+	return &TripSearchResultsResolver{
+		M: *found,
+		S: r,
+		C: ctx,
+	}, nil
+}
+
+// List stops that match the given criteria.
+func (r *SchemaResolver) Stops(ctx context.Context, args struct {
+	Criteria TripSearchCriteria
+}) (*StopSearchResultsResolver, error) {
+	api := r.GetApi(ctx)
+	criteria, err := r.asTripSearchCriteria(args.Criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := api.Stops(ctx, *criteria)
+	if err != nil {
+		return nil, err
+	}
+	return &StopSearchResultsResolver{
+		M: *found,
+		S: r,
+		C: ctx,
+	}, nil
+}
@@ -0,0 +1,269 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	util "github.com/devicechain-io/dc-microservice/graphql"
+	gql "github.com/graph-gophers/graphql-go"
+)
+
+// ------------------------------
+// Measurement aggregate resolver
+// ------------------------------
+
+type MeasurementAggregateResolver struct {
+	M model.MeasurementAggregate
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *MeasurementAggregateResolver) DeviceId() gql.ID {
+	return gql.ID(fmt.Sprint(r.M.DeviceId))
+}
+
+func (r *MeasurementAggregateResolver) Name() string {
+	return r.M.Name
+}
+
+func (r *MeasurementAggregateResolver) Bucket() *string {
+	return util.FormatTime(r.M.Bucket)
+}
+
+func (r *MeasurementAggregateResolver) Count() int32 {
+	return int32(r.M.Count)
+}
+
+func (r *MeasurementAggregateResolver) Min() *float64 {
+	return r.M.Min
+}
+
+func (r *MeasurementAggregateResolver) Max() *float64 {
+	return r.M.Max
+}
+
+func (r *MeasurementAggregateResolver) Avg() *float64 {
+	return r.M.Avg
+}
+
+func (r *MeasurementAggregateResolver) Sum() *float64 {
+	return r.M.Sum
+}
+
+// --------------------------------------
+// Measurement aggregate results resolver
+// --------------------------------------
+
+type MeasurementAggregateResultsResolver struct {
+	M model.MeasurementAggregateResults
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *MeasurementAggregateResultsResolver) Results() []*MeasurementAggregateResolver {
+	resolvers := make([]*MeasurementAggregateResolver, 0)
+	for _, current := range r.M.Results {
+		resolvers = append(resolvers,
+			&MeasurementAggregateResolver{
+				M: current,
+				S: r.S,
+				C: r.C,
+			})
+	}
+	return resolvers
+}
+
+func (r *MeasurementAggregateResultsResolver) Source() string {
+	return r.M.Source
+}
+
+func (r *MeasurementAggregateResultsResolver) Unit() *string {
+	return r.M.Unit
+}
+
+// ---------------------------------
+// Measurement distribution resolver
+// ---------------------------------
+
+type MeasurementDistributionResolver struct {
+	M model.MeasurementDistribution
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *MeasurementDistributionResolver) GroupId() *gql.ID {
+	return optionalId(r.M.GroupId)
+}
+
+func (r *MeasurementDistributionResolver) Bucket() *string {
+	return util.FormatTime(r.M.Bucket)
+}
+
+func (r *MeasurementDistributionResolver) Count() int32 {
+	return int32(r.M.Count)
+}
+
+func (r *MeasurementDistributionResolver) Percentiles() []float64 {
+	return r.M.Percentiles
+}
+
+func (r *MeasurementDistributionResolver) Histogram() *[]int32 {
+	if r.M.Histogram == nil {
+		return nil
+	}
+	counts := make([]int32, 0, len(r.M.Histogram))
+	for _, count := range r.M.Histogram {
+		counts = append(counts, int32(count))
+	}
+	return &counts
+}
+
+// -----------------------------------------
+// Measurement distribution results resolver
+// -----------------------------------------
+
+type MeasurementDistributionResultsResolver struct {
+	M model.MeasurementDistributionResults
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *MeasurementDistributionResultsResolver) Percentiles() []float64 {
+	return r.M.Percentiles
+}
+
+func (r *MeasurementDistributionResultsResolver) Unit() *string {
+	return r.M.Unit
+}
+
+func (r *MeasurementDistributionResultsResolver) Results() []*MeasurementDistributionResolver {
+	resolvers := make([]*MeasurementDistributionResolver, 0)
+	for _, current := range r.M.Results {
+		resolvers = append(resolvers,
+			&MeasurementDistributionResolver{
+				M: current,
+				S: r.S,
+				C: r.C,
+			})
+	}
+	return resolvers
+}
+
+// --------------------
+// Trend point resolver
+// --------------------
+
+type TrendPointResolver struct {
+	M model.TrendPoint
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *TrendPointResolver) Time() *string {
+	return util.FormatTime(r.M.Time)
+}
+
+func (r *TrendPointResolver) Value() float64 {
+	return r.M.Value
+}
+
+// --------------------------
+// Measurement trend resolver
+// --------------------------
+
+type MeasurementTrendResolver struct {
+	M model.MeasurementTrend
+	S *SchemaResolver
+	C context.Context
+}
+
+// Convert trend points to resolvers.
+func (r *MeasurementTrendResolver) points(points []model.TrendPoint) []*TrendPointResolver {
+	resolvers := make([]*TrendPointResolver, 0)
+	for _, current := range points {
+		resolvers = append(resolvers,
+			&TrendPointResolver{
+				M: current,
+				S: r.S,
+				C: r.C,
+			})
+	}
+	return resolvers
+}
+
+// Hours from the end time until a threshold time (nil if not reached).
+func (r *MeasurementTrendResolver) hoursUntil(crossed *time.Time) *float64 {
+	if crossed == nil {
+		return nil
+	}
+	hours := crossed.Sub(r.M.EndTime).Hours()
+	return &hours
+}
+
+func (r *MeasurementTrendResolver) Count() int32 {
+	return int32(r.M.Count)
+}
+
+func (r *MeasurementTrendResolver) Slope() float64 {
+	return r.M.Slope
+}
+
+func (r *MeasurementTrendResolver) EndValue() float64 {
+	return r.M.EndValue
+}
+
+func (r *MeasurementTrendResolver) RSquared() *float64 {
+	return r.M.RSquared
+}
+
+func (r *MeasurementTrendResolver) Projected() []*TrendPointResolver {
+	return r.points(r.M.Projected)
+}
+
+func (r *MeasurementTrendResolver) ThresholdTime() *string {
+	if r.M.ThresholdTime == nil {
+		return nil
+	}
+	return util.FormatTime(*r.M.ThresholdTime)
+}
+
+func (r *MeasurementTrendResolver) HoursToThreshold() *float64 {
+	return r.hoursUntil(r.M.ThresholdTime)
+}
+
+func (r *MeasurementTrendResolver) Forecast() *[]*TrendPointResolver {
+	if r.M.Forecast == nil {
+		return nil
+	}
+	points := r.points(r.M.Forecast)
+	return &points
+}
+
+func (r *MeasurementTrendResolver) ForecastThresholdTime() *string {
+	if r.M.ForecastThresholdTime == nil {
+		return nil
+	}
+	return util.FormatTime(*r.M.ForecastThresholdTime)
+}
+
+func (r *MeasurementTrendResolver) HoursToForecastThreshold() *float64 {
+	return r.hoursUntil(r.M.ForecastThresholdTime)
+}
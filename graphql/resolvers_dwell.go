@@ -0,0 +1,63 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	gql "github.com/graph-gophers/graphql-go"
+)
+
+// -------------------------
+// Area dwell total resolver
+// -------------------------
+
+type AreaDwellTotalResolver struct {
+	M model.AreaDwellTotal
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *AreaDwellTotalResolver) GroupId() *gql.ID {
+	return optionalId(r.M.GroupId)
+}
+
+func (r *AreaDwellTotalResolver) AreaType() string {
+	return r.M.AreaType
+}
+
+func (r *AreaDwellTotalResolver) AreaId() gql.ID {
+	return gql.ID(fmt.Sprint(r.M.AreaId))
+}
+
+func (r *AreaDwellTotalResolver) Day() *string {
+	if r.M.Day == nil {
+		return nil
+	}
+	day := r.M.Day.Format(DAY_FORMAT)
+	return &day
+}
+
+func (r *AreaDwellTotalResolver) Seconds() float64 {
+	return r.M.Seconds
+}
+
+func (r *AreaDwellTotalResolver) Devices() int32 {
+	return int32(r.M.Devices)
+}
@@ -0,0 +1,237 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	util "github.com/devicechain-io/dc-microservice/graphql"
+	gql "github.com/graph-gophers/graphql-go"
+)
+
+// Convert an optional id to a graphql id.
+func optionalId(id *uint) *gql.ID {
+	if id == nil {
+		return nil
+	}
+	gid := gql.ID(fmt.Sprint(*id))
+	return &gid
+}
+
+// ----------------------
+// Located event resolver
+// ----------------------
+
+type LocatedEventResolver struct {
+	M model.LocatedEvent
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *LocatedEventResolver) DeviceId() gql.ID {
+	return gql.ID(fmt.Sprint(r.M.DeviceId))
+}
+
+func (r *LocatedEventResolver) OccurredTime() *string {
+	return util.FormatTime(r.M.OccurredTime)
+}
+
+func (r *LocatedEventResolver) Source() string {
+	return r.M.Source
+}
+
+func (r *LocatedEventResolver) Latitude() float64 {
+	return r.M.Latitude
+}
+
+func (r *LocatedEventResolver) Longitude() float64 {
+	return r.M.Longitude
+}
+
+func (r *LocatedEventResolver) Elevation() *float64 {
+	return r.M.Elevation
+}
+
+func (r *LocatedEventResolver) Accuracy() *float64 {
+	return r.M.Accuracy
+}
+
+func (r *LocatedEventResolver) Speed() *float64 {
+	return r.M.Speed
+}
+
+func (r *LocatedEventResolver) Course() *float64 {
+	return r.M.Course
+}
+
+func (r *LocatedEventResolver) Satellites() *int32 {
+	return r.M.Satellites
+}
+
+func (r *LocatedEventResolver) FixType() *string {
+	return r.M.FixType
+}
+
+func (r *LocatedEventResolver) ComputedSpeed() *float64 {
+	return r.M.ComputedSpeed
+}
+
+func (r *LocatedEventResolver) ComputedHeading() *float64 {
+	return r.M.ComputedHeading
+}
+
+func (r *LocatedEventResolver) DistanceFromPrevious() *float64 {
+	return r.M.DistanceFromPrevious
+}
+
+func (r *LocatedEventResolver) SuspectReason() *string {
+	return r.M.SuspectReason
+}
+
+func (r *LocatedEventResolver) PlaceName() *string {
+	return r.M.PlaceName
+}
+
+func (r *LocatedEventResolver) CountryCode() *string {
+	return r.M.CountryCode
+}
+
+func (r *LocatedEventResolver) RegionCode() *string {
+	return r.M.RegionCode
+}
+
+func (r *LocatedEventResolver) RelCustomerId() *gql.ID {
+	return optionalId(r.M.RelCustomerId)
+}
+
+func (r *LocatedEventResolver) RelCustomerGroupId() *gql.ID {
+	return optionalId(r.M.RelCustomerGroupId)
+}
+
+func (r *LocatedEventResolver) RelAreaId() *gql.ID {
+	return optionalId(r.M.RelAreaId)
+}
+
+func (r *LocatedEventResolver) RelAreaGroupId() *gql.ID {
+	return optionalId(r.M.RelAreaGroupId)
+}
+
+func (r *LocatedEventResolver) RelAssetId() *gql.ID {
+	return optionalId(r.M.RelAssetId)
+}
+
+// -------------------------
+// Device proximity resolver
+// -------------------------
+
+type DeviceProximityResolver struct {
+	M model.DeviceProximity
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *DeviceProximityResolver) Location() *LocatedEventResolver {
+	return &LocatedEventResolver{
+		M: r.M.LocatedEvent,
+		S: r.S,
+		C: r.C,
+	}
+}
+
+func (r *DeviceProximityResolver) Distance() float64 {
+	return r.M.Distance
+}
+
+// -----------------------
+// Fleet snapshot resolver
+// -----------------------
+
+type FleetSnapshotResolver struct {
+	M model.FleetSnapshot
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *FleetSnapshotResolver) Time() *string {
+	return util.FormatTime(r.M.Time)
+}
+
+func (r *FleetSnapshotResolver) Locations() []*LocatedEventResolver {
+	return r.S.asLocatedEventResolvers(r.C, r.M.Locations)
+}
+
+// ---------------------
+// Heatmap cell resolver
+// ---------------------
+
+type HeatmapCellResolver struct {
+	M model.HeatmapCell
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *HeatmapCellResolver) Cell() string {
+	return r.M.Cell
+}
+
+func (r *HeatmapCellResolver) Latitude() float64 {
+	return r.M.Latitude
+}
+
+func (r *HeatmapCellResolver) Longitude() float64 {
+	return r.M.Longitude
+}
+
+func (r *HeatmapCellResolver) Count() int32 {
+	return int32(r.M.Count)
+}
+
+func (r *HeatmapCellResolver) MeasurementAverage() *float64 {
+	return r.M.MeasurementAverage
+}
+
+func (r *HeatmapCellResolver) MeasurementCount() int32 {
+	return int32(r.M.MeasurementCount)
+}
+
+// ---------------------
+// Track export resolver
+// ---------------------
+
+type TrackExportResolver struct {
+	M TrackExport
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *TrackExportResolver) Format() string {
+	return r.M.Format
+}
+
+func (r *TrackExportResolver) ContentType() string {
+	return r.M.ContentType
+}
+
+func (r *TrackExportResolver) PointCount() int32 {
+	return int32(r.M.PointCount)
+}
+
+func (r *TrackExportResolver) Content() string {
+	return string(r.M.Content)
+}
@@ -0,0 +1,148 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	util "github.com/devicechain-io/dc-microservice/graphql"
+	gql "github.com/graph-gophers/graphql-go"
+)
+
+// --------------
+// Point resolver
+// --------------
+
+type GeoPointResolver struct {
+	M model.GeoPoint
+}
+
+func (r *GeoPointResolver) Latitude() float64 {
+	return r.M.Latitude
+}
+
+func (r *GeoPointResolver) Longitude() float64 {
+	return r.M.Longitude
+}
+
+// -----------------
+// Geofence resolver
+// -----------------
+
+type GeofenceResolver struct {
+	M model.Geofence
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *GeofenceResolver) Id() gql.ID {
+	return gql.ID(fmt.Sprint(r.M.ID))
+}
+
+func (r *GeofenceResolver) CreatedAt() *string {
+	return util.FormatTime(r.M.CreatedAt)
+}
+
+func (r *GeofenceResolver) UpdatedAt() *string {
+	return util.FormatTime(r.M.UpdatedAt)
+}
+
+func (r *GeofenceResolver) DeletedAt() *string {
+	return util.FormatTime(r.M.DeletedAt.Time)
+}
+
+func (r *GeofenceResolver) Token() string {
+	return r.M.Token
+}
+
+func (r *GeofenceResolver) Name() *string {
+	return util.NullStr(r.M.Name)
+}
+
+func (r *GeofenceResolver) Description() *string {
+	return util.NullStr(r.M.Description)
+}
+
+func (r *GeofenceResolver) AreaId() *gql.ID {
+	return optionalId(r.M.AreaId)
+}
+
+func (r *GeofenceResolver) Vertices() ([]*GeoPointResolver, error) {
+	vertices, err := r.M.Polygon()
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*GeoPointResolver, 0)
+	for _, vertex := range vertices {
+		resolvers = append(resolvers, &GeoPointResolver{M: vertex})
+	}
+	return resolvers, nil
+}
+
+func (r *GeofenceResolver) Center() *GeoPointResolver {
+	if !r.M.CenterLatitude.Valid || !r.M.CenterLongitude.Valid {
+		return nil
+	}
+	return &GeoPointResolver{M: model.GeoPoint{
+		Latitude:  r.M.CenterLatitude.Float64,
+		Longitude: r.M.CenterLongitude.Float64,
+	}}
+}
+
+func (r *GeofenceResolver) Radius() *float64 {
+	if !r.M.Radius.Valid {
+		return nil
+	}
+	return &r.M.Radius.Float64
+}
+
+func (r *GeofenceResolver) Metadata() *string {
+	return util.MetadataStr(r.M.Metadata)
+}
+
+// --------------------------------
+// Geofence search results resolver
+// --------------------------------
+
+type GeofenceSearchResultsResolver struct {
+	M model.GeofenceSearchResults
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *GeofenceSearchResultsResolver) Results() []*GeofenceResolver {
+	resolvers := make([]*GeofenceResolver, 0)
+	for _, current := range r.M.Results {
+		resolvers = append(resolvers,
+			&GeofenceResolver{
+				M: current,
+				S: r.S,
+				C: r.C,
+			})
+	}
+	return resolvers
+}
+
+func (r *GeofenceSearchResultsResolver) Pagination() *SearchResultsPaginationResolver {
+	return &SearchResultsPaginationResolver{
+		M: r.M.Pagination,
+		S: r.S,
+		C: r.C,
+	}
+}
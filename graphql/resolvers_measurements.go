@@ -0,0 +1,214 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	util "github.com/devicechain-io/dc-microservice/graphql"
+	gql "github.com/graph-gophers/graphql-go"
+)
+
+// -------------------------------
+// Measurement definition resolver
+// -------------------------------
+
+type MeasurementDefinitionResolver struct {
+	M model.MeasurementDefinition
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *MeasurementDefinitionResolver) Id() gql.ID {
+	return gql.ID(fmt.Sprint(r.M.ID))
+}
+
+func (r *MeasurementDefinitionResolver) CreatedAt() *string {
+	return util.FormatTime(r.M.CreatedAt)
+}
+
+func (r *MeasurementDefinitionResolver) UpdatedAt() *string {
+	return util.FormatTime(r.M.UpdatedAt)
+}
+
+func (r *MeasurementDefinitionResolver) DeletedAt() *string {
+	return util.FormatTime(r.M.DeletedAt.Time)
+}
+
+func (r *MeasurementDefinitionResolver) Token() string {
+	return r.M.Token
+}
+
+func (r *MeasurementDefinitionResolver) Name() *string {
+	return util.NullStr(r.M.Name)
+}
+
+func (r *MeasurementDefinitionResolver) Description() *string {
+	return util.NullStr(r.M.Description)
+}
+
+func (r *MeasurementDefinitionResolver) Unit() *string {
+	return util.NullStr(r.M.Unit)
+}
+
+func (r *MeasurementDefinitionResolver) DataType() string {
+	return r.M.DataType
+}
+
+func (r *MeasurementDefinitionResolver) MinValue() *float64 {
+	if !r.M.MinValue.Valid {
+		return nil
+	}
+	return &r.M.MinValue.Float64
+}
+
+func (r *MeasurementDefinitionResolver) MaxValue() *float64 {
+	if !r.M.MaxValue.Valid {
+		return nil
+	}
+	return &r.M.MaxValue.Float64
+}
+
+func (r *MeasurementDefinitionResolver) Precision() *int32 {
+	if !r.M.Precision.Valid {
+		return nil
+	}
+	precision := int32(r.M.Precision.Int64)
+	return &precision
+}
+
+func (r *MeasurementDefinitionResolver) Metadata() *string {
+	return util.MetadataStr(r.M.Metadata)
+}
+
+// ----------------------------------------------
+// Measurement definition search results resolver
+// ----------------------------------------------
+
+type MeasurementDefinitionSearchResultsResolver struct {
+	M model.MeasurementDefinitionSearchResults
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *MeasurementDefinitionSearchResultsResolver) Results() []*MeasurementDefinitionResolver {
+	resolvers := make([]*MeasurementDefinitionResolver, 0)
+	for _, current := range r.M.Results {
+		resolvers = append(resolvers,
+			&MeasurementDefinitionResolver{
+				M: current,
+				S: r.S,
+				C: r.C,
+			})
+	}
+	return resolvers
+}
+
+func (r *MeasurementDefinitionSearchResultsResolver) Pagination() *SearchResultsPaginationResolver {
+	return &SearchResultsPaginationResolver{
+		M: r.M.Pagination,
+		S: r.S,
+		C: r.C,
+	}
+}
+
+// ----------------------------
+// Virtual measurement resolver
+// ----------------------------
+
+type VirtualMeasurementResolver struct {
+	M model.VirtualMeasurement
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *VirtualMeasurementResolver) Id() gql.ID {
+	return gql.ID(fmt.Sprint(r.M.ID))
+}
+
+func (r *VirtualMeasurementResolver) CreatedAt() *string {
+	return util.FormatTime(r.M.CreatedAt)
+}
+
+func (r *VirtualMeasurementResolver) UpdatedAt() *string {
+	return util.FormatTime(r.M.UpdatedAt)
+}
+
+func (r *VirtualMeasurementResolver) DeletedAt() *string {
+	return util.FormatTime(r.M.DeletedAt.Time)
+}
+
+func (r *VirtualMeasurementResolver) Token() string {
+	return r.M.Token
+}
+
+func (r *VirtualMeasurementResolver) Name() *string {
+	return util.NullStr(r.M.Name)
+}
+
+func (r *VirtualMeasurementResolver) Description() *string {
+	return util.NullStr(r.M.Description)
+}
+
+func (r *VirtualMeasurementResolver) Expression() string {
+	return r.M.Expression
+}
+
+func (r *VirtualMeasurementResolver) Unit() *string {
+	return util.NullStr(r.M.Unit)
+}
+
+func (r *VirtualMeasurementResolver) Enabled() bool {
+	return r.M.Enabled
+}
+
+func (r *VirtualMeasurementResolver) Metadata() *string {
+	return util.MetadataStr(r.M.Metadata)
+}
+
+// -------------------------------------------
+// Virtual measurement search results resolver
+// -------------------------------------------
+
+type VirtualMeasurementSearchResultsResolver struct {
+	M model.VirtualMeasurementSearchResults
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *VirtualMeasurementSearchResultsResolver) Results() []*VirtualMeasurementResolver {
+	resolvers := make([]*VirtualMeasurementResolver, 0)
+	for _, current := range r.M.Results {
+		resolvers = append(resolvers,
+			&VirtualMeasurementResolver{
+				M: current,
+				S: r.S,
+				C: r.C,
+			})
+	}
+	return resolvers
+}
+
+func (r *VirtualMeasurementSearchResultsResolver) Pagination() *SearchResultsPaginationResolver {
+	return &SearchResultsPaginationResolver{
+		M: r.M.Pagination,
+		S: r.S,
+		C: r.C,
+	}
+}
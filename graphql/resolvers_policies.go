@@ -0,0 +1,91 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	util "github.com/devicechain-io/dc-microservice/graphql"
+)
+
+// -------------------------
+// Retention policy resolver
+// -------------------------
+
+type RetentionPolicyResolver struct {
+	M model.HypertablePolicy
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *RetentionPolicyResolver) Hypertable() string {
+	return r.M.Hypertable
+}
+
+func (r *RetentionPolicyResolver) JobId() int32 {
+	return r.M.JobId
+}
+
+func (r *RetentionPolicyResolver) DropAfter() string {
+	return r.M.Interval
+}
+
+func (r *RetentionPolicyResolver) ScheduleInterval() string {
+	return r.M.ScheduleInterval
+}
+
+func (r *RetentionPolicyResolver) NextStart() *string {
+	if r.M.NextStart == nil {
+		return nil
+	}
+	return util.FormatTime(*r.M.NextStart)
+}
+
+// --------------------------
+// Compression stats resolver
+// --------------------------
+
+type CompressionStatsResolver struct {
+	M model.CompressionStats
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *CompressionStatsResolver) Hypertable() string {
+	return r.M.Hypertable
+}
+
+func (r *CompressionStatsResolver) TotalChunks() int32 {
+	return int32(r.M.TotalChunks)
+}
+
+func (r *CompressionStatsResolver) CompressedChunks() int32 {
+	return int32(r.M.CompressedChunks)
+}
+
+func (r *CompressionStatsResolver) BeforeBytes() float64 {
+	return float64(r.M.BeforeBytes)
+}
+
+func (r *CompressionStatsResolver) AfterBytes() float64 {
+	return float64(r.M.AfterBytes)
+}
+
+func (r *CompressionStatsResolver) Ratio() float64 {
+	return r.M.Ratio()
+}
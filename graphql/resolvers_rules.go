@@ -0,0 +1,159 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	util "github.com/devicechain-io/dc-microservice/graphql"
+	gql "github.com/graph-gophers/graphql-go"
+)
+
+// -----------------------
+// Threshold rule resolver
+// -----------------------
+
+type ThresholdRuleResolver struct {
+	M model.ThresholdRule
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *ThresholdRuleResolver) Id() gql.ID {
+	return gql.ID(fmt.Sprint(r.M.ID))
+}
+
+func (r *ThresholdRuleResolver) CreatedAt() *string {
+	return util.FormatTime(r.M.CreatedAt)
+}
+
+func (r *ThresholdRuleResolver) UpdatedAt() *string {
+	return util.FormatTime(r.M.UpdatedAt)
+}
+
+func (r *ThresholdRuleResolver) DeletedAt() *string {
+	return util.FormatTime(r.M.DeletedAt.Time)
+}
+
+func (r *ThresholdRuleResolver) Token() string {
+	return r.M.Token
+}
+
+func (r *ThresholdRuleResolver) Name() *string {
+	return util.NullStr(r.M.Name)
+}
+
+func (r *ThresholdRuleResolver) Description() *string {
+	return util.NullStr(r.M.Description)
+}
+
+func (r *ThresholdRuleResolver) Measurement() string {
+	return r.M.Measurement
+}
+
+func (r *ThresholdRuleResolver) Operator() string {
+	return r.M.Operator
+}
+
+func (r *ThresholdRuleResolver) Threshold() float64 {
+	return r.M.Threshold
+}
+
+func (r *ThresholdRuleResolver) ClearThreshold() *float64 {
+	if !r.M.ClearThreshold.Valid {
+		return nil
+	}
+	return &r.M.ClearThreshold.Float64
+}
+
+func (r *ThresholdRuleResolver) Unit() *string {
+	return util.NullStr(r.M.Unit)
+}
+
+func (r *ThresholdRuleResolver) Duration() string {
+	return (time.Duration(r.M.Duration) * time.Second).String()
+}
+
+func (r *ThresholdRuleResolver) ClearDuration() string {
+	return (time.Duration(r.M.ClearDuration) * time.Second).String()
+}
+
+func (r *ThresholdRuleResolver) DeviceGroupId() *gql.ID {
+	return optionalId(r.M.DeviceGroupId)
+}
+
+func (r *ThresholdRuleResolver) AssetGroupId() *gql.ID {
+	return optionalId(r.M.AssetGroupId)
+}
+
+func (r *ThresholdRuleResolver) CustomerGroupId() *gql.ID {
+	return optionalId(r.M.CustomerGroupId)
+}
+
+func (r *ThresholdRuleResolver) AreaGroupId() *gql.ID {
+	return optionalId(r.M.AreaGroupId)
+}
+
+func (r *ThresholdRuleResolver) AlertType() string {
+	return r.M.AlertType
+}
+
+func (r *ThresholdRuleResolver) AlertLevel() int32 {
+	return int32(r.M.AlertLevel)
+}
+
+func (r *ThresholdRuleResolver) Enabled() bool {
+	return r.M.Enabled
+}
+
+func (r *ThresholdRuleResolver) Metadata() *string {
+	return util.MetadataStr(r.M.Metadata)
+}
+
+// --------------------------------------
+// Threshold rule search results resolver
+// --------------------------------------
+
+type ThresholdRuleSearchResultsResolver struct {
+	M model.ThresholdRuleSearchResults
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *ThresholdRuleSearchResultsResolver) Results() []*ThresholdRuleResolver {
+	resolvers := make([]*ThresholdRuleResolver, 0)
+	for _, current := range r.M.Results {
+		resolvers = append(resolvers,
+			&ThresholdRuleResolver{
+				M: current,
+				S: r.S,
+				C: r.C,
+			})
+	}
+	return resolvers
+}
+
+func (r *ThresholdRuleSearchResultsResolver) Pagination() *SearchResultsPaginationResolver {
+	return &SearchResultsPaginationResolver{
+		M: r.M.Pagination,
+		S: r.S,
+		C: r.C,
+	}
+}
@@ -0,0 +1,196 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package graphql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	util "github.com/devicechain-io/dc-microservice/graphql"
+	gql "github.com/graph-gophers/graphql-go"
+)
+
+// -------------
+// Trip resolver
+// -------------
+
+type TripResolver struct {
+	M model.Trip
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *TripResolver) Id() gql.ID {
+	return gql.ID(fmt.Sprint(r.M.ID))
+}
+
+func (r *TripResolver) DeviceId() gql.ID {
+	return gql.ID(fmt.Sprint(r.M.DeviceId))
+}
+
+func (r *TripResolver) RelCustomerId() *gql.ID {
+	return optionalId(r.M.RelCustomerId)
+}
+
+func (r *TripResolver) RelAreaId() *gql.ID {
+	return optionalId(r.M.RelAreaId)
+}
+
+func (r *TripResolver) RelAssetId() *gql.ID {
+	return optionalId(r.M.RelAssetId)
+}
+
+func (r *TripResolver) StartTime() *string {
+	return util.FormatTime(r.M.StartTime)
+}
+
+func (r *TripResolver) EndTime() *string {
+	return util.FormatTime(r.M.EndTime)
+}
+
+func (r *TripResolver) StartPoint() *GeoPointResolver {
+	return &GeoPointResolver{M: model.GeoPoint{Latitude: r.M.StartLatitude, Longitude: r.M.StartLongitude}}
+}
+
+func (r *TripResolver) EndPoint() *GeoPointResolver {
+	return &GeoPointResolver{M: model.GeoPoint{Latitude: r.M.EndLatitude, Longitude: r.M.EndLongitude}}
+}
+
+func (r *TripResolver) Distance() float64 {
+	return r.M.Distance
+}
+
+func (r *TripResolver) Duration() float64 {
+	return r.M.Duration().Seconds()
+}
+
+func (r *TripResolver) Active() bool {
+	return r.M.Active
+}
+
+// -------------
+// Stop resolver
+// -------------
+
+type StopResolver struct {
+	M model.Stop
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *StopResolver) Id() gql.ID {
+	return gql.ID(fmt.Sprint(r.M.ID))
+}
+
+func (r *StopResolver) DeviceId() gql.ID {
+	return gql.ID(fmt.Sprint(r.M.DeviceId))
+}
+
+func (r *StopResolver) RelCustomerId() *gql.ID {
+	return optionalId(r.M.RelCustomerId)
+}
+
+func (r *StopResolver) RelAreaId() *gql.ID {
+	return optionalId(r.M.RelAreaId)
+}
+
+func (r *StopResolver) RelAssetId() *gql.ID {
+	return optionalId(r.M.RelAssetId)
+}
+
+func (r *StopResolver) StartTime() *string {
+	return util.FormatTime(r.M.StartTime)
+}
+
+func (r *StopResolver) EndTime() *string {
+	return util.FormatTime(r.M.EndTime)
+}
+
+func (r *StopResolver) Location() *GeoPointResolver {
+	return &GeoPointResolver{M: model.GeoPoint{Latitude: r.M.Latitude, Longitude: r.M.Longitude}}
+}
+
+func (r *StopResolver) Duration() float64 {
+	return r.M.Duration().Seconds()
+}
+
+func (r *StopResolver) Active() bool {
+	return r.M.Active
+}
+
+// ----------------------------
+// Trip search results resolver
+// ----------------------------
+
+type TripSearchResultsResolver struct {
+	M model.TripSearchResults
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *TripSearchResultsResolver) Results() []*TripResolver {
+	resolvers := make([]*TripResolver, 0)
+	for _, current := range r.M.Results {
+		resolvers = append(resolvers,
+			&TripResolver{
+				M: current,
+				S: r.S,
+				C: r.C,
+			})
+	}
+	return resolvers
+}
+
+func (r *TripSearchResultsResolver) Pagination() *SearchResultsPaginationResolver {
+	return &SearchResultsPaginationResolver{
+		M: r.M.Pagination,
+		S: r.S,
+		C: r.C,
+	}
+}
+
+// ----------------------------
+// Stop search results resolver
+// ----------------------------
+
+type StopSearchResultsResolver struct {
+	M model.StopSearchResults
+	S *SchemaResolver
+	C context.Context
+}
+
+func (r *StopSearchResultsResolver) Results() []*StopResolver {
+	resolvers := make([]*StopResolver, 0)
+	for _, current := range r.M.Results {
+		resolvers = append(resolvers,
+			&StopResolver{
+				M: current,
+				S: r.S,
+				C: r.C,
+			})
+	}
+	return resolvers
+}
+
+func (r *StopSearchResultsResolver) Pagination() *SearchResultsPaginationResolver {
+	return &SearchResultsPaginationResolver{
+		M: r.M.Pagination,
+		S: r.S,
+		C: r.C,
+	}
+}
@@ -19,9 +19,13 @@ package graphql
 import (
 	"context"
 	_ "embed"
+	"strconv"
+	"time"
 
+	"github.com/devicechain-io/dc-event-management/model"
 	gqlcore "github.com/devicechain-io/dc-microservice/graphql"
 	"github.com/devicechain-io/dc-microservice/rdb"
+	gql "github.com/graph-gophers/graphql-go"
 )
 
 //go:embed schema.graphql
@@ -33,3 +37,58 @@ type SchemaResolver struct{}
 func (s *SchemaResolver) GetRdbManager(ctx context.Context) *rdb.RdbManager {
 	return ctx.Value(gqlcore.ContextRdbKey).(*rdb.RdbManager)
 }
+
+// Get api from context.
+func (s *SchemaResolver) GetApi(ctx context.Context) *model.Api {
+	return ctx.Value(gqlcore.ContextApiKey).(*model.Api)
+}
+
+// Convert graphql ids to uint ids.
+func (r *SchemaResolver) asUintIds(val *[]gql.ID) ([]uint, error) {
+	ids := make([]uint, 0)
+	if val == nil {
+		return ids, nil
+	}
+	for _, sid := range *val {
+		id, err := strconv.ParseUint(string(sid), 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// Convert an optional graphql id to a uint id.
+func (r *SchemaResolver) asUintId(val *gql.ID) (*uint, error) {
+	if val == nil {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(string(*val), 0, 64)
+	if err != nil {
+		return nil, err
+	}
+	uid := uint(id)
+	return &uid, nil
+}
+
+// Parse an RFC3339 timestamp.
+func (r *SchemaResolver) asTime(val string) (time.Time, error) {
+	return time.Parse(time.RFC3339, val)
+}
+
+// Parse an optional duration (zero if not set).
+func (r *SchemaResolver) asDuration(val *string) (time.Duration, error) {
+	if val == nil || *val == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(*val)
+}
+
+// Convert optional string list to a slice.
+func (r *SchemaResolver) asStrings(val *[]string) []string {
+	if val == nil {
+		return []string{}
+	}
+	return *val
+}
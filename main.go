@@ -19,6 +19,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"net/http"
 
 	gql "github.com/graph-gophers/graphql-go"
 
@@ -79,10 +80,77 @@ func parseConfiguration() error {
 	if err != nil {
 		return err
 	}
+	err = config.Validate()
+	if err != nil {
+		return err
+	}
 	Configuration = config
 	return nil
 }
 
+// Synchronize hypertable policies with the current configuration. Partitioning is applied
+// first since dimensions may not be added once compression is enabled. Configuration is only
+// read at startup, so this runs once and policy changes require a restart.
+func syncHypertablePolicies(ctx context.Context) error {
+	partitioning := Configuration.Partitioning
+	err := Api.SyncDevicePartitioning(ctx, partitioning.DevicePartitions, partitioning.MigrateExisting)
+	if err != nil {
+		return err
+	}
+
+	var tuning *model.ChunkTuningSettings
+	if partitioning.ChunkTuning.Enabled {
+		tuning = &model.ChunkTuningSettings{
+			TargetBytes:      partitioning.ChunkTuning.TargetChunkBytes,
+			MinInterval:      partitioning.ChunkTuning.MinInterval,
+			MaxInterval:      partitioning.ChunkTuning.MaxInterval,
+			ScheduleInterval: partitioning.ChunkTuning.ScheduleInterval,
+		}
+	}
+	err = Api.SyncChunkTuning(ctx, tuning)
+	if err != nil {
+		return err
+	}
+
+	retention := Configuration.Retention
+	err = Api.SyncRetentionPolicies(ctx, []model.PolicySetting{
+		{Hypertable: model.HYPERTABLE_EVENTS, Interval: retention.Events},
+		{Hypertable: model.HYPERTABLE_LOCATION_EVENTS, Interval: retention.Locations},
+		{Hypertable: model.HYPERTABLE_MEASUREMENT_EVENTS, Interval: retention.Measurements},
+		{Hypertable: model.MEASUREMENT_ROLLUP_HOURLY, Interval: retention.MeasurementsHourly},
+		{Hypertable: model.MEASUREMENT_ROLLUP_DAILY, Interval: retention.MeasurementsDaily},
+		{Hypertable: model.HYPERTABLE_ALERT_EVENTS, Interval: retention.Alerts},
+		{Hypertable: model.HYPERTABLE_GEOFENCE_EVENTS, Interval: retention.Geofences},
+		{Hypertable: model.HYPERTABLE_ANOMALY_EVENTS, Interval: retention.Anomalies},
+	})
+	if err != nil {
+		return err
+	}
+
+	var compressAfter *string
+	if Configuration.Compression.Enabled {
+		compressAfter = &Configuration.Compression.CompressAfter
+	}
+	return Api.SyncCompressionPolicies(ctx, compressAfter)
+}
+
+// Register units from configuration with the api unit converter.
+func registerUnits() error {
+	for _, unit := range Configuration.Units {
+		err := Api.Units.Register(model.UnitDefinition{
+			Quantity: unit.Quantity,
+			Unit:     unit.Unit,
+			Aliases:  unit.Aliases,
+			Factor:   unit.Factor,
+			Offset:   unit.Offset,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Create kafka components used by this microservice.
 func createKafkaComponents(kmgr *kcore.KafkaManager) error {
 	// Create reader for resolved events.
@@ -110,7 +178,7 @@ func createKafkaComponents(kmgr *kcore.KafkaManager) error {
 
 	// Add and initialize inbound events processor.
 	EventPersistenceProcessor = processor.NewEventPersistenceProcessor(Microservice, ResolvedEventsReader,
-		PersistedEventsWriter, FailedEventsWriter, core.NewNoOpLifecycleCallbacks(), Api)
+		PersistedEventsWriter, FailedEventsWriter, core.NewNoOpLifecycleCallbacks(), Api, *Configuration)
 	err = EventPersistenceProcessor.Initialize(context.Background())
 	if err != nil {
 		return err
@@ -141,6 +209,18 @@ func afterMicroserviceInitialized(ctx context.Context) error {
 	// Wrap api around rdb manager.
 	Api = model.NewApi(RdbManager)
 
+	// Add units from configuration to the conversion table.
+	err = registerUnits()
+	if err != nil {
+		return err
+	}
+
+	// Apply hypertable policies from configuration.
+	err = syncHypertablePolicies(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Create and initialize kafka manager.
 	KakfaManager = kcore.NewKafkaManager(Microservice, core.NewNoOpLifecycleCallbacks(), createKafkaComponents)
 	err = KakfaManager.Initialize(ctx)
@@ -151,6 +231,7 @@ func afterMicroserviceInitialized(ctx context.Context) error {
 	// Map of providers that will be injected into graphql http context.
 	providers := map[gqlcore.ContextKey]interface{}{
 		gqlcore.ContextRdbKey: RdbManager,
+		gqlcore.ContextApiKey: Api,
 	}
 
 	// Create and initialize graphql manager.
@@ -159,6 +240,9 @@ func afterMicroserviceInitialized(ctx context.Context) error {
 	schema := graphql.SchemaContent
 	parsed := gql.MustParseSchema(schema, &graphql.SchemaResolver{})
 	GraphQLManager = gqlcore.NewGraphQLManager(Microservice, gqlcb, *parsed, providers)
+
+	// Add handler for device track downloads (served along with graphql).
+	http.Handle(graphql.TRACK_EXPORT_PATH, graphql.NewTrackExportHandler(providers))
 	err = GraphQLManager.Initialize(ctx)
 	if err != nil {
 		return err
@@ -0,0 +1,61 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"time"
+
+	esmodel "github.com/devicechain-io/dc-event-sources/model"
+	gormigrate "github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Creates alert storage as a hypertable.
+func NewAlertSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018094000",
+		Migrate: func(tx *gorm.DB) error {
+			// Alert event fields.
+			type AlertEvent struct {
+				DeviceId     uint              `gorm:"not null"`
+				EventType    esmodel.EventType `gorm:"not null"`
+				OccurredTime time.Time         `gorm:"not null"`
+				Type         string            `gorm:"not null;size:128"`
+				Level        uint32            `gorm:"not null"`
+				Message      string            `gorm:"size:1024"`
+				Source       string            `gorm:"size:128"`
+			}
+
+			err := tx.AutoMigrate(&AlertEvent{})
+			if err != nil {
+				return err
+			}
+
+			// Convert to a hypertable.
+			err = tx.Raw("SELECT create_hypertable('\"event-management\".\"alert_events\"', 'occurred_time');").Row().Err()
+			if err != nil {
+				return err
+			}
+
+			// Add index on device id.
+			return tx.Exec("CREATE INDEX ON \"event-management\".\"alert_events\" (device_id, occurred_time DESC);").Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("alert_events")
+		},
+	}
+}
@@ -0,0 +1,76 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"time"
+
+	esmodel "github.com/devicechain-io/dc-event-sources/model"
+	gormigrate "github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Creates snapshots of rolling measurement statistics and anomaly event storage.
+func NewAnomalySchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018110000",
+		Migrate: func(tx *gorm.DB) error {
+			// Rolling statistics for a device measurement.
+			type MeasurementStatistics struct {
+				DeviceId    uint    `gorm:"primaryKey"`
+				Name        string  `gorm:"primaryKey;size:128"`
+				Mean        float64 `gorm:"not null"`
+				Variance    float64 `gorm:"not null"`
+				Count       int64   `gorm:"not null"`
+				UpdatedTime time.Time
+			}
+
+			// Anomaly event fields.
+			type AnomalyEvent struct {
+				DeviceId     uint              `gorm:"not null"`
+				EventType    esmodel.EventType `gorm:"not null"`
+				OccurredTime time.Time         `gorm:"not null"`
+				Name         string            `gorm:"not null;size:128"`
+				Value        float64           `gorm:"not null"`
+				Mean         float64           `gorm:"not null"`
+				StdDev       float64           `gorm:"not null"`
+				ZScore       float64           `gorm:"not null"`
+			}
+
+			err := tx.AutoMigrate(&MeasurementStatistics{}, &AnomalyEvent{})
+			if err != nil {
+				return err
+			}
+
+			// Convert to a hypertable.
+			err = tx.Raw("SELECT create_hypertable('\"event-management\".\"anomaly_events\"', 'occurred_time');").Row().Err()
+			if err != nil {
+				return err
+			}
+
+			// Add indexes on device id and measurement name.
+			err = tx.Exec("CREATE INDEX ON \"event-management\".\"anomaly_events\" (device_id, occurred_time DESC);").Error
+			if err != nil {
+				return err
+			}
+			return tx.Exec("CREATE INDEX ON \"event-management\".\"anomaly_events\" (name, occurred_time DESC);").Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("anomaly_events", "measurement_statistics")
+		},
+	}
+}
@@ -18,24 +18,58 @@ package model
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/devicechain-io/dc-microservice/rdb"
 )
 
 type Api struct {
-	RDB *rdb.RdbManager
+	RDB   *rdb.RdbManager
+	Units *UnitConverter
 }
 
 // Create a new API instance.
 func NewApi(rdb *rdb.RdbManager) *Api {
 	api := &Api{}
 	api.RDB = rdb
+	api.Units = NewUnitConverter()
 	return api
 }
 
 // Interface for event management API (used for mocking)
 type EventManagementApi interface {
 	CreateLocationEvent(ctx context.Context, request *LocationEventCreateRequest) (*LocationEvent, error)
+	LastLocation(ctx context.Context, deviceId uint, before time.Time) (*LocationEvent, error)
+	CreateMeasurementEvent(ctx context.Context, request *MeasurementEventCreateRequest) (*MeasurementEvent, error)
+	CreateAlertEvent(ctx context.Context, request *AlertEventCreateRequest) (*AlertEvent, error)
+	CreateGeofenceEvent(ctx context.Context, request *GeofenceEventCreateRequest) (*GeofenceEvent, error)
+	ActiveGeofences(ctx context.Context) ([]Geofence, error)
+	GeofenceStates(ctx context.Context, deviceId uint) ([]GeofenceState, error)
+	SaveGeofenceState(ctx context.Context, state *GeofenceState) error
+	TripDetectorState(ctx context.Context, deviceId uint) (*TripDetectorState, error)
+	SaveTripDetectorState(ctx context.Context, state *TripDetectorState) error
+	ActiveTrip(ctx context.Context, deviceId uint) (*Trip, error)
+	ActiveStop(ctx context.Context, deviceId uint) (*Stop, error)
+	SaveTrip(ctx context.Context, trip *Trip) error
+	SaveStop(ctx context.Context, stop *Stop) error
+	DwellPosition(ctx context.Context, deviceId uint) (*DwellPosition, error)
+	AddAreaDwells(ctx context.Context, position *DwellPosition, dwells []AreaDwell) error
+	AllMeasurementDefinitions(ctx context.Context) ([]MeasurementDefinition, error)
+	ActiveThresholdRules(ctx context.Context) ([]ThresholdRule, error)
+	ThresholdRuleStates(ctx context.Context, deviceId uint) ([]ThresholdRuleState, error)
+	SaveThresholdRuleState(ctx context.Context, state *ThresholdRuleState) error
+	CreateThresholdRuleAlert(ctx context.Context, request *AlertEventCreateRequest, state *ThresholdRuleState) (*AlertEvent, error)
+	MeasurementStatistics(ctx context.Context, deviceId uint) ([]MeasurementStatistics, error)
+	SaveMeasurementStatistics(ctx context.Context, stats []MeasurementStatistics) error
+	CreateAnomalyEvent(ctx context.Context, request *AnomalyEventCreateRequest) (*AnomalyEvent, error)
+	ActiveVirtualMeasurements(ctx context.Context) ([]VirtualMeasurement, error)
+	UnitConverter() *UnitConverter
+}
+
+// Get the converter for built-in and configured units.
+func (api *Api) UnitConverter() *UnitConverter {
+	return api.Units
 }
 
 // Create a new location event.
@@ -47,6 +81,20 @@ func (api *Api) CreateLocationEvent(ctx context.Context, request *LocationEventC
 		Longitude:    rdb.NullFloat64Of(request.Longitude),
 		Elevation:    rdb.NullFloat64Of(request.Elevation),
 		Event:        request.Event,
+
+		Accuracy:   rdb.NullFloat64Of(request.Accuracy),
+		Speed:      rdb.NullFloat64Of(request.Speed),
+		Course:     rdb.NullFloat64Of(request.Course),
+		Satellites: rdb.NullInt64Of(request.Satellites),
+		FixType:    rdb.NullStrOf(request.FixType),
+
+		ComputedSpeed:        rdb.NullFloat64Of(request.ComputedSpeed),
+		ComputedHeading:      rdb.NullFloat64Of(request.ComputedHeading),
+		DistanceFromPrevious: rdb.NullFloat64Of(request.DistanceFromPrevious),
+		SuspectReason:        rdb.NullStrOf(request.SuspectReason),
+		PlaceName:            rdb.NullStrOf(request.PlaceName),
+		CountryCode:          rdb.NullStrOf(request.CountryCode),
+		RegionCode:           rdb.NullStrOf(request.RegionCode),
 	}
 	result := api.RDB.Database.Create(created)
 	if result.Error != nil {
@@ -54,3 +102,72 @@ func (api *Api) CreateLocationEvent(ctx context.Context, request *LocationEventC
 	}
 	return created, nil
 }
+
+// Get the most recent location with coordinates reported by a device before the given time
+// (nil if none). Suspect locations are ignored.
+func (api *Api) LastLocation(ctx context.Context, deviceId uint, before time.Time) (*LocationEvent, error) {
+	found := make([]*LocationEvent, 0)
+	result := api.RDB.Database.WithContext(ctx).
+		Where("device_id = ? AND occurred_time < ? AND latitude IS NOT NULL AND longitude IS NOT NULL AND suspect_reason IS NULL", deviceId, before).
+		Order("occurred_time DESC").Limit(1).Find(&found)
+	if result.Error != nil || len(found) == 0 {
+		return nil, result.Error
+	}
+	return found[0], nil
+}
+
+// Create a new measurement event.
+func (api *Api) CreateMeasurementEvent(ctx context.Context, request *MeasurementEventCreateRequest) (*MeasurementEvent, error) {
+	created := &MeasurementEvent{
+		DeviceId:      request.DeviceId,
+		EventType:     request.EventType,
+		OccurredTime:  request.OccurredTime,
+		Name:          request.Name,
+		Value:         request.Value,
+		Unit:          rdb.NullStrOf(request.Unit),
+		RawUnit:       rdb.NullStrOf(request.RawUnit),
+		RawValue:      rdb.NullFloat64Of(request.RawValue),
+		SuspectReason: rdb.NullStrOf(request.SuspectReason),
+		Classifier:    rdb.NullInt64Of(request.Classifier),
+		Latitude:      rdb.NullFloat64Of(request.Latitude),
+		Longitude:     rdb.NullFloat64Of(request.Longitude),
+		Elevation:     rdb.NullFloat64Of(request.Elevation),
+		Event:         request.Event,
+	}
+	if request.LocationInferred {
+		created.LocationInferred = sql.NullBool{Bool: true, Valid: true}
+	}
+	result := api.RDB.Database.Create(created)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return created, nil
+}
+
+// Create a new alert event.
+func (api *Api) CreateAlertEvent(ctx context.Context, request *AlertEventCreateRequest) (*AlertEvent, error) {
+	created := alertEventOf(request)
+	result := api.RDB.Database.Create(created)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return created, nil
+}
+
+// Build an alert event entity from a create request.
+func alertEventOf(request *AlertEventCreateRequest) *AlertEvent {
+	return &AlertEvent{
+		DeviceId:     request.DeviceId,
+		EventType:    request.EventType,
+		OccurredTime: request.OccurredTime,
+		Type:         request.Type,
+		Level:        request.Level,
+		Message:      request.Message,
+		Source:       request.Source,
+		Event:        request.Event,
+
+		RuleId:         request.RuleId,
+		TriggerValue:   rdb.NullFloat64Of(request.TriggerValue),
+		RuleTransition: rdb.NullStrOf(request.RuleTransition),
+	}
+}
@@ -0,0 +1,280 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Qualify a table name with the schema for this functional area.
+func (api *Api) qualified(table string) string {
+	return fmt.Sprintf("\"%s\".\"%s\"", api.RDB.Microservice.FunctionalArea, table)
+}
+
+// Convert a duration into a postgres interval literal.
+func asInterval(duration time.Duration) string {
+	return fmt.Sprintf("%d seconds", int64(duration.Seconds()))
+}
+
+// Choose the coarsest rollup that can answer a query exactly. Buckets must be a whole
+// multiple of the rollup width and the time range must align on rollup boundaries.
+func SelectMeasurementRollup(bucket time.Duration, start time.Time, end time.Time) *MeasurementRollup {
+	for _, rollup := range MeasurementRollups {
+		if bucket < rollup.Width || bucket%rollup.Width != 0 {
+			continue
+		}
+		if !start.UTC().Truncate(rollup.Width).Equal(start) || !end.UTC().Truncate(rollup.Width).Equal(end) {
+			continue
+		}
+		return &rollup
+	}
+	return nil
+}
+
+// Build where clause shared by raw and rollup measurement queries. Columns are qualified with the
+// table alias if one is given.
+func measurementFilters(alias string, timecol string, criteria MeasurementAggregateCriteria) (string, []interface{}) {
+	prefix := ""
+	if alias != "" {
+		prefix = alias + "."
+	}
+	clauses := []string{fmt.Sprintf("%s%s >= ?", prefix, timecol), fmt.Sprintf("%s%s < ?", prefix, timecol)}
+	args := []interface{}{criteria.StartTime, criteria.EndTime}
+	if len(criteria.DeviceIds) > 0 {
+		clauses = append(clauses, prefix+"device_id IN ?")
+		args = append(args, criteria.DeviceIds)
+	}
+	if len(criteria.Names) > 0 {
+		clauses = append(clauses, prefix+"name IN ?")
+		args = append(args, criteria.Names)
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+// Build where clause for queries over raw measurements. Measurements flagged as suspect are left
+// out as they are for rollups.
+func rawMeasurementFilters(alias string, criteria MeasurementAggregateCriteria) (string, []interface{}) {
+	where, args := measurementFilters(alias, "occurred_time", criteria)
+	prefix := ""
+	if alias != "" {
+		prefix = alias + "."
+	}
+	return fmt.Sprintf("%s AND %ssuspect_reason IS NULL", where, prefix), args
+}
+
+// Aggregate measurements into time buckets, using continuous aggregates where possible. Virtual
+// measurements included in the criteria names are computed from their inputs for buckets in
+// which no values were stored (gap filling is not supported for them).
+func (api *Api) MeasurementAggregates(ctx context.Context,
+	criteria MeasurementAggregateCriteria) (*MeasurementAggregateResults, error) {
+	if criteria.Bucket <= 0 {
+		return nil, fmt.Errorf("bucket width must be positive")
+	}
+	if !criteria.EndTime.After(criteria.StartTime) {
+		return nil, fmt.Errorf("end time must be after start time")
+	}
+
+	if criteria.Fill != "" {
+		switch criteria.Fill {
+		case GAPFILL_NULLS, GAPFILL_LOCF, GAPFILL_INTERPOLATE:
+		default:
+			return nil, fmt.Errorf("unknown gap fill: %s", criteria.Fill)
+		}
+		if criteria.EndTime.Sub(criteria.StartTime)/criteria.Bucket > MAX_GAPFILL_BUCKETS {
+			return nil, fmt.Errorf("gap filled queries are limited to %d buckets", MAX_GAPFILL_BUCKETS)
+		}
+	}
+
+	// Rollups do not track units, so converted values are always computed from raw measurements.
+	var found *MeasurementAggregateResults
+	var err error
+	if criteria.Unit != nil {
+		found, err = api.convertedMeasurementAggregates(ctx, criteria)
+	} else {
+		found, err = api.storedMeasurementAggregates(ctx, criteria)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Add values for virtual measurements in buckets where they were not stored.
+	if len(criteria.Names) > 0 {
+		found.Results, err = api.addVirtualMeasurementAggregates(ctx, criteria, found.Results)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+// Aggregate stored measurements into time buckets, using continuous aggregates where possible.
+func (api *Api) storedMeasurementAggregates(ctx context.Context,
+	criteria MeasurementAggregateCriteria) (*MeasurementAggregateResults, error) {
+	var query string
+	var source string
+	var args []interface{}
+	rollup := SelectMeasurementRollup(criteria.Bucket, criteria.StartTime, criteria.EndTime)
+	if rollup != nil {
+		source = rollup.View
+		selects, sargs := aggregateSelect("bucket", rollupAggregates, criteria)
+		where, wargs := measurementFilters("", "bucket", criteria)
+		query = fmt.Sprintf(`SELECT %s
+FROM %s WHERE %s GROUP BY 1, 2, 3 ORDER BY 1, 2, 3`, selects, api.qualified(rollup.View), where)
+		args = append(sargs, wargs...)
+	} else {
+		source = HYPERTABLE_MEASUREMENT_EVENTS
+		selects, sargs := aggregateSelect("occurred_time", rawAggregates, criteria)
+		where, wargs := rawMeasurementFilters("", criteria)
+		query = fmt.Sprintf(`SELECT %s
+FROM %s WHERE %s GROUP BY 1, 2, 3 ORDER BY 1, 2, 3`, selects, api.qualified(source), where)
+		args = append(sargs, wargs...)
+	}
+
+	results := make([]MeasurementAggregate, 0)
+	result := api.RDB.Database.WithContext(ctx).Raw(query, args...).Scan(&results)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &MeasurementAggregateResults{
+		Results: results,
+		Source:  source,
+	}, nil
+}
+
+// Aggregate expressions for a measurement source.
+type aggregateExpressions struct {
+	Count string
+	Min   string
+	Max   string
+	Avg   string
+	Sum   string
+}
+
+// Aggregates computed from raw measurements.
+var rawAggregates = aggregateExpressions{
+	Count: "count(*)",
+	Min:   "min(value)",
+	Max:   "max(value)",
+	Avg:   "avg(value)",
+	Sum:   "sum(value)",
+}
+
+// Aggregates computed by combining rollup statistics.
+var rollupAggregates = aggregateExpressions{
+	Count: "sum(sample_count)",
+	Min:   "min(min_value)",
+	Max:   "max(max_value)",
+	Avg:   "sum(sum_value) / sum(sample_count)",
+	Sum:   "sum(sum_value)",
+}
+
+// Build the select list for bucketed aggregates. When gap filling, every bucket in the time
+// range is returned and statistics for empty buckets are filled as requested (counts are zero).
+func aggregateSelect(timecol string, exprs aggregateExpressions, criteria MeasurementAggregateCriteria) (string, []interface{}) {
+	bucket := fmt.Sprintf("time_bucket(?::interval, %s)", timecol)
+	args := []interface{}{asInterval(criteria.Bucket)}
+	fill := "%s"
+	if criteria.Fill != "" {
+		bucket = fmt.Sprintf("time_bucket_gapfill(?::interval, %s, ?::timestamptz, ?::timestamptz)", timecol)
+		args = append(args, criteria.StartTime, criteria.EndTime)
+		switch criteria.Fill {
+		case GAPFILL_LOCF:
+			fill = "locf(%s)"
+		case GAPFILL_INTERPOLATE:
+			fill = "interpolate(%s)"
+		}
+	}
+	return fmt.Sprintf(`device_id, name, %s AS bucket, %s AS count,
+	%s AS min, %s AS max, %s AS avg, %s AS sum`, bucket, exprs.Count, fmt.Sprintf(fill, exprs.Min),
+		fmt.Sprintf(fill, exprs.Max), fmt.Sprintf(fill, exprs.Avg), fmt.Sprintf(fill, exprs.Sum)), args
+}
+
+// Unit of raw measurements (as m), falling back to the unit of the measurement definition (as d)
+// for measurements stored without a unit.
+const measurementUnitColumn = "lower(COALESCE(m.unit, d.unit))"
+
+// Join of raw measurements (as m) to their definitions (as d) for unit fallback.
+func (api *Api) measurementDefinitionJoin() string {
+	return fmt.Sprintf("LEFT JOIN %s d ON d.token = m.name AND d.deleted_at IS NULL",
+		api.qualified(TABLE_MEASUREMENT_DEFINITIONS))
+}
+
+// Build an expression that converts raw measurement values (as m) to a target unit based on the
+// unit of each measurement (null if the unit can not be converted).
+func unitConversionExpression(conversions []UnitConversion) (string, []interface{}) {
+	var sb strings.Builder
+	args := make([]interface{}, 0)
+	sb.WriteString(fmt.Sprintf("CASE %s", measurementUnitColumn))
+	for _, conversion := range conversions {
+		sb.WriteString(" WHEN ? THEN m.value * ? + ?")
+		args = append(args, conversion.Unit, conversion.Scale, conversion.Offset)
+	}
+	sb.WriteString(" END")
+	return sb.String(), args
+}
+
+// Get names of units that can be converted to the target unit.
+func conversionUnits(conversions []UnitConversion) []string {
+	units := make([]string, 0)
+	for _, conversion := range conversions {
+		units = append(units, conversion.Unit)
+	}
+	return units
+}
+
+// Build the query aggregating raw measurements into time buckets after converting values to the
+// criteria unit. Measurements without a unit use the unit of their definition; those in units that
+// can not be converted (or without any unit) are excluded.
+func (api *Api) convertedAggregateQuery(criteria MeasurementAggregateCriteria,
+	conversions []UnitConversion) (string, []interface{}) {
+	selects, sargs := aggregateSelect("occurred_time", rawAggregates, criteria)
+	converted, cargs := unitConversionExpression(conversions)
+	where, wargs := rawMeasurementFilters("m", criteria)
+	query := fmt.Sprintf(`SELECT %s
+FROM (SELECT m.device_id, m.name, m.occurred_time, %s AS value FROM %s m %s
+	WHERE %s AND %s IN ?) m
+GROUP BY 1, 2, 3 ORDER BY 1, 2, 3`, selects, converted, api.qualified(HYPERTABLE_MEASUREMENT_EVENTS),
+		api.measurementDefinitionJoin(), where, measurementUnitColumn)
+	args := append(sargs, cargs...)
+	args = append(args, wargs...)
+	args = append(args, conversionUnits(conversions))
+	return query, args
+}
+
+// Aggregate raw measurements into time buckets after converting values to the criteria unit.
+func (api *Api) convertedMeasurementAggregates(ctx context.Context,
+	criteria MeasurementAggregateCriteria) (*MeasurementAggregateResults, error) {
+	conversions, err := api.Units.ConversionsTo(*criteria.Unit)
+	if err != nil {
+		return nil, err
+	}
+	query, args := api.convertedAggregateQuery(criteria, conversions)
+
+	results := make([]MeasurementAggregate, 0)
+	result := api.RDB.Database.WithContext(ctx).Raw(query, args...).Scan(&results)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &MeasurementAggregateResults{
+		Results: results,
+		Source:  HYPERTABLE_MEASUREMENT_EVENTS,
+		Unit:    criteria.Unit,
+	}, nil
+}
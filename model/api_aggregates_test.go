@@ -0,0 +1,228 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test rollup selection for bucket widths and time ranges.
+func TestSelectMeasurementRollup(t *testing.T) {
+	day := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// Whole days over a day-aligned range use the daily rollup.
+	rollup := SelectMeasurementRollup(7*24*time.Hour, day, day.AddDate(0, 3, 0))
+	assert.NotNil(t, rollup)
+	assert.Equal(t, MEASUREMENT_ROLLUP_DAILY, rollup.View)
+
+	// Hourly buckets use the hourly rollup.
+	rollup = SelectMeasurementRollup(6*time.Hour, day, day.Add(48*time.Hour))
+	assert.NotNil(t, rollup)
+	assert.Equal(t, MEASUREMENT_ROLLUP_HOURLY, rollup.View)
+
+	// Daily buckets over a range that is not day-aligned fall back to hourly.
+	rollup = SelectMeasurementRollup(24*time.Hour, day.Add(time.Hour), day.Add(49*time.Hour))
+	assert.NotNil(t, rollup)
+	assert.Equal(t, MEASUREMENT_ROLLUP_HOURLY, rollup.View)
+
+	// Sub-hour buckets must read raw measurements.
+	assert.Nil(t, SelectMeasurementRollup(15*time.Minute, day, day.Add(time.Hour)))
+	assert.Nil(t, SelectMeasurementRollup(90*time.Minute, day, day.Add(3*time.Hour)))
+}
+
+// Test gap filling of aggregate expressions.
+func TestAggregateSelect(t *testing.T) {
+	day := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	criteria := MeasurementAggregateCriteria{StartTime: day, EndTime: day.Add(time.Hour), Bucket: time.Minute}
+
+	// Buckets are not filled by default.
+	selects, args := aggregateSelect("occurred_time", rawAggregates, criteria)
+	assert.Contains(t, selects, "time_bucket(?::interval, occurred_time)")
+	assert.Contains(t, selects, "avg(value) AS avg")
+	assert.Equal(t, []interface{}{"60 seconds"}, args)
+
+	// Gap filling passes the time range and wraps statistics (but not counts).
+	criteria.Fill = GAPFILL_LOCF
+	selects, args = aggregateSelect("bucket", rollupAggregates, criteria)
+	assert.Contains(t, selects, "time_bucket_gapfill(?::interval, bucket, ?::timestamptz, ?::timestamptz)")
+	assert.Contains(t, selects, "locf(min(min_value)) AS min")
+	assert.Contains(t, selects, "sum(sample_count) AS count")
+	assert.Len(t, args, 3)
+
+	criteria.Fill = GAPFILL_NULLS
+	selects, _ = aggregateSelect("occurred_time", rawAggregates, criteria)
+	assert.Contains(t, selects, "max(value) AS max")
+	assert.NotContains(t, selects, "interpolate")
+}
+
+// Test validation of distribution criteria and parsing of array results.
+func TestMeasurementDistributionCriteria(t *testing.T) {
+	day := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	criteria := MeasurementDistributionCriteria{Name: "temp", StartTime: day, EndTime: day.Add(time.Hour),
+		Bucket: time.Hour, GroupBy: DISTRIBUTION_GROUP_DEVICE}
+	assert.Nil(t, validateDistributionCriteria(&criteria))
+	assert.Equal(t, DefaultPercentiles, criteria.Percentiles)
+	assert.Equal(t, "{0.5,0.9,0.99}", floatArrayLiteral(criteria.Percentiles))
+
+	criteria.Percentiles = []float64{1.5}
+	assert.NotNil(t, validateDistributionCriteria(&criteria))
+	criteria.Percentiles = nil
+	criteria.Histogram = &HistogramSettings{Min: 10, Max: 0, Bins: 5}
+	assert.NotNil(t, validateDistributionCriteria(&criteria))
+	criteria.Histogram = nil
+	criteria.GroupBy = "AREA"
+	assert.NotNil(t, validateDistributionCriteria(&criteria))
+
+	percentiles, err := parseFloatArray("{2.5,7,-1e-05}")
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{2.5, 7, -0.00001}, percentiles)
+	counts, err := parseIntArray("{0,3,4,0}")
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{0, 3, 4, 0}, counts)
+	empty, err := parseIntArray("{}")
+	assert.Nil(t, err)
+	assert.Empty(t, empty)
+}
+
+// Test computing virtual measurement aggregates from input values.
+func TestVirtualMeasurementAggregates(t *testing.T) {
+	day := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	hour := day.Add(time.Hour)
+	virtual := &VirtualMeasurement{Expression: "voltage * current"}
+	virtual.Token = "power"
+	expr, err := ParseExpression(virtual.Expression)
+	assert.Nil(t, err)
+
+	at := func(minutes int) time.Time { return day.Add(time.Duration(minutes) * time.Minute) }
+	inputs := []virtualInput{
+		{DeviceId: 1, Name: "current", OccurredTime: at(0), Bucket: day, Value: 1},
+		{DeviceId: 1, Name: "voltage", OccurredTime: at(0), Bucket: day, Value: 100},
+		{DeviceId: 1, Name: "current", OccurredTime: at(10), Bucket: day, Value: 3},
+		{DeviceId: 1, Name: "voltage", OccurredTime: at(10), Bucket: day, Value: 200},
+		{DeviceId: 1, Name: "voltage", OccurredTime: at(20), Bucket: day, Value: 230},
+		{DeviceId: 1, Name: "voltage", OccurredTime: at(60), Bucket: hour, Value: 231},
+		{DeviceId: 2, Name: "current", OccurredTime: at(0), Bucket: day, Value: 2},
+		{DeviceId: 2, Name: "voltage", OccurredTime: at(0), Bucket: day, Value: 120},
+	}
+
+	// Expressions are evaluated per sample (avg(V*I), not avg(V)*avg(I)) and samples missing an
+	// input are left out.
+	computed := virtualMeasurementAggregates(virtual, expr, inputs, nil, nil)
+	assert.Len(t, computed, 2)
+	assert.Equal(t, "power", computed[0].Name)
+	assert.Equal(t, uint(1), computed[0].DeviceId)
+	assert.Equal(t, int64(2), computed[0].Count)
+	assert.InDelta(t, 350, *computed[0].Avg, 1e-9)
+	assert.InDelta(t, 100, *computed[0].Min, 1e-9)
+	assert.InDelta(t, 600, *computed[0].Max, 1e-9)
+	assert.InDelta(t, 700, *computed[0].Sum, 1e-9)
+	assert.InDelta(t, 240, *computed[1].Avg, 1e-9)
+	assert.Equal(t, int64(1), computed[1].Count)
+
+	// Buckets with stored values are skipped.
+	computed = virtualMeasurementAggregates(virtual, expr, inputs, map[deviceBucket]bool{{DeviceId: 1, Bucket: day}: true}, nil)
+	assert.Len(t, computed, 1)
+	assert.Equal(t, uint(2), computed[0].DeviceId)
+
+	// Values are converted if requested.
+	computed = virtualMeasurementAggregates(virtual, expr, inputs, nil, &UnitConversion{Scale: 0.001})
+	assert.InDelta(t, 0.35, *computed[0].Avg, 1e-9)
+	assert.InDelta(t, 0.6, *computed[0].Max, 1e-9)
+}
+
+// Test virtual measurement inputs are read in sample order with a limit.
+func TestVirtualInputQuery(t *testing.T) {
+	api := newQueryApi()
+	day := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	criteria := MeasurementAggregateCriteria{Names: []string{"power"}, DeviceIds: []uint{1},
+		StartTime: day, EndTime: day.Add(time.Hour), Bucket: time.Hour}
+	query, args := api.virtualInputQuery(criteria, []string{"voltage", "current"})
+	assert.Contains(t, query, "time_bucket(?::interval, occurred_time) AS bucket")
+	assert.Contains(t, query, "ORDER BY device_id, occurred_time, name LIMIT ?")
+	assert.Equal(t, "3600 seconds", args[0])
+	assert.Contains(t, args, []string{"voltage", "current"})
+	assert.Equal(t, MAX_VIRTUAL_INPUT_VALUES+1, args[len(args)-1])
+}
+
+// Test converted aggregates handle measurements in mixed units and without a unit.
+func TestConvertedAggregateQuery(t *testing.T) {
+	api := newQueryApi()
+	day := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	unit := "celsius"
+	criteria := MeasurementAggregateCriteria{Names: []string{"temp"}, StartTime: day, EndTime: day.Add(time.Hour),
+		Bucket: time.Hour, Unit: &unit}
+	conversions, err := api.Units.ConversionsTo(unit)
+	assert.Nil(t, err)
+	query, args := api.convertedAggregateQuery(criteria, conversions)
+
+	// Rows in any convertible unit are converted; rows without a unit use the definition unit.
+	assert.Contains(t, query, "CASE lower(COALESCE(m.unit, d.unit)) WHEN ? THEN m.value * ? + ?")
+	assert.Contains(t, query, `LEFT JOIN "event-management"."measurement_definitions" d ON d.token = m.name AND d.deleted_at IS NULL`)
+	assert.Contains(t, query, "m.occurred_time >= ? AND m.occurred_time < ? AND m.name IN ? AND m.suspect_reason IS NULL AND lower(COALESCE(m.unit, d.unit)) IN ?")
+	units := args[len(args)-1].([]string)
+	assert.Contains(t, units, "fahrenheit")
+	assert.Contains(t, units, "celsius")
+	assert.Contains(t, units, "degreescelcius")
+
+	// Bucket, unit, scale and offset per conversion, time range, names and units.
+	assert.Equal(t, 1+3*len(conversions)+3+1, len(args))
+}
+
+// Test converted distributions handle measurements in mixed units and without a unit.
+func TestConvertedDistributionQuery(t *testing.T) {
+	api := newQueryApi()
+	day := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	criteria := MeasurementDistributionCriteria{Name: "temp", StartTime: day, EndTime: day.Add(time.Hour),
+		Bucket: time.Hour, GroupBy: DISTRIBUTION_GROUP_DEVICE}
+	assert.Nil(t, validateDistributionCriteria(&criteria))
+
+	// Without a unit values are used as stored and definitions are not joined.
+	query, _, err := api.distributionQuery(criteria)
+	assert.Nil(t, err)
+	assert.NotContains(t, query, "measurement_definitions")
+	assert.Contains(t, query, "m.suspect_reason IS NULL")
+
+	unit := "fahrenheit"
+	criteria.Unit = &unit
+	query, _, err = api.distributionQuery(criteria)
+	assert.Nil(t, err)
+	assert.Contains(t, query, "ORDER BY (CASE lower(COALESCE(m.unit, d.unit))")
+	assert.Contains(t, query, "LEFT JOIN \"event-management\".\"measurement_definitions\" d")
+	assert.Contains(t, query, "AND lower(COALESCE(m.unit, d.unit)) IN ?")
+
+	// Unknown units are rejected.
+	unit = "furlongs"
+	_, _, err = api.distributionQuery(criteria)
+	assert.NotNil(t, err)
+}
+
+// Test suspect measurements are left out of raw queries and rollups.
+func TestSuspectMeasurementFilters(t *testing.T) {
+	day := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	criteria := MeasurementAggregateCriteria{Names: []string{"temp"}, StartTime: day, EndTime: day.Add(time.Hour)}
+	where, args := rawMeasurementFilters("", criteria)
+	assert.Equal(t, "occurred_time >= ? AND occurred_time < ? AND name IN ? AND suspect_reason IS NULL", where)
+	assert.Equal(t, 3, len(args))
+	where, _ = measurementFilters("", "bucket", criteria)
+	assert.NotContains(t, where, "suspect_reason")
+
+	statement := measurementRollupStatement(MEASUREMENT_ROLLUP_HOURLY, "1 hour", measurementRollupFilter)
+	assert.Contains(t, statement, `"measurement_events" WHERE suspect_reason IS NULL`)
+}
@@ -0,0 +1,65 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"context"
+
+	"gorm.io/gorm/clause"
+)
+
+// Get rolling measurement statistics saved for a device.
+func (api *Api) MeasurementStatistics(ctx context.Context, deviceId uint) ([]MeasurementStatistics, error) {
+	found := make([]MeasurementStatistics, 0)
+	result := api.RDB.Database.WithContext(ctx).Find(&found, "device_id = ?", deviceId)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
+
+// Save snapshots of rolling measurement statistics, replacing earlier snapshots.
+func (api *Api) SaveMeasurementStatistics(ctx context.Context, stats []MeasurementStatistics) error {
+	if len(stats) == 0 {
+		return nil
+	}
+	return api.RDB.Database.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "device_id"}, {Name: "name"}},
+		UpdateAll: true,
+	}).Create(&stats).Error
+}
+
+// Create a new anomaly event. Anomalies refer to the base event of the measurement they were
+// detected in (which is stored first), so no base event is written for them.
+func (api *Api) CreateAnomalyEvent(ctx context.Context, request *AnomalyEventCreateRequest) (*AnomalyEvent, error) {
+	created := &AnomalyEvent{
+		DeviceId:     request.DeviceId,
+		EventType:    request.EventType,
+		OccurredTime: request.OccurredTime,
+		Name:         request.Name,
+		Value:        request.Value,
+		Mean:         request.Mean,
+		StdDev:       request.StdDev,
+		ZScore:       request.ZScore,
+		Event:        request.Event,
+	}
+	result := api.RDB.Database.WithContext(ctx).Omit("Event").Create(created)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return created, nil
+}
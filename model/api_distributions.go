@@ -0,0 +1,230 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Columns used to group measurement distributions (on events joined as e).
+var distributionGroupColumns = map[string]string{
+	DISTRIBUTION_GROUP_NONE:           "NULL::bigint",
+	DISTRIBUTION_GROUP_DEVICE:         "m.device_id",
+	DISTRIBUTION_GROUP_DEVICE_GROUP:   "e.rel_device_group_id",
+	DISTRIBUTION_GROUP_ASSET:          "e.rel_asset_id",
+	DISTRIBUTION_GROUP_ASSET_GROUP:    "e.rel_asset_group_id",
+	DISTRIBUTION_GROUP_CUSTOMER:       "e.rel_customer_id",
+	DISTRIBUTION_GROUP_CUSTOMER_GROUP: "e.rel_customer_group_id",
+}
+
+// Split the text form of a postgres array into elements.
+func parseArrayText(text string) []string {
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "{"), "}")
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(text, ",")
+}
+
+// Parse the text form of a postgres float array.
+func parseFloatArray(text string) ([]float64, error) {
+	values := make([]float64, 0)
+	for _, element := range parseArrayText(text) {
+		value, err := strconv.ParseFloat(element, 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// Parse the text form of a postgres integer array.
+func parseIntArray(text string) ([]int64, error) {
+	values := make([]int64, 0)
+	for _, element := range parseArrayText(text) {
+		value, err := strconv.ParseInt(element, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// Format values as a postgres array literal.
+func floatArrayLiteral(values []float64) string {
+	elements := make([]string, 0, len(values))
+	for _, value := range values {
+		elements = append(elements, strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	return "{" + strings.Join(elements, ",") + "}"
+}
+
+// Validate distribution criteria, applying default percentiles.
+func validateDistributionCriteria(criteria *MeasurementDistributionCriteria) error {
+	if criteria.Name == "" {
+		return fmt.Errorf("measurement name is required")
+	}
+	if criteria.Bucket <= 0 {
+		return fmt.Errorf("bucket width must be positive")
+	}
+	if !criteria.EndTime.After(criteria.StartTime) {
+		return fmt.Errorf("end time must be after start time")
+	}
+	if _, ok := distributionGroupColumns[criteria.GroupBy]; !ok {
+		return fmt.Errorf("unknown distribution grouping: %s", criteria.GroupBy)
+	}
+	if len(criteria.Percentiles) == 0 {
+		criteria.Percentiles = DefaultPercentiles
+	}
+	for _, percentile := range criteria.Percentiles {
+		if percentile < 0 || percentile > 1 {
+			return fmt.Errorf("percentiles must be between 0 and 1: %v", percentile)
+		}
+	}
+	if criteria.Histogram != nil {
+		if criteria.Histogram.Bins < 1 || criteria.Histogram.Bins > MAX_HISTOGRAM_BINS {
+			return fmt.Errorf("histograms require between 1 and %d bins", MAX_HISTOGRAM_BINS)
+		}
+		if criteria.Histogram.Max <= criteria.Histogram.Min {
+			return fmt.Errorf("histogram max must be greater than min")
+		}
+	}
+	return nil
+}
+
+// Row returned by distribution queries (arrays are read in text form).
+type distributionRow struct {
+	GroupId     *uint
+	Bucket      time.Time
+	Count       int64
+	Percentiles string
+	Histogram   sql.NullString
+}
+
+// Build the query computing percentiles and histograms for validated criteria. Measurements
+// flagged as suspect are left out. When converting to a unit, measurements without a unit use
+// the unit of their definition and those in units that can not be converted (or without any
+// unit) are excluded.
+func (api *Api) distributionQuery(criteria MeasurementDistributionCriteria) (string, []interface{}, error) {
+	value := "m.value"
+	join := ""
+	args := make([]interface{}, 0)
+	filters := []string{"m.name = ?", "m.occurred_time >= ?", "m.occurred_time < ?", "m.suspect_reason IS NULL"}
+	fargs := []interface{}{criteria.Name, criteria.StartTime, criteria.EndTime}
+	if criteria.Unit != nil {
+		conversions, err := api.Units.ConversionsTo(*criteria.Unit)
+		if err != nil {
+			return "", nil, err
+		}
+		converted, cargs := unitConversionExpression(conversions)
+		value = "(" + converted + ")"
+		join = api.measurementDefinitionJoin()
+		filters = append(filters, measurementUnitColumn+" IN ?")
+		fargs = append(fargs, conversionUnits(conversions))
+		args = append(args, cargs...)
+	}
+	ids := []struct {
+		column string
+		ids    []uint
+	}{
+		{"m.device_id", criteria.DeviceIds},
+		{"e.rel_device_group_id", criteria.DeviceGroupIds},
+		{"e.rel_asset_id", criteria.AssetIds},
+		{"e.rel_asset_group_id", criteria.AssetGroupIds},
+		{"e.rel_customer_id", criteria.CustomerIds},
+		{"e.rel_customer_group_id", criteria.CustomerGroupIds},
+	}
+	for _, filter := range ids {
+		if len(filter.ids) > 0 {
+			filters = append(filters, filter.column+" IN ?")
+			fargs = append(fargs, filter.ids)
+		}
+	}
+
+	histogram := "NULL::text"
+	var hargs []interface{}
+	if criteria.Histogram != nil {
+		histogram = fmt.Sprintf("histogram(%s, ?::float8, ?::float8, ?::int)::text", value)
+		hargs = []interface{}{criteria.Histogram.Min, criteria.Histogram.Max, criteria.Histogram.Bins}
+	}
+
+	// Conversion arguments appear once per use of the value expression.
+	query := fmt.Sprintf(`SELECT %s AS group_id, time_bucket(?::interval, m.occurred_time) AS bucket, count(*) AS count,
+	percentile_cont(?::float8[]) WITHIN GROUP (ORDER BY %s)::text AS percentiles, %s AS histogram
+FROM %s m
+JOIN %s e ON e.device_id = m.device_id AND e.event_type = m.event_type AND e.occurred_time = m.occurred_time
+%s
+WHERE %s GROUP BY 1, 2 ORDER BY 1, 2`, distributionGroupColumns[criteria.GroupBy], value, histogram,
+		api.qualified(HYPERTABLE_MEASUREMENT_EVENTS), api.qualified(HYPERTABLE_EVENTS), join, strings.Join(filters, " AND "))
+	qargs := []interface{}{asInterval(criteria.Bucket), floatArrayLiteral(criteria.Percentiles)}
+	qargs = append(qargs, args...)
+	if criteria.Histogram != nil {
+		qargs = append(qargs, args...)
+		qargs = append(qargs, hargs...)
+	}
+	qargs = append(qargs, fargs...)
+	return query, qargs, nil
+}
+
+// Compute percentiles and histograms of a measurement for each group and time bucket. Percentiles
+// are exact (continuous) values computed from raw measurements.
+func (api *Api) MeasurementDistributions(ctx context.Context,
+	criteria MeasurementDistributionCriteria) (*MeasurementDistributionResults, error) {
+	err := validateDistributionCriteria(&criteria)
+	if err != nil {
+		return nil, err
+	}
+	query, qargs, err := api.distributionQuery(criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]distributionRow, 0)
+	result := api.RDB.Database.WithContext(ctx).Raw(query, qargs...).Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	distributions := make([]MeasurementDistribution, 0, len(rows))
+	for _, row := range rows {
+		distribution := MeasurementDistribution{GroupId: row.GroupId, Bucket: row.Bucket, Count: row.Count}
+		distribution.Percentiles, err = parseFloatArray(row.Percentiles)
+		if err != nil {
+			return nil, err
+		}
+		if row.Histogram.Valid {
+			distribution.Histogram, err = parseIntArray(row.Histogram.String)
+			if err != nil {
+				return nil, err
+			}
+		}
+		distributions = append(distributions, distribution)
+	}
+	return &MeasurementDistributionResults{
+		Percentiles: criteria.Percentiles,
+		Histogram:   criteria.Histogram,
+		Unit:        criteria.Unit,
+		Results:     distributions,
+	}, nil
+}
@@ -0,0 +1,115 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Columns identifying dwell time for a device, area and day. Relations match the expressions of
+// the unique index since nulls never conflict.
+var areaDwellKey = []clause.Column{
+	{Name: "device_id"}, {Name: "area_type"}, {Name: "area_id"}, {Name: "day"},
+	{Name: "COALESCE(rel_asset_id, 0)", Raw: true}, {Name: "COALESCE(rel_customer_id, 0)", Raw: true},
+}
+
+// Get the last position of a device counted toward dwell time (nil if none).
+func (api *Api) DwellPosition(ctx context.Context, deviceId uint) (*DwellPosition, error) {
+	found := make([]*DwellPosition, 0)
+	result := api.RDB.Database.WithContext(ctx).Where("device_id = ?", deviceId).Limit(1).Find(&found)
+	if result.Error != nil || len(found) == 0 {
+		return nil, result.Error
+	}
+	return found[0], nil
+}
+
+// Save the last position of a device and add dwell time accumulated since the previous one in
+// a single transaction. Time accumulates with time already recorded for the day.
+func (api *Api) AddAreaDwells(ctx context.Context, position *DwellPosition, dwells []AreaDwell) error {
+	return api.RDB.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(dwells) > 0 {
+			err := tx.Clauses(clause.OnConflict{
+				Columns: areaDwellKey,
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"seconds": gorm.Expr("area_dwells.seconds + excluded.seconds"),
+				}),
+			}).Create(&dwells).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Save(position).Error
+	})
+}
+
+// Report total dwell time inside areas for a range of days.
+func (api *Api) AreaDwellReport(ctx context.Context, criteria AreaDwellCriteria) ([]AreaDwellTotal, error) {
+	if criteria.EndDay.Before(criteria.StartDay) {
+		return nil, fmt.Errorf("end day must not be before start day")
+	}
+	var group string
+	switch criteria.GroupBy {
+	case DWELL_GROUP_DEVICE:
+		group = "device_id"
+	case DWELL_GROUP_ASSET:
+		group = "rel_asset_id"
+	case DWELL_GROUP_CUSTOMER:
+		group = "rel_customer_id"
+	default:
+		return nil, fmt.Errorf("unknown dwell grouping: %s", criteria.GroupBy)
+	}
+	columns := []string{group, "area_type", "area_id"}
+	if criteria.Daily {
+		columns = append(columns, "day")
+	}
+
+	result := api.RDB.Database.WithContext(ctx).Model(&AreaDwell{}).
+		Where("day >= ? AND day <= ?", criteria.StartDay.Format("2006-01-02"), criteria.EndDay.Format("2006-01-02"))
+	if criteria.AreaType != nil {
+		result = result.Where("area_type = ?", *criteria.AreaType)
+	}
+	filters := []struct {
+		column string
+		ids    []uint
+	}{
+		{"area_id", criteria.AreaIds},
+		{"device_id", criteria.DeviceIds},
+		{"rel_asset_id", criteria.AssetIds},
+		{"rel_customer_id", criteria.CustomerIds},
+	}
+	for _, filter := range filters {
+		if len(filter.ids) > 0 {
+			result = result.Where(filter.column+" IN ?", filter.ids)
+		}
+	}
+
+	found := make([]AreaDwellTotal, 0)
+	for i := range columns {
+		result = result.Group(columns[i]).Order(columns[i])
+	}
+	selected := append([]string{group + " AS group_id"}, columns[1:]...)
+	selected = append(selected, "sum(seconds) AS seconds", "count(DISTINCT device_id) AS devices")
+	result = result.Select(selected).Scan(&found)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
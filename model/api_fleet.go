@@ -0,0 +1,138 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Build the query for last known locations of devices at each snapshot time from start to end at
+// the given interval along with its arguments. Devices are those matching the criteria with a
+// location within max age of a snapshot time; the time range of the criteria is ignored. Each
+// lookup seeks the latest location at or before the snapshot time per device using the
+// (device_id, occurred_time) index.
+func (api *Api) fleetQuery(start time.Time, end time.Time, interval time.Duration, maxAge time.Duration,
+	criteria LocationSearchCriteria) (string, []interface{}, error) {
+	if maxAge <= 0 {
+		maxAge = DEFAULT_FLEET_MAX_AGE
+	}
+
+	// Find devices with a location in the window covered by all snapshots.
+	window := criteria
+	window.StartTime = start.Add(-maxAge)
+	window.EndTime = end.Add(time.Microsecond)
+	where, args, err := locationFilters(window)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Suspect and relationship filters are applied again for the location found per device.
+	rclauses, rargs := relationshipFilters("l", criteria)
+	lateral := []string{"l.device_id = d.device_id", "l.occurred_time <= s.snapshot_time",
+		"l.occurred_time >= s.snapshot_time - make_interval(secs => ?)", "l.geog IS NOT NULL"}
+	if !criteria.IncludeSuspect {
+		lateral = append(lateral, "l.suspect_reason IS NULL")
+	}
+	lateral = append(lateral, rclauses...)
+
+	query := fmt.Sprintf(`WITH snapshots AS (
+	SELECT generate_series(?::timestamptz, ?::timestamptz, make_interval(secs => ?)) AS snapshot_time
+), devices AS (
+	SELECT DISTINCT l.device_id FROM %[1]s l
+	JOIN %[2]s e ON e.device_id = l.device_id AND e.event_type = l.event_type AND e.occurred_time = l.occurred_time
+	WHERE %[3]s ORDER BY l.device_id LIMIT ?
+)
+SELECT s.snapshot_time, latest.* FROM snapshots s CROSS JOIN devices d
+CROSS JOIN LATERAL (
+	SELECT %[4]s FROM %[1]s l
+	JOIN %[2]s e ON e.device_id = l.device_id AND e.event_type = l.event_type AND e.occurred_time = l.occurred_time
+	WHERE %[5]s ORDER BY l.occurred_time DESC LIMIT 1
+) latest
+ORDER BY s.snapshot_time, latest.device_id`, api.qualified(HYPERTABLE_LOCATION_EVENTS), api.qualified(HYPERTABLE_EVENTS),
+		where, locatedEventColumns, strings.Join(lateral, " AND "))
+	qargs := []interface{}{start, end, interval.Seconds()}
+	qargs = append(qargs, args...)
+	qargs = append(qargs, maxResults(criteria), maxAge.Seconds())
+	qargs = append(qargs, rargs...)
+	return query, qargs, nil
+}
+
+// Get last known locations of devices at each snapshot time (see fleetQuery).
+func (api *Api) fleetPositions(ctx context.Context, start time.Time, end time.Time, interval time.Duration,
+	maxAge time.Duration, criteria LocationSearchCriteria) ([]FleetPosition, error) {
+	query, args, err := api.fleetQuery(start, end, interval, maxAge, criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]FleetPosition, 0)
+	result := api.RDB.Database.WithContext(ctx).Raw(query, args...).Scan(&found)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
+
+// Get the last known location of each device matching the criteria at a point in time. Devices
+// without a location within max age of the time are not included.
+func (api *Api) FleetSnapshot(ctx context.Context, at time.Time, maxAge time.Duration,
+	criteria LocationSearchCriteria) (*FleetSnapshot, error) {
+	found, err := api.fleetPositions(ctx, at, at, time.Second, maxAge, criteria)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &FleetSnapshot{Time: at, Locations: make([]LocatedEvent, 0)}
+	for _, position := range found {
+		snapshot.Locations = append(snapshot.Locations, position.LocatedEvent)
+	}
+	return snapshot, nil
+}
+
+// Get fleet snapshots at fixed intervals across a time window (inclusive of both ends).
+func (api *Api) FleetPlayback(ctx context.Context, start time.Time, end time.Time, interval time.Duration,
+	maxAge time.Duration, criteria LocationSearchCriteria) ([]FleetSnapshot, error) {
+	if end.Before(start) {
+		return nil, fmt.Errorf("end time must not be before start time")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("playback interval must be positive")
+	}
+	frames := end.Sub(start)/interval + 1
+	if frames > MAX_PLAYBACK_FRAMES {
+		return nil, fmt.Errorf("playback would return %d snapshots (maximum is %d)", frames, MAX_PLAYBACK_FRAMES)
+	}
+	found, err := api.fleetPositions(ctx, start, end, interval, maxAge, criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group positions into snapshots (including those without devices).
+	snapshots := make([]FleetSnapshot, 0, frames)
+	for at := start; !at.After(end); at = at.Add(interval) {
+		snapshots = append(snapshots, FleetSnapshot{Time: at, Locations: make([]LocatedEvent, 0)})
+	}
+	for _, position := range found {
+		index := int((position.SnapshotTime.Sub(start) + interval/2) / interval)
+		if index >= 0 && index < len(snapshots) {
+			snapshots[index].Locations = append(snapshots[index].Locations, position.LocatedEvent)
+		}
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,66 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Get the where clause of the per-device lateral lookup in a fleet query.
+func fleetLateralWhere(query string) string {
+	lateral := query[strings.Index(query, "CROSS JOIN LATERAL"):]
+	where := lateral[strings.Index(lateral, "WHERE ")+len("WHERE "):]
+	return where[:strings.Index(where, " ORDER BY")]
+}
+
+// Test the query for a single fleet snapshot.
+func TestFleetQuerySnapshot(t *testing.T) {
+	api := newQueryApi()
+	at := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	criteria := LocationSearchCriteria{DeviceGroupIds: []uint{9}}
+	query, args, err := api.fleetQuery(at, at, time.Second, 0, criteria)
+	assert.Nil(t, err)
+
+	// Devices and the location found per device both exclude suspect rows and apply filters.
+	assert.Equal(t, 2, strings.Count(query, "l.suspect_reason IS NULL"))
+	assert.Equal(t, 2, strings.Count(query, "e.rel_device_group_id IN ?"))
+	assert.Equal(t, "l.device_id = d.device_id AND l.occurred_time <= s.snapshot_time AND "+
+		"l.occurred_time >= s.snapshot_time - make_interval(secs => ?) AND l.geog IS NOT NULL AND "+
+		"l.suspect_reason IS NULL AND e.rel_device_group_id IN ?", fleetLateralWhere(query))
+
+	// Snapshot series, device window (default max age), limit, max age and lateral filters.
+	assert.Equal(t, []interface{}{at, at, 1.0, at.Add(-DEFAULT_FLEET_MAX_AGE), at.Add(time.Microsecond), []uint{9},
+		DEFAULT_GEO_MAX_RESULTS, DEFAULT_FLEET_MAX_AGE.Seconds(), []uint{9}}, args)
+}
+
+// Test the query for fleet playback.
+func TestFleetQueryPlayback(t *testing.T) {
+	api := newQueryApi()
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	criteria := LocationSearchCriteria{DeviceIds: []uint{1, 2}, MaxResults: 10, IncludeSuspect: true}
+	query, args, err := api.fleetQuery(start, end, 15*time.Minute, time.Hour, criteria)
+	assert.Nil(t, err)
+	assert.NotContains(t, query, "suspect_reason IS NULL")
+	assert.Contains(t, fleetLateralWhere(query), "l.device_id IN ?")
+	assert.Equal(t, []interface{}{start, end, 900.0, start.Add(-time.Hour), end.Add(time.Microsecond), []uint{1, 2},
+		10, 3600.0, []uint{1, 2}}, args)
+}
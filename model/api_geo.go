@@ -0,0 +1,245 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/geo"
+)
+
+// Validate a latitude/longitude pair.
+func validatePoint(point GeoPoint) error {
+	if point.Latitude < -90 || point.Latitude > 90 {
+		return fmt.Errorf("latitude out of range: %f", point.Latitude)
+	}
+	if point.Longitude < -180 || point.Longitude > 180 {
+		return fmt.Errorf("longitude out of range: %f", point.Longitude)
+	}
+	return nil
+}
+
+// Build WKT for a polygon, closing the ring if necessary.
+func PolygonWkt(points []GeoPoint) (string, error) {
+	if len(points) < 3 {
+		return "", fmt.Errorf("polygon requires at least three points")
+	}
+	ring := points
+	if points[0] != points[len(points)-1] {
+		ring = append(append([]GeoPoint{}, points...), points[0])
+	}
+	coords := make([]string, 0, len(ring))
+	for _, point := range ring {
+		if err := validatePoint(point); err != nil {
+			return "", err
+		}
+		coords = append(coords, fmt.Sprintf("%f %f", point.Longitude, point.Latitude))
+	}
+	return fmt.Sprintf("SRID=4326;POLYGON((%s))", strings.Join(coords, ", ")), nil
+}
+
+// Build clauses for device and relationship filters on rows of an event table (with the given
+// alias) joined to events (as e).
+func relationshipFilters(alias string, criteria LocationSearchCriteria) ([]string, []interface{}) {
+	clauses := make([]string, 0)
+	args := make([]interface{}, 0)
+	filters := []struct {
+		column string
+		ids    []uint
+	}{
+		{alias + ".device_id", criteria.DeviceIds},
+		{"e.rel_device_group_id", criteria.DeviceGroupIds},
+		{"e.rel_customer_id", criteria.CustomerIds},
+		{"e.rel_customer_group_id", criteria.CustomerGroupIds},
+		{"e.rel_area_id", criteria.AreaIds},
+		{"e.rel_area_group_id", criteria.AreaGroupIds},
+	}
+	for _, filter := range filters {
+		if len(filter.ids) > 0 {
+			clauses = append(clauses, filter.column+" IN ?")
+			args = append(args, filter.ids)
+		}
+	}
+	return clauses, args
+}
+
+// Build where clause for time range and relationship filters on rows of an event table (with the
+// given alias) joined to events (as e). The located condition selects rows with a position. Rows
+// flagged as suspect are excluded unless requested.
+func spatialFilters(alias string, located string, criteria LocationSearchCriteria) (string, []interface{}, error) {
+	if !criteria.EndTime.After(criteria.StartTime) {
+		return "", nil, fmt.Errorf("end time must be after start time")
+	}
+	clauses := []string{alias + ".occurred_time >= ?", alias + ".occurred_time < ?", located}
+	if !criteria.IncludeSuspect {
+		clauses = append(clauses, alias+".suspect_reason IS NULL")
+	}
+	args := []interface{}{criteria.StartTime, criteria.EndTime}
+	rclauses, rargs := relationshipFilters(alias, criteria)
+	clauses = append(clauses, rclauses...)
+	args = append(args, rargs...)
+	return strings.Join(clauses, " AND "), args, nil
+}
+
+// Build where clause for time range and relationship filters on joined location/event rows.
+func locationFilters(criteria LocationSearchCriteria) (string, []interface{}, error) {
+	return spatialFilters("l", "l.geog IS NOT NULL", criteria)
+}
+
+// Get maximum number of results for criteria.
+func maxResults(criteria LocationSearchCriteria) int {
+	if criteria.MaxResults <= 0 {
+		return DEFAULT_GEO_MAX_RESULTS
+	}
+	return criteria.MaxResults
+}
+
+// Columns selected for located events.
+const locatedEventColumns = `l.device_id, l.occurred_time, e.source,
+	l.latitude, l.longitude, l.elevation, l.accuracy, l.speed, l.course, l.satellites,
+	l.fix_type, l.computed_speed, l.computed_heading, l.distance_from_previous, l.suspect_reason,
+	l.place_name, l.country_code, l.region_code, e.rel_customer_id, e.rel_customer_group_id,
+	e.rel_area_id, e.rel_area_group_id, e.rel_asset_id`
+
+// Search located events matching a spatial condition.
+func (api *Api) searchLocations(ctx context.Context, spatial string, sargs []interface{},
+	criteria LocationSearchCriteria) ([]LocatedEvent, error) {
+	where, args, err := locationFilters(criteria)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(`SELECT %s FROM %s l
+JOIN %s e ON e.device_id = l.device_id AND e.event_type = l.event_type AND e.occurred_time = l.occurred_time
+WHERE %s AND %s ORDER BY l.occurred_time DESC LIMIT ?`, locatedEventColumns,
+		api.qualified(HYPERTABLE_LOCATION_EVENTS), api.qualified(HYPERTABLE_EVENTS), where, spatial)
+	args = append(args, sargs...)
+	args = append(args, maxResults(criteria))
+
+	found := make([]LocatedEvent, 0)
+	result := api.RDB.Database.WithContext(ctx).Raw(query, args...).Scan(&found)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
+
+// Build the spatial condition for locations within a bounding box. Geography overlap with the
+// envelope uses the spatial index but only compares bounding boxes (with edges following great
+// circles), so coordinates are also compared against the box edges exactly.
+func boundingBoxCondition(box GeoBoundingBox) (string, []interface{}, error) {
+	if err := validatePoint(GeoPoint{Latitude: box.South, Longitude: box.West}); err != nil {
+		return "", nil, err
+	}
+	if err := validatePoint(GeoPoint{Latitude: box.North, Longitude: box.East}); err != nil {
+		return "", nil, err
+	}
+	if box.South > box.North {
+		return "", nil, fmt.Errorf("south edge must not be north of north edge")
+	}
+
+	// Boxes crossing the antimeridian are split in two.
+	envelope := "l.geog && ST_MakeEnvelope(?, ?, ?, ?, 4326)::geography"
+	if box.West > box.East {
+		return "(" + envelope + " OR " + envelope + ") AND l.latitude BETWEEN ? AND ? AND (l.longitude >= ? OR l.longitude <= ?)",
+			[]interface{}{box.West, box.South, 180.0, box.North, -180.0, box.South, box.East, box.North,
+				box.South, box.North, box.West, box.East}, nil
+	}
+	return envelope + " AND l.latitude BETWEEN ? AND ? AND l.longitude BETWEEN ? AND ?",
+		[]interface{}{box.West, box.South, box.East, box.North, box.South, box.North, box.West, box.East}, nil
+}
+
+// Find location events within a bounding box during a time range.
+func (api *Api) LocationsInBoundingBox(ctx context.Context, box GeoBoundingBox,
+	criteria LocationSearchCriteria) ([]LocatedEvent, error) {
+	spatial, sargs, err := boundingBoxCondition(box)
+	if err != nil {
+		return nil, err
+	}
+	return api.searchLocations(ctx, spatial, sargs, criteria)
+}
+
+// Find location events within a polygon during a time range.
+func (api *Api) LocationsInPolygon(ctx context.Context, polygon []GeoPoint,
+	criteria LocationSearchCriteria) ([]LocatedEvent, error) {
+	wkt, err := PolygonWkt(polygon)
+	if err != nil {
+		return nil, err
+	}
+	return api.searchLocations(ctx, "ST_Covers(ST_GeogFromText(?), l.geog)", []interface{}{wkt}, criteria)
+}
+
+// Find devices that reported a location within the given distance (in meters) of a point during
+// a time range. The closest location reported by each device is returned, nearest devices first.
+func (api *Api) DevicesNearPoint(ctx context.Context, point GeoPoint, meters float64,
+	criteria LocationSearchCriteria) ([]DeviceProximity, error) {
+	if err := validatePoint(point); err != nil {
+		return nil, err
+	}
+	if meters <= 0 {
+		return nil, fmt.Errorf("radius must be positive")
+	}
+	where, args, err := locationFilters(criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`WITH poi AS (SELECT ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography AS geog)
+SELECT * FROM (
+	SELECT DISTINCT ON (l.device_id) %s, ST_Distance(l.geog, poi.geog) AS distance
+	FROM %s l
+	JOIN %s e ON e.device_id = l.device_id AND e.event_type = l.event_type AND e.occurred_time = l.occurred_time
+	CROSS JOIN poi
+	WHERE %s AND ST_DWithin(l.geog, poi.geog, ?)
+	ORDER BY l.device_id, distance, l.occurred_time DESC
+) nearest ORDER BY distance LIMIT ?`, locatedEventColumns,
+		api.qualified(HYPERTABLE_LOCATION_EVENTS), api.qualified(HYPERTABLE_EVENTS), where)
+	args = append([]interface{}{point.Longitude, point.Latitude}, args...)
+	args = append(args, meters, maxResults(criteria))
+
+	found := make([]DeviceProximity, 0)
+	result := api.RDB.Database.WithContext(ctx).Raw(query, args...).Scan(&found)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
+
+// Get locations reported by a device during a time range in chronological order, excluding those
+// flagged as suspect. Ranges with more than MAX_TRACK_POINTS locations result in an error rather
+// than a truncated track.
+func (api *Api) DeviceTrack(ctx context.Context, deviceId uint, start time.Time, end time.Time) ([]geo.TrackPoint, error) {
+	if !end.After(start) {
+		return nil, fmt.Errorf("end time must be after start time")
+	}
+	query := fmt.Sprintf(`SELECT latitude, longitude, elevation, occurred_time AS time FROM %s
+WHERE device_id = ? AND occurred_time >= ? AND occurred_time < ?
+	AND latitude IS NOT NULL AND longitude IS NOT NULL AND suspect_reason IS NULL
+ORDER BY occurred_time LIMIT ?`, api.qualified(HYPERTABLE_LOCATION_EVENTS))
+
+	found := make([]geo.TrackPoint, 0)
+	result := api.RDB.Database.WithContext(ctx).Raw(query, deviceId, start, end, MAX_TRACK_POINTS+1).Scan(&found)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(found) > MAX_TRACK_POINTS {
+		return nil, fmt.Errorf("track has more than %d locations; request a shorter time range", MAX_TRACK_POINTS)
+	}
+	return found, nil
+}
@@ -0,0 +1,90 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test polygon WKT generation and validation.
+func TestPolygonWkt(t *testing.T) {
+	// Open rings are closed.
+	wkt, err := PolygonWkt([]GeoPoint{{0, 0}, {0, 1}, {1, 1}})
+	assert.Nil(t, err)
+	assert.Equal(t, "SRID=4326;POLYGON((0.000000 0.000000, 1.000000 0.000000, 1.000000 1.000000, 0.000000 0.000000))", wkt)
+
+	// Closed rings are left as-is.
+	closed, err := PolygonWkt([]GeoPoint{{0, 0}, {0, 1}, {1, 1}, {0, 0}})
+	assert.Nil(t, err)
+	assert.Equal(t, wkt, closed)
+
+	// Too few points or invalid coordinates are rejected.
+	_, err = PolygonWkt([]GeoPoint{{0, 0}, {0, 1}})
+	assert.NotNil(t, err)
+	_, err = PolygonWkt([]GeoPoint{{0, 0}, {91, 1}, {1, 1}})
+	assert.NotNil(t, err)
+}
+
+// Test bounding box conditions combine index overlap with exact coordinate checks.
+func TestBoundingBoxCondition(t *testing.T) {
+	box := GeoBoundingBox{South: 10, West: 20, North: 11, East: 21}
+	condition, args, err := boundingBoxCondition(box)
+	assert.Nil(t, err)
+	assert.Contains(t, condition, "l.geog && ST_MakeEnvelope(?, ?, ?, ?, 4326)::geography")
+	assert.Contains(t, condition, "l.latitude BETWEEN ? AND ? AND l.longitude BETWEEN ? AND ?")
+	assert.Equal(t, []interface{}{20.0, 10.0, 21.0, 11.0, 10.0, 11.0, 20.0, 21.0}, args)
+
+	// Boxes crossing the antimeridian match longitudes on either side.
+	box = GeoBoundingBox{South: -5, West: 170, North: 5, East: -170}
+	condition, args, err = boundingBoxCondition(box)
+	assert.Nil(t, err)
+	assert.Contains(t, condition, " OR ")
+	assert.Contains(t, condition, "l.latitude BETWEEN ? AND ? AND (l.longitude >= ? OR l.longitude <= ?)")
+	assert.Equal(t, []interface{}{170.0, -5.0, 180.0, 5.0, -180.0, -5.0, -170.0, 5.0, -5.0, 5.0, 170.0, -170.0}, args)
+
+	// Invalid boxes are rejected.
+	_, _, err = boundingBoxCondition(GeoBoundingBox{South: 5, West: 0, North: -5, East: 1})
+	assert.NotNil(t, err)
+	_, _, err = boundingBoxCondition(GeoBoundingBox{South: 0, West: 0, North: 95, East: 1})
+	assert.NotNil(t, err)
+}
+
+// Test suspect rows are excluded from spatial filters unless requested.
+func TestSpatialFiltersSuspect(t *testing.T) {
+	criteria := LocationSearchCriteria{
+		StartTime: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2022, 6, 2, 0, 0, 0, 0, time.UTC),
+		DeviceIds: []uint{1},
+	}
+	where, args, err := locationFilters(criteria)
+	assert.Nil(t, err)
+	assert.Equal(t, "l.occurred_time >= ? AND l.occurred_time < ? AND l.geog IS NOT NULL AND l.suspect_reason IS NULL AND l.device_id IN ?", where)
+	assert.Equal(t, 3, len(args))
+
+	criteria.IncludeSuspect = true
+	where, _, err = locationFilters(criteria)
+	assert.Nil(t, err)
+	assert.NotContains(t, where, "suspect_reason")
+
+	// Time range must not be empty.
+	criteria.EndTime = criteria.StartTime
+	_, _, err = locationFilters(criteria)
+	assert.NotNil(t, err)
+}
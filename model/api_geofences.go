@@ -0,0 +1,226 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/devicechain-io/dc-microservice/rdb"
+	"gorm.io/gorm"
+)
+
+// Validate geofence shape and apply it to the entity.
+func applyGeofenceShape(fence *Geofence, request *GeofenceCreateRequest) error {
+	fence.Vertices = sql.NullString{}
+	fence.CenterLatitude = sql.NullFloat64{}
+	fence.CenterLongitude = sql.NullFloat64{}
+	fence.Radius = sql.NullFloat64{}
+
+	circle := request.Center != nil || request.Radius != nil
+	if circle && len(request.Vertices) > 0 {
+		return fmt.Errorf("geofence must be either a polygon or a circle")
+	}
+	if circle {
+		if request.Center == nil || request.Radius == nil || *request.Radius <= 0 {
+			return fmt.Errorf("circular geofence requires a center and positive radius")
+		}
+		if err := validatePoint(*request.Center); err != nil {
+			return err
+		}
+		fence.CenterLatitude = rdb.NullFloat64Of(&request.Center.Latitude)
+		fence.CenterLongitude = rdb.NullFloat64Of(&request.Center.Longitude)
+		fence.Radius = rdb.NullFloat64Of(request.Radius)
+		return nil
+	}
+
+	if _, err := PolygonWkt(request.Vertices); err != nil {
+		return err
+	}
+	vertices, err := json.Marshal(request.Vertices)
+	if err != nil {
+		return err
+	}
+	fence.Vertices = sql.NullString{String: string(vertices), Valid: true}
+	return nil
+}
+
+// Create a new geofence.
+func (api *Api) CreateGeofence(ctx context.Context, request *GeofenceCreateRequest) (*Geofence, error) {
+	created := &Geofence{
+		TokenReference: rdb.TokenReference{
+			Token: request.Token,
+		},
+		NamedEntity: rdb.NamedEntity{
+			Name:        rdb.NullStrOf(request.Name),
+			Description: rdb.NullStrOf(request.Description),
+		},
+		MetadataEntity: rdb.MetadataEntity{
+			Metadata: rdb.MetadataStrOf(request.Metadata),
+		},
+		AreaId: request.AreaId,
+	}
+	err := applyGeofenceShape(created, request)
+	if err != nil {
+		return nil, err
+	}
+	result := api.RDB.Database.Create(created)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return created, nil
+}
+
+// Update an existing geofence.
+func (api *Api) UpdateGeofence(ctx context.Context, token string, request *GeofenceCreateRequest) (*Geofence, error) {
+	matches, err := api.GeofencesByToken(ctx, []string{token})
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	// Update fields that changed.
+	updated := matches[0]
+	updated.Token = request.Token
+	updated.Name = rdb.NullStrOf(request.Name)
+	updated.Description = rdb.NullStrOf(request.Description)
+	updated.Metadata = rdb.MetadataStrOf(request.Metadata)
+	updated.AreaId = request.AreaId
+	err = applyGeofenceShape(updated, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result := api.RDB.Database.Save(updated)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return updated, nil
+}
+
+// Delete an existing geofence along with device states for it.
+func (api *Api) DeleteGeofence(ctx context.Context, token string) (*Geofence, error) {
+	matches, err := api.GeofencesByToken(ctx, []string{token})
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	deleted := matches[0]
+	err = api.RDB.Database.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&GeofenceState{}, "geofence_id = ?", deleted.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		return tx.Delete(deleted).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// Get geofences by id.
+func (api *Api) GeofencesById(ctx context.Context, ids []uint) ([]*Geofence, error) {
+	found := make([]*Geofence, 0)
+	result := api.RDB.Database.Find(&found, ids)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
+
+// Get geofences by token.
+func (api *Api) GeofencesByToken(ctx context.Context, tokens []string) ([]*Geofence, error) {
+	found := make([]*Geofence, 0)
+	result := api.RDB.Database.Find(&found, "token in ?", tokens)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
+
+// Search for geofences that meet criteria.
+func (api *Api) Geofences(ctx context.Context, criteria GeofenceSearchCriteria) (*GeofenceSearchResults, error) {
+	results := make([]Geofence, 0)
+	db, pag := api.RDB.ListOf(&Geofence{}, func(result *gorm.DB) *gorm.DB {
+		if criteria.AreaId != nil {
+			result = result.Where("area_id = ?", *criteria.AreaId)
+		}
+		return result
+	}, criteria.Pagination)
+	db.Find(&results)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+
+	// Wrap as search results.
+	return &GeofenceSearchResults{
+		Results:    results,
+		Pagination: pag,
+	}, nil
+}
+
+// Get all geofences used when evaluating location events.
+func (api *Api) ActiveGeofences(ctx context.Context) ([]Geofence, error) {
+	found := make([]Geofence, 0)
+	result := api.RDB.Database.WithContext(ctx).Find(&found)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
+
+// Get states for a device relative to geofences.
+func (api *Api) GeofenceStates(ctx context.Context, deviceId uint) ([]GeofenceState, error) {
+	found := make([]GeofenceState, 0)
+	result := api.RDB.Database.WithContext(ctx).Find(&found, "device_id = ?", deviceId)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
+
+// Save state of a device relative to a geofence.
+func (api *Api) SaveGeofenceState(ctx context.Context, state *GeofenceState) error {
+	return api.RDB.Database.WithContext(ctx).Save(state).Error
+}
+
+// Create a new geofence event.
+func (api *Api) CreateGeofenceEvent(ctx context.Context, request *GeofenceEventCreateRequest) (*GeofenceEvent, error) {
+	created := &GeofenceEvent{
+		DeviceId:     request.DeviceId,
+		EventType:    request.EventType,
+		OccurredTime: request.OccurredTime,
+		GeofenceId:   request.GeofenceId,
+		Transition:   request.Transition,
+		Latitude:     request.Latitude,
+		Longitude:    request.Longitude,
+		Event:        request.Event,
+	}
+	result := api.RDB.Database.Create(created)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return created, nil
+}
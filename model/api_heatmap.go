@@ -0,0 +1,127 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"context"
+	"fmt"
+)
+
+// Get expression templates for the cell containing the position of a row (formatted with the table
+// alias) and for the latitude and longitude of the center of a cell (formatted with the cell).
+func heatmapCellExpressions(grid HeatmapGrid) (string, string, string, error) {
+	switch grid.Type {
+	case HEATMAP_GRID_GEOHASH:
+		if grid.Precision < 1 || grid.Precision > MAX_GEOHASH_PRECISION {
+			return "", "", "", fmt.Errorf("geohash precision must be between 1 and %d", MAX_GEOHASH_PRECISION)
+		}
+		cell := fmt.Sprintf("ST_GeoHash(ST_SetSRID(ST_MakePoint(%%[1]s.longitude::float8, %%[1]s.latitude::float8), 4326), %d)",
+			grid.Precision)
+		return cell, "ST_Y(ST_PointFromGeoHash(%s))", "ST_X(ST_PointFromGeoHash(%s))", nil
+	case HEATMAP_GRID_H3:
+		if grid.Precision < 0 || grid.Precision > MAX_H3_RESOLUTION {
+			return "", "", "", fmt.Errorf("h3 resolution must be between 0 and %d", MAX_H3_RESOLUTION)
+		}
+		cell := fmt.Sprintf("h3_lat_lng_to_cell(point(%%[1]s.longitude::float8, %%[1]s.latitude::float8), %d)::text",
+			grid.Precision)
+		return cell, "(h3_cell_to_lat_lng(%s::h3index))[1]", "(h3_cell_to_lat_lng(%s::h3index))[0]", nil
+	}
+	return "", "", "", fmt.Errorf("unknown heatmap grid: %s", grid.Type)
+}
+
+// Build the query aggregating location counts (and optionally located measurement values) into
+// grid cells along with its arguments.
+func (api *Api) heatmapQuery(grid HeatmapGrid, measurement *string,
+	criteria LocationSearchCriteria) (string, []interface{}, error) {
+	cell, lat, lon, err := heatmapCellExpressions(grid)
+	if err != nil {
+		return "", nil, err
+	}
+	where, args, err := locationFilters(criteria)
+	if err != nil {
+		return "", nil, err
+	}
+	limit := criteria.MaxResults
+	if limit <= 0 {
+		limit = DEFAULT_HEATMAP_CELLS
+	}
+
+	// Measurements are aggregated by their own position.
+	measurements := "SELECT NULL::text AS cell, NULL::float8 AS average, 0::bigint AS count WHERE false"
+	if measurement != nil {
+		mwhere, margs, err := spatialFilters("m", `m.latitude BETWEEN -90 AND 90
+		AND m.longitude BETWEEN -180 AND 180`, criteria)
+		if err != nil {
+			return "", nil, err
+		}
+		measurements = fmt.Sprintf(`SELECT %s AS cell, avg(m.value) AS average, count(*) AS count
+	FROM %s m
+	JOIN %s e ON e.device_id = m.device_id AND e.event_type = m.event_type AND e.occurred_time = m.occurred_time
+	WHERE %s AND m.name = ? GROUP BY 1`, fmt.Sprintf(cell, "m"),
+			api.qualified(HYPERTABLE_MEASUREMENT_EVENTS), api.qualified(HYPERTABLE_EVENTS), mwhere)
+		args = append(args, margs...)
+		args = append(args, *measurement)
+	}
+
+	query := fmt.Sprintf(`WITH locations AS (
+	SELECT %s AS cell, count(*) AS count
+	FROM %s l
+	JOIN %s e ON e.device_id = l.device_id AND e.event_type = l.event_type AND e.occurred_time = l.occurred_time
+	WHERE %s GROUP BY 1
+), measurements AS (
+	%s
+), cells AS (
+	SELECT COALESCE(loc.cell, mx.cell) AS cell, COALESCE(loc.count, 0) AS count,
+		mx.average AS measurement_average, COALESCE(mx.count, 0) AS measurement_count
+	FROM locations loc FULL JOIN measurements mx ON mx.cell = loc.cell
+)
+SELECT cell, %s AS latitude, %s AS longitude, count, measurement_average, measurement_count
+FROM cells ORDER BY count DESC, measurement_count DESC, cell LIMIT ?`, fmt.Sprintf(cell, "l"),
+		api.qualified(HYPERTABLE_LOCATION_EVENTS), api.qualified(HYPERTABLE_EVENTS), where,
+		measurements, fmt.Sprintf(lat, "cell"), fmt.Sprintf(lon, "cell"))
+	args = append(args, limit)
+	return query, args, nil
+}
+
+// Aggregate location counts into grid cells for a time range. If a measurement name is given,
+// the average value of located measurements with that name is included for each cell. Cells are
+// returned with the highest counts first.
+func (api *Api) LocationHeatmap(ctx context.Context, grid HeatmapGrid, measurement *string,
+	criteria LocationSearchCriteria) ([]HeatmapCell, error) {
+	query, args, err := api.heatmapQuery(grid, measurement, criteria)
+	if err != nil {
+		return nil, err
+	}
+	if grid.Type == HEATMAP_GRID_H3 {
+		var installed bool
+		err := api.RDB.Database.WithContext(ctx).
+			Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'h3')").Scan(&installed).Error
+		if err != nil {
+			return nil, err
+		}
+		if !installed {
+			return nil, fmt.Errorf("h3 grids require the h3 extension to be installed")
+		}
+	}
+
+	found := make([]HeatmapCell, 0)
+	result := api.RDB.Database.WithContext(ctx).Raw(query, args...).Scan(&found)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
@@ -0,0 +1,89 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devicechain-io/dc-microservice/core"
+	"github.com/devicechain-io/dc-microservice/rdb"
+	"github.com/stretchr/testify/assert"
+)
+
+// Create an api that builds queries without a database.
+func newQueryApi() *Api {
+	return NewApi(&rdb.RdbManager{Microservice: &core.Microservice{FunctionalArea: "event-management"}})
+}
+
+// Criteria for a one day heatmap.
+func heatmapCriteria() LocationSearchCriteria {
+	return LocationSearchCriteria{
+		StartTime: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2022, 6, 2, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// Test heatmap queries for geohash cells without a measurement.
+func TestHeatmapQueryGeohash(t *testing.T) {
+	api := newQueryApi()
+	query, args, err := api.heatmapQuery(HeatmapGrid{Type: HEATMAP_GRID_GEOHASH, Precision: 6}, nil, heatmapCriteria())
+	assert.Nil(t, err)
+	assert.Contains(t, query, "ST_GeoHash(ST_SetSRID(ST_MakePoint(l.longitude::float8, l.latitude::float8), 4326), 6) AS cell")
+	assert.Contains(t, query, "ST_Y(ST_PointFromGeoHash(cell)) AS latitude, ST_X(ST_PointFromGeoHash(cell)) AS longitude")
+	assert.Contains(t, query, `FROM "event-management"."location_events" l`)
+	assert.Contains(t, query, "l.suspect_reason IS NULL")
+
+	// Without a measurement the measurements set is empty and not filtered.
+	assert.Contains(t, query, "SELECT NULL::text AS cell, NULL::float8 AS average, 0::bigint AS count WHERE false")
+	assert.NotContains(t, query, "measurement_events")
+	assert.Equal(t, []interface{}{heatmapCriteria().StartTime, heatmapCriteria().EndTime, DEFAULT_HEATMAP_CELLS}, args)
+
+	// Precision is bounded.
+	_, _, err = api.heatmapQuery(HeatmapGrid{Type: HEATMAP_GRID_GEOHASH, Precision: 0}, nil, heatmapCriteria())
+	assert.NotNil(t, err)
+	_, _, err = api.heatmapQuery(HeatmapGrid{Type: HEATMAP_GRID_GEOHASH, Precision: MAX_GEOHASH_PRECISION + 1}, nil, heatmapCriteria())
+	assert.NotNil(t, err)
+}
+
+// Test heatmap queries for h3 cells with a measurement.
+func TestHeatmapQueryH3(t *testing.T) {
+	api := newQueryApi()
+	name := "temperature"
+	criteria := heatmapCriteria()
+	criteria.DeviceIds = []uint{4}
+	criteria.MaxResults = 50
+	query, args, err := api.heatmapQuery(HeatmapGrid{Type: HEATMAP_GRID_H3, Precision: 7}, &name, criteria)
+	assert.Nil(t, err)
+	assert.Contains(t, query, "h3_lat_lng_to_cell(point(l.longitude::float8, l.latitude::float8), 7)::text AS cell")
+	assert.Contains(t, query, "h3_lat_lng_to_cell(point(m.longitude::float8, m.latitude::float8), 7)::text AS cell")
+	assert.Contains(t, query, "(h3_cell_to_lat_lng(cell::h3index))[1] AS latitude")
+	assert.Contains(t, query, `FROM "event-management"."measurement_events" m`)
+	assert.Contains(t, query, "m.suspect_reason IS NULL")
+	assert.Equal(t, 1, strings.Count(query, "m.name = ?"))
+
+	// Location filters come first, then measurement filters, name and limit.
+	assert.Equal(t, []interface{}{criteria.StartTime, criteria.EndTime, []uint{4},
+		criteria.StartTime, criteria.EndTime, []uint{4}, name, 50}, args)
+
+	// Resolution is bounded and unknown grids are rejected.
+	_, _, err = api.heatmapQuery(HeatmapGrid{Type: HEATMAP_GRID_H3, Precision: MAX_H3_RESOLUTION + 1}, nil, criteria)
+	assert.NotNil(t, err)
+	_, _, err = api.heatmapQuery(HeatmapGrid{Type: "S2", Precision: 1}, nil, criteria)
+	assert.NotNil(t, err)
+}
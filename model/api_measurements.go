@@ -0,0 +1,143 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devicechain-io/dc-microservice/rdb"
+	"gorm.io/gorm"
+)
+
+// Validate a measurement definition request and apply it to the entity.
+func applyMeasurementDefinition(definition *MeasurementDefinition, request *MeasurementDefinitionCreateRequest) error {
+	switch request.DataType {
+	case MEASUREMENT_TYPE_FLOAT, MEASUREMENT_TYPE_INTEGER, MEASUREMENT_TYPE_BOOLEAN:
+	default:
+		return fmt.Errorf("unknown measurement data type: %s", request.DataType)
+	}
+	if request.MinValue != nil && request.MaxValue != nil && *request.MinValue > *request.MaxValue {
+		return fmt.Errorf("measurement minimum must not be greater than maximum")
+	}
+	if request.Precision != nil && *request.Precision < 0 {
+		return fmt.Errorf("measurement display precision must not be negative")
+	}
+	definition.Token = request.Token
+	definition.Name = rdb.NullStrOf(request.Name)
+	definition.Description = rdb.NullStrOf(request.Description)
+	definition.Metadata = rdb.MetadataStrOf(request.Metadata)
+	definition.Unit = rdb.NullStrOf(request.Unit)
+	definition.DataType = request.DataType
+	definition.MinValue = rdb.NullFloat64Of(request.MinValue)
+	definition.MaxValue = rdb.NullFloat64Of(request.MaxValue)
+	definition.Precision = rdb.NullInt64Of(request.Precision)
+	return nil
+}
+
+// Create a new measurement definition.
+func (api *Api) CreateMeasurementDefinition(ctx context.Context,
+	request *MeasurementDefinitionCreateRequest) (*MeasurementDefinition, error) {
+	created := &MeasurementDefinition{}
+	err := applyMeasurementDefinition(created, request)
+	if err != nil {
+		return nil, err
+	}
+	result := api.RDB.Database.Create(created)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return created, nil
+}
+
+// Update an existing measurement definition.
+func (api *Api) UpdateMeasurementDefinition(ctx context.Context, token string,
+	request *MeasurementDefinitionCreateRequest) (*MeasurementDefinition, error) {
+	matches, err := api.MeasurementDefinitionsByToken(ctx, []string{token})
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	updated := matches[0]
+	err = applyMeasurementDefinition(updated, request)
+	if err != nil {
+		return nil, err
+	}
+	result := api.RDB.Database.Save(updated)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return updated, nil
+}
+
+// Delete an existing measurement definition.
+func (api *Api) DeleteMeasurementDefinition(ctx context.Context, token string) (*MeasurementDefinition, error) {
+	matches, err := api.MeasurementDefinitionsByToken(ctx, []string{token})
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	deleted := matches[0]
+	result := api.RDB.Database.Delete(deleted)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return deleted, nil
+}
+
+// Get measurement definitions by token.
+func (api *Api) MeasurementDefinitionsByToken(ctx context.Context, tokens []string) ([]*MeasurementDefinition, error) {
+	found := make([]*MeasurementDefinition, 0)
+	result := api.RDB.Database.Find(&found, "token in ?", tokens)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
+
+// Search for measurement definitions that meet criteria.
+func (api *Api) MeasurementDefinitions(ctx context.Context,
+	criteria MeasurementDefinitionSearchCriteria) (*MeasurementDefinitionSearchResults, error) {
+	results := make([]MeasurementDefinition, 0)
+	db, pag := api.RDB.ListOf(&MeasurementDefinition{}, nil, criteria.Pagination)
+	db.Find(&results)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+
+	// Wrap as search results.
+	return &MeasurementDefinitionSearchResults{
+		Results:    results,
+		Pagination: pag,
+	}, nil
+}
+
+// Get all measurement definitions used when validating measurement events.
+func (api *Api) AllMeasurementDefinitions(ctx context.Context) ([]MeasurementDefinition, error) {
+	found := make([]MeasurementDefinition, 0)
+	result := api.RDB.Database.WithContext(ctx).Find(&found)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
@@ -0,0 +1,393 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const (
+	PARTITION_MIGRATION_BATCH = 24 * time.Hour // Time range of rows copied per batch when rebuilding a hypertable
+)
+
+// Settings for automatic chunk interval tuning. Empty values fall back to defaults
+// computed by the tuning procedure.
+type ChunkTuningSettings struct {
+	TargetBytes      int64
+	MinInterval      string
+	MaxInterval      string
+	ScheduleInterval string
+}
+
+// Apply hash partitioning on device id to event hypertables. TimescaleDB only allows new
+// dimensions on hypertables without chunks, so hypertables that already hold data cause an error
+// unless migration is requested, in which case they are rebuilt with device partitioning (see
+// MigrateDevicePartitioning). Where the dimension exists, the number of partitions is adjusted
+// and applies to new chunks.
+func (api *Api) SyncDevicePartitioning(ctx context.Context, partitions int32, migrate bool) error {
+	db := api.RDB.Database.WithContext(ctx)
+	schema := api.RDB.Microservice.FunctionalArea
+	for _, hypertable := range EventHypertables {
+		existing := make([]int32, 0)
+		result := db.Raw(`SELECT num_partitions FROM timescaledb_information.dimensions
+WHERE hypertable_schema = ? AND hypertable_name = ? AND column_name = 'device_id'`, schema, hypertable).Scan(&existing)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if len(existing) > 0 {
+			if partitions < 1 {
+				log.Warn().Str("hypertable", hypertable).Msg("Device partitioning can not be removed once added.")
+				continue
+			}
+			if existing[0] != partitions {
+				err := db.Exec("SELECT set_number_partitions(?::regclass, ?, 'device_id')",
+					api.qualified(hypertable), partitions).Error
+				if err != nil {
+					return err
+				}
+				log.Info().Str("hypertable", hypertable).Int32("partitions", partitions).
+					Msg("Updated number of device partitions.")
+			}
+			continue
+		}
+		if partitions < 1 {
+			continue
+		}
+
+		chunks := int64(0)
+		result = db.Raw(`SELECT count(*) FROM timescaledb_information.chunks
+WHERE hypertable_schema = ? AND hypertable_name = ?`, schema, hypertable).Scan(&chunks)
+		if result.Error != nil {
+			return result.Error
+		}
+		if chunks > 0 {
+			if !migrate {
+				return fmt.Errorf("hypertable '%s' already has %d chunks; enable partition migration to rebuild it with device partitioning",
+					hypertable, chunks)
+			}
+			err := api.MigrateDevicePartitioning(ctx, hypertable, partitions)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err := db.Exec("SELECT add_dimension(?::regclass, 'device_id', number_partitions => ?)",
+			api.qualified(hypertable), partitions).Error
+		if err != nil {
+			return err
+		}
+		log.Info().Str("hypertable", hypertable).Int32("partitions", partitions).Msg("Added device partitioning.")
+	}
+	return nil
+}
+
+// Range of time covered by a batch of copied rows.
+type timeWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Split the time range between the first and last row into batches aligned on the batch width (so
+// that they match time buckets of the same width).
+func migrationBatches(first time.Time, last time.Time, batch time.Duration) []timeWindow {
+	batches := make([]timeWindow, 0)
+	for start := first.UTC().Truncate(batch); !start.After(last); start = start.Add(batch) {
+		batches = append(batches, timeWindow{Start: start, End: start.Add(batch)})
+	}
+	return batches
+}
+
+// Times of the first and last row in a hypertable.
+type hypertableTimeRange struct {
+	FirstTime sql.NullTime
+	LastTime  sql.NullTime
+}
+
+// Build statements that add the primary key, unique constraints and indexes of a hypertable (as
+// reported by pg_get_constraintdef and pg_get_indexdef) to the table replacing it. The index on
+// time alone is skipped since it is added when the hypertable is created.
+func stagingIndexStatements(qualified string, constraints []string, indexes []string) []string {
+	statements := make([]string, 0)
+	for _, constraint := range constraints {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD %s", qualified, constraint))
+	}
+	for _, index := range indexes {
+		using := strings.Index(index, " USING ")
+		if using < 0 || strings.HasSuffix(index, "(occurred_time DESC)") {
+			continue
+		}
+		create := "CREATE INDEX"
+		if strings.HasPrefix(index, "CREATE UNIQUE INDEX") {
+			create = "CREATE UNIQUE INDEX"
+		}
+		statements = append(statements, fmt.Sprintf("%s ON %s%s", create, qualified, index[using:]))
+	}
+	return statements
+}
+
+// Build the query finding windows of PARTITION_MIGRATION_BATCH in which the number of rows differs
+// between a hypertable and its replacement.
+func changedWindowsQuery(original string, staging string) string {
+	counts := "SELECT time_bucket(?::interval, occurred_time) AS start, count(*) AS rows FROM %s GROUP BY 1"
+	return fmt.Sprintf(`SELECT COALESCE(o.start, s.start) AS start
+FROM (%s) o FULL JOIN (%s) s ON s.start = o.start
+WHERE o.rows IS DISTINCT FROM s.rows ORDER BY 1`, fmt.Sprintf(counts, original), fmt.Sprintf(counts, staging))
+}
+
+// Rebuild a hypertable that already holds chunks with hash partitioning on device id. A new
+// hypertable with the same columns, indexes and chunk interval is created and rows are copied in
+// batches of PARTITION_MIGRATION_BATCH while the original stays writable. Writes are then blocked
+// and rows written meanwhile are reconciled: row counts of the two tables are compared per batch
+// window and windows that differ are copied again (event rows are only ever inserted, or removed
+// with whole chunks by retention). The new hypertable then replaces the original. Measurement
+// rollups depend on raw measurements, so they are recreated and refreshed over all data. Policies
+// are dropped with the original and are applied again by the policy sync that follows partitioning.
+//
+// Disk space is needed for an uncompressed copy of the data until the original is dropped.
+func (api *Api) MigrateDevicePartitioning(ctx context.Context, hypertable string, partitions int32) error {
+	db := api.RDB.Database.WithContext(ctx)
+	schema := api.RDB.Microservice.FunctionalArea
+	staging := hypertable + "_partitioned"
+	replaced := hypertable + "_unpartitioned"
+
+	// Generated columns are computed by the new hypertable.
+	names := make([]string, 0)
+	result := db.Raw(`SELECT column_name FROM information_schema.columns
+WHERE table_schema = ? AND table_name = ? AND is_generated = 'NEVER' ORDER BY ordinal_position`, schema, hypertable).Scan(&names)
+	if result.Error != nil {
+		return result.Error
+	}
+	quoted := make([]string, 0)
+	for _, name := range names {
+		quoted = append(quoted, fmt.Sprintf("\"%s\"", name))
+	}
+	columns := strings.Join(quoted, ", ")
+	copyRows := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s WHERE occurred_time >= ? AND occurred_time < ?",
+		api.qualified(staging), columns, columns, api.qualified(hypertable))
+	clearRows := fmt.Sprintf("DELETE FROM %s WHERE occurred_time >= ? AND occurred_time < ?", api.qualified(staging))
+
+	// Keys and indexes are added to the new hypertable once rows are copied.
+	constraints := make([]string, 0)
+	result = db.Raw(`SELECT pg_get_constraintdef(oid) FROM pg_constraint
+WHERE conrelid = ?::regclass AND contype IN ('p', 'u')`, api.qualified(hypertable)).Scan(&constraints)
+	if result.Error != nil {
+		return result.Error
+	}
+	indexes := make([]string, 0)
+	result = db.Raw(`SELECT pg_get_indexdef(i.indexrelid) FROM pg_index i
+WHERE i.indrelid = ?::regclass AND NOT EXISTS (SELECT 1 FROM pg_constraint c WHERE c.conindid = i.indexrelid)`,
+		api.qualified(hypertable)).Scan(&indexes)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	interval := ""
+	result = db.Raw(`SELECT time_interval::text FROM timescaledb_information.dimensions
+WHERE hypertable_schema = ? AND hypertable_name = ? AND dimension_type = 'Time'`, schema, hypertable).Scan(&interval)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	// Create the partitioned hypertable (replacing any left by an earlier attempt).
+	err := db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", api.qualified(staging))).Error
+	if err != nil {
+		return err
+	}
+	err = db.Exec(fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING ALL EXCLUDING INDEXES)",
+		api.qualified(staging), api.qualified(hypertable))).Error
+	if err != nil {
+		return err
+	}
+	err = db.Exec(`SELECT create_hypertable(?::regclass, 'occurred_time', partitioning_column => 'device_id',
+	number_partitions => ?, chunk_time_interval => ?::interval)`, api.qualified(staging), partitions, interval).Error
+	if err != nil {
+		return err
+	}
+
+	// Copy rows while the original is still writable.
+	span := hypertableTimeRange{}
+	result = db.Raw(fmt.Sprintf("SELECT min(occurred_time) AS first_time, max(occurred_time) AS last_time FROM %s",
+		api.qualified(hypertable))).Scan(&span)
+	if result.Error != nil {
+		return result.Error
+	}
+	if span.FirstTime.Valid {
+		batches := migrationBatches(span.FirstTime.Time, span.LastTime.Time, PARTITION_MIGRATION_BATCH)
+		for i, batch := range batches {
+			err = db.Exec(copyRows, batch.Start, batch.End).Error
+			if err != nil {
+				return err
+			}
+			log.Info().Str("hypertable", hypertable).Int("batch", i+1).Int("batches", len(batches)).
+				Msg("Copied rows to partitioned hypertable.")
+		}
+	}
+	for _, statement := range stagingIndexStatements(api.qualified(staging), constraints, indexes) {
+		err = db.Exec(statement).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	// Copy rows written during the copy again and swap tables while writes are blocked.
+	err = db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			fmt.Sprintf("LOCK TABLE %s IN EXCLUSIVE MODE", api.qualified(hypertable)),
+			fmt.Sprintf("ALTER TABLE %s RENAME TO \"%s\"", api.qualified(hypertable), replaced),
+			fmt.Sprintf("ALTER TABLE %s RENAME TO \"%s\"", api.qualified(staging), hypertable),
+			fmt.Sprintf("DROP TABLE %s CASCADE", api.qualified(replaced)),
+		}
+		err := tx.Exec(statements[0]).Error
+		if err != nil {
+			return err
+		}
+		changed := make([]time.Time, 0)
+		window := asInterval(PARTITION_MIGRATION_BATCH)
+		result := tx.Raw(changedWindowsQuery(api.qualified(hypertable), api.qualified(staging)), window, window).Scan(&changed)
+		if result.Error != nil {
+			return result.Error
+		}
+		for _, start := range changed {
+			end := start.Add(PARTITION_MIGRATION_BATCH)
+			err = tx.Exec(clearRows, start, end).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Exec(copyRows, start, end).Error
+			if err != nil {
+				return err
+			}
+		}
+		log.Info().Str("hypertable", hypertable).Int("windows", len(changed)).Msg("Copied rows written during copy.")
+		for _, statement := range statements[1:] {
+			err = tx.Exec(statement).Error
+			if err != nil {
+				return err
+			}
+		}
+		if hypertable == HYPERTABLE_MEASUREMENT_EVENTS {
+			for _, statement := range measurementRollupStatements(measurementRollupFilter) {
+				err = tx.Exec(statement).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Continuous aggregates can not be refreshed inside a transaction.
+	if hypertable == HYPERTABLE_MEASUREMENT_EVENTS {
+		for _, rollup := range MeasurementRollups {
+			err = db.Exec("CALL refresh_continuous_aggregate(?::regclass, NULL, NULL)", api.qualified(rollup.View)).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	log.Info().Str("hypertable", hypertable).Int32("partitions", partitions).Msg("Rebuilt hypertable with device partitioning.")
+	return nil
+}
+
+// Tuning job registered for a hypertable.
+type chunkTuningJob struct {
+	JobId      int32
+	Hypertable string
+}
+
+// Register (or update) jobs that tune chunk intervals for event hypertables. Passing nil
+// settings removes the jobs.
+func (api *Api) SyncChunkTuning(ctx context.Context, settings *ChunkTuningSettings) error {
+	db := api.RDB.Database.WithContext(ctx)
+	schema := api.RDB.Microservice.FunctionalArea
+
+	existing := make([]chunkTuningJob, 0)
+	result := db.Raw(`SELECT job_id, config->>'hypertable_name' AS hypertable FROM timescaledb_information.jobs
+WHERE proc_schema = ? AND proc_name = ?`, schema, CHUNK_TUNING_PROCEDURE).Scan(&existing)
+	if result.Error != nil {
+		return result.Error
+	}
+	jobs := make(map[string]int32)
+	for _, job := range existing {
+		jobs[job.Hypertable] = job.JobId
+	}
+
+	if settings == nil {
+		for hypertable, id := range jobs {
+			err := db.Exec("SELECT delete_job(?)", id).Error
+			if err != nil {
+				return err
+			}
+			log.Info().Str("hypertable", hypertable).Msg("Removed chunk tuning job.")
+		}
+		return nil
+	}
+
+	schedule := settings.ScheduleInterval
+	if schedule == "" {
+		schedule = "1 hour"
+	}
+	for _, hypertable := range EventHypertables {
+		config := map[string]interface{}{
+			"hypertable_schema": schema,
+			"hypertable_name":   hypertable,
+			"share":             len(EventHypertables),
+		}
+		if settings.TargetBytes > 0 {
+			config["target_bytes"] = settings.TargetBytes
+		}
+		if settings.MinInterval != "" {
+			config["min_interval"] = settings.MinInterval
+		}
+		if settings.MaxInterval != "" {
+			config["max_interval"] = settings.MaxInterval
+		}
+		jconfig, err := json.Marshal(config)
+		if err != nil {
+			return err
+		}
+
+		if id, found := jobs[hypertable]; found {
+			err = db.Exec("SELECT alter_job(?, schedule_interval => ?::interval, config => ?::jsonb)",
+				id, schedule, string(jconfig)).Error
+		} else {
+			proc := api.qualified(CHUNK_TUNING_PROCEDURE)
+			err = db.Exec("SELECT add_job(?::regproc, ?::interval, config => ?::jsonb)",
+				proc, schedule, string(jconfig)).Error
+			if err == nil {
+				log.Info().Str("hypertable", hypertable).Msg("Added chunk tuning job.")
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
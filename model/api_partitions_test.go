@@ -0,0 +1,74 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test splitting rows copied during partition migration into batches.
+func TestMigrationBatches(t *testing.T) {
+	first := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	// Rows within a single batch. Batches are aligned on the batch width.
+	day := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	batches := migrationBatches(first, first.Add(time.Hour), PARTITION_MIGRATION_BATCH)
+	assert.Equal(t, 1, len(batches))
+	assert.Equal(t, day, batches[0].Start)
+	assert.Equal(t, day.Add(PARTITION_MIGRATION_BATCH), batches[0].End)
+
+	// Batches are contiguous and cover the last row.
+	last := first.Add(50 * time.Hour)
+	batches = migrationBatches(first, last, PARTITION_MIGRATION_BATCH)
+	assert.Equal(t, 3, len(batches))
+	for i := 1; i < len(batches); i++ {
+		assert.Equal(t, batches[i-1].End, batches[i].Start)
+	}
+	assert.True(t, batches[2].End.After(last))
+
+	// A single row still produces a batch.
+	batches = migrationBatches(first, first, PARTITION_MIGRATION_BATCH)
+	assert.Equal(t, 1, len(batches))
+}
+
+// Test keys and indexes are copied to the replacement hypertable except for the time index.
+func TestStagingIndexStatements(t *testing.T) {
+	table := `"event-management"."events_partitioned"`
+	statements := stagingIndexStatements(table,
+		[]string{"PRIMARY KEY (device_id, event_type, occurred_time)"},
+		[]string{
+			`CREATE INDEX events_occurred_time_idx ON "event-management".events USING btree (occurred_time DESC)`,
+			`CREATE INDEX events_device_id_occurred_time_idx ON "event-management".events USING btree (device_id, occurred_time DESC)`,
+			`CREATE UNIQUE INDEX events_alt_id_idx ON "event-management".events USING btree (alt_id)`,
+		})
+	assert.Equal(t, []string{
+		`ALTER TABLE "event-management"."events_partitioned" ADD PRIMARY KEY (device_id, event_type, occurred_time)`,
+		`CREATE INDEX ON "event-management"."events_partitioned" USING btree (device_id, occurred_time DESC)`,
+		`CREATE UNIQUE INDEX ON "event-management"."events_partitioned" USING btree (alt_id)`,
+	}, statements)
+}
+
+// Test windows with rows written during the copy are found by comparing row counts.
+func TestChangedWindowsQuery(t *testing.T) {
+	query := changedWindowsQuery(`"event-management"."events"`, `"event-management"."events_partitioned"`)
+	assert.Contains(t, query, `FROM "event-management"."events" GROUP BY 1) o FULL JOIN`)
+	assert.Contains(t, query, `FROM "event-management"."events_partitioned" GROUP BY 1) s`)
+	assert.Contains(t, query, "o.rows IS DISTINCT FROM s.rows")
+}
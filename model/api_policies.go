@@ -0,0 +1,205 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Describes how a kind of timescaledb policy job is queried and managed.
+type policyType struct {
+	Name      string
+	Proc      string
+	ConfigKey string
+	Add       string
+	Remove    string
+}
+
+var retentionPolicy = policyType{
+	Name:      "retention",
+	Proc:      "policy_retention",
+	ConfigKey: "drop_after",
+	Add:       "SELECT add_retention_policy(?::regclass, ?::interval, if_not_exists => true)",
+	Remove:    "SELECT remove_retention_policy(?::regclass, if_exists => true)",
+}
+
+var compressionPolicy = policyType{
+	Name:      "compression",
+	Proc:      "policy_compression",
+	ConfigKey: "compress_after",
+	Add:       "SELECT add_compression_policy(?::regclass, ?::interval, if_not_exists => true)",
+	Remove:    "SELECT remove_compression_policy(?::regclass, if_exists => true)",
+}
+
+// Get policy jobs of the given type for hypertables and continuous aggregates in this functional area.
+func (api *Api) policies(ctx context.Context, ptype policyType) ([]HypertablePolicy, error) {
+	query := `SELECT j.job_id, COALESCE(c.view_name, j.hypertable_name) AS hypertable,
+	j.config->>? AS interval, j.schedule_interval::text AS schedule_interval, j.next_start
+FROM timescaledb_information.jobs j
+LEFT JOIN timescaledb_information.continuous_aggregates c
+	ON c.materialization_hypertable_schema = j.hypertable_schema
+	AND c.materialization_hypertable_name = j.hypertable_name
+WHERE j.proc_name = ? AND COALESCE(c.view_schema, j.hypertable_schema) = ?
+ORDER BY 2`
+
+	found := make([]HypertablePolicy, 0)
+	result := api.RDB.Database.WithContext(ctx).Raw(query, ptype.ConfigKey, ptype.Proc,
+		api.RDB.Microservice.FunctionalArea).Scan(&found)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
+
+// Indicates whether two postgres interval literals are equivalent.
+func (api *Api) sameInterval(ctx context.Context, first string, second string) (bool, error) {
+	same := false
+	result := api.RDB.Database.WithContext(ctx).Raw("SELECT ?::interval = ?::interval", first, second).Scan(&same)
+	return same, result.Error
+}
+
+// Synchronize policy jobs of the given type with desired settings. Policies are only replaced
+// when the configured interval differs from the one currently applied.
+func (api *Api) syncPolicies(ctx context.Context, ptype policyType, settings []PolicySetting) error {
+	existing, err := api.policies(ctx, ptype)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]HypertablePolicy)
+	for _, policy := range existing {
+		current[policy.Hypertable] = policy
+	}
+
+	db := api.RDB.Database.WithContext(ctx)
+	for _, setting := range settings {
+		table := api.qualified(setting.Hypertable)
+		policy, found := current[setting.Hypertable]
+		if found && setting.Interval != nil {
+			same, err := api.sameInterval(ctx, policy.Interval, *setting.Interval)
+			if err != nil {
+				return err
+			}
+			if same {
+				continue
+			}
+		}
+		if found {
+			err := db.Exec(ptype.Remove, table).Error
+			if err != nil {
+				return err
+			}
+			log.Info().Str("hypertable", setting.Hypertable).Msgf("Removed %s policy.", ptype.Name)
+		}
+		if setting.Interval != nil {
+			err := db.Exec(ptype.Add, table, *setting.Interval).Error
+			if err != nil {
+				return err
+			}
+			log.Info().Str("hypertable", setting.Hypertable).Str("interval", *setting.Interval).
+				Msgf("Applied %s policy.", ptype.Name)
+		}
+	}
+	return nil
+}
+
+// Get retention policies for hypertables and continuous aggregates in this functional area.
+func (api *Api) RetentionPolicies(ctx context.Context) ([]HypertablePolicy, error) {
+	return api.policies(ctx, retentionPolicy)
+}
+
+// Synchronize retention policies with desired settings.
+func (api *Api) SyncRetentionPolicies(ctx context.Context, settings []PolicySetting) error {
+	return api.syncPolicies(ctx, retentionPolicy, settings)
+}
+
+// Get compression policies for hypertables in this functional area.
+func (api *Api) CompressionPolicies(ctx context.Context) ([]HypertablePolicy, error) {
+	return api.policies(ctx, compressionPolicy)
+}
+
+// Columns used to segment and order compressed rows for a hypertable.
+type compressionSetting struct {
+	SegmentBy string
+	OrderBy   string
+}
+
+// Compression settings for event hypertables. Rows are segmented by the columns (beyond time)
+// that make up the key of the table or that queries filter series on, newest first.
+var eventCompressionSettings = map[string]compressionSetting{
+	HYPERTABLE_EVENTS:             {SegmentBy: "device_id, event_type", OrderBy: "occurred_time DESC"},
+	HYPERTABLE_LOCATION_EVENTS:    {SegmentBy: "device_id", OrderBy: "occurred_time DESC"},
+	HYPERTABLE_MEASUREMENT_EVENTS: {SegmentBy: "device_id, name", OrderBy: "occurred_time DESC"},
+	HYPERTABLE_ALERT_EVENTS:       {SegmentBy: "device_id", OrderBy: "occurred_time DESC"},
+	HYPERTABLE_GEOFENCE_EVENTS:    {SegmentBy: "device_id, geofence_id", OrderBy: "occurred_time DESC"},
+	HYPERTABLE_ANOMALY_EVENTS:     {SegmentBy: "device_id, name", OrderBy: "occurred_time DESC"},
+}
+
+// Build the statement enabling native compression on a hypertable.
+func (api *Api) enableCompressionStatement(hypertable string) string {
+	setting := eventCompressionSettings[hypertable]
+	return `ALTER TABLE ` + api.qualified(hypertable) + ` SET (timescaledb.compress,
+	timescaledb.compress_segmentby = '` + setting.SegmentBy + `', timescaledb.compress_orderby = '` + setting.OrderBy + `')`
+}
+
+// Enable native compression on event hypertables (see eventCompressionSettings) and synchronize
+// compression policies. A nil interval removes the policy but leaves chunks that are already
+// compressed untouched. Settings are only applied when compression is first enabled.
+func (api *Api) SyncCompressionPolicies(ctx context.Context, compressAfter *string) error {
+	settings := make([]PolicySetting, 0)
+	db := api.RDB.Database.WithContext(ctx)
+	for _, hypertable := range EventHypertables {
+		if compressAfter != nil {
+			enabled := false
+			result := db.Raw(`SELECT compression_enabled FROM timescaledb_information.hypertables
+WHERE hypertable_schema = ? AND hypertable_name = ?`, api.RDB.Microservice.FunctionalArea, hypertable).Scan(&enabled)
+			if result.Error != nil {
+				return result.Error
+			}
+			if !enabled {
+				err := db.Exec(api.enableCompressionStatement(hypertable)).Error
+				if err != nil {
+					return err
+				}
+				log.Info().Str("hypertable", hypertable).Msg("Enabled native compression.")
+			}
+		}
+		settings = append(settings, PolicySetting{Hypertable: hypertable, Interval: compressAfter})
+	}
+	return api.syncPolicies(ctx, compressionPolicy, settings)
+}
+
+// Get compression statistics for event hypertables.
+func (api *Api) CompressionStats(ctx context.Context) ([]CompressionStats, error) {
+	found := make([]CompressionStats, 0)
+	for _, hypertable := range EventHypertables {
+		stats := CompressionStats{Hypertable: hypertable}
+		result := api.RDB.Database.WithContext(ctx).Raw(`SELECT
+	COALESCE(sum(total_chunks), 0) AS total_chunks,
+	COALESCE(sum(number_compressed_chunks), 0) AS compressed_chunks,
+	COALESCE(sum(before_compression_total_bytes), 0) AS before_bytes,
+	COALESCE(sum(after_compression_total_bytes), 0) AS after_bytes
+FROM hypertable_compression_stats(?::regclass)`, api.qualified(hypertable)).Scan(&stats)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		stats.Hypertable = hypertable
+		found = append(found, stats)
+	}
+	return found, nil
+}
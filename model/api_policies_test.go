@@ -0,0 +1,39 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test native compression is segmented by the key columns of each event hypertable.
+func TestEnableCompressionStatement(t *testing.T) {
+	api := newQueryApi()
+	for _, hypertable := range EventHypertables {
+		assert.Contains(t, eventCompressionSettings, hypertable)
+	}
+
+	statement := api.enableCompressionStatement(HYPERTABLE_EVENTS)
+	assert.Contains(t, statement, `"event-management"."events"`)
+	assert.Contains(t, statement, "timescaledb.compress_segmentby = 'device_id, event_type'")
+	assert.Contains(t, statement, "timescaledb.compress_orderby = 'occurred_time DESC'")
+
+	statement = api.enableCompressionStatement(HYPERTABLE_MEASUREMENT_EVENTS)
+	assert.Contains(t, statement, "timescaledb.compress_segmentby = 'device_id, name'")
+}
@@ -0,0 +1,212 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devicechain-io/dc-microservice/rdb"
+	"gorm.io/gorm"
+)
+
+// Validate a threshold rule request and apply it to the entity.
+func applyThresholdRule(rule *ThresholdRule, request *ThresholdRuleCreateRequest, units *UnitConverter) error {
+	if request.Measurement == "" {
+		return fmt.Errorf("threshold rule requires a measurement")
+	}
+	if _, ok := RuleOperatorSymbols[request.Operator]; !ok {
+		return fmt.Errorf("unknown threshold rule operator: %s", request.Operator)
+	}
+	if request.Unit != nil {
+		if _, ok := units.Lookup(*request.Unit); !ok {
+			return fmt.Errorf("unknown threshold rule unit: %s", *request.Unit)
+		}
+	}
+	if request.Duration < 0 || request.ClearDuration < 0 {
+		return fmt.Errorf("threshold rule durations must not be negative")
+	}
+	if request.ClearThreshold != nil {
+		switch request.Operator {
+		case RULE_OPERATOR_GREATER_THAN, RULE_OPERATOR_GREATER_OR_EQUAL:
+			if *request.ClearThreshold > request.Threshold {
+				return fmt.Errorf("clear threshold must not be above threshold")
+			}
+		case RULE_OPERATOR_LESS_THAN, RULE_OPERATOR_LESS_OR_EQUAL:
+			if *request.ClearThreshold < request.Threshold {
+				return fmt.Errorf("clear threshold must not be below threshold")
+			}
+		}
+	}
+	rule.Token = request.Token
+	rule.Name = rdb.NullStrOf(request.Name)
+	rule.Description = rdb.NullStrOf(request.Description)
+	rule.Metadata = rdb.MetadataStrOf(request.Metadata)
+	rule.Measurement = request.Measurement
+	rule.Operator = request.Operator
+	rule.Threshold = request.Threshold
+	rule.ClearThreshold = rdb.NullFloat64Of(request.ClearThreshold)
+	rule.Unit = rdb.NullStrOf(request.Unit)
+	rule.Duration = int64(request.Duration.Seconds())
+	rule.ClearDuration = int64(request.ClearDuration.Seconds())
+	rule.DeviceGroupId = request.DeviceGroupId
+	rule.AssetGroupId = request.AssetGroupId
+	rule.CustomerGroupId = request.CustomerGroupId
+	rule.AreaGroupId = request.AreaGroupId
+	rule.AlertType = request.Token
+	if request.AlertType != nil && *request.AlertType != "" {
+		rule.AlertType = *request.AlertType
+	}
+	rule.AlertLevel = request.AlertLevel
+	rule.Enabled = request.Enabled
+	return nil
+}
+
+// Create a new threshold rule.
+func (api *Api) CreateThresholdRule(ctx context.Context, request *ThresholdRuleCreateRequest) (*ThresholdRule, error) {
+	created := &ThresholdRule{}
+	err := applyThresholdRule(created, request, api.Units)
+	if err != nil {
+		return nil, err
+	}
+	result := api.RDB.Database.Create(created)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return created, nil
+}
+
+// Update an existing threshold rule.
+func (api *Api) UpdateThresholdRule(ctx context.Context, token string,
+	request *ThresholdRuleCreateRequest) (*ThresholdRule, error) {
+	matches, err := api.ThresholdRulesByToken(ctx, []string{token})
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	updated := matches[0]
+	err = applyThresholdRule(updated, request, api.Units)
+	if err != nil {
+		return nil, err
+	}
+	result := api.RDB.Database.Save(updated)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return updated, nil
+}
+
+// Delete an existing threshold rule along with device states for it.
+func (api *Api) DeleteThresholdRule(ctx context.Context, token string) (*ThresholdRule, error) {
+	matches, err := api.ThresholdRulesByToken(ctx, []string{token})
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	deleted := matches[0]
+	err = api.RDB.Database.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&ThresholdRuleState{}, "rule_id = ?", deleted.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		return tx.Delete(deleted).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// Get threshold rules by token.
+func (api *Api) ThresholdRulesByToken(ctx context.Context, tokens []string) ([]*ThresholdRule, error) {
+	found := make([]*ThresholdRule, 0)
+	result := api.RDB.Database.Find(&found, "token in ?", tokens)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
+
+// Search for threshold rules that meet criteria.
+func (api *Api) ThresholdRules(ctx context.Context, criteria ThresholdRuleSearchCriteria) (*ThresholdRuleSearchResults, error) {
+	results := make([]ThresholdRule, 0)
+	db, pag := api.RDB.ListOf(&ThresholdRule{}, func(result *gorm.DB) *gorm.DB {
+		if criteria.Measurement != nil {
+			result = result.Where("measurement = ?", *criteria.Measurement)
+		}
+		return result
+	}, criteria.Pagination)
+	db.Find(&results)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+
+	// Wrap as search results.
+	return &ThresholdRuleSearchResults{
+		Results:    results,
+		Pagination: pag,
+	}, nil
+}
+
+// Get enabled threshold rules used when evaluating measurement events.
+func (api *Api) ActiveThresholdRules(ctx context.Context) ([]ThresholdRule, error) {
+	found := make([]ThresholdRule, 0)
+	result := api.RDB.Database.WithContext(ctx).Find(&found, "enabled = ?", true)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
+
+// Get states for a device relative to threshold rules.
+func (api *Api) ThresholdRuleStates(ctx context.Context, deviceId uint) ([]ThresholdRuleState, error) {
+	found := make([]ThresholdRuleState, 0)
+	result := api.RDB.Database.WithContext(ctx).Find(&found, "device_id = ?", deviceId)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
+
+// Save state of a device relative to a threshold rule.
+func (api *Api) SaveThresholdRuleState(ctx context.Context, state *ThresholdRuleState) error {
+	return api.RDB.Database.WithContext(ctx).Save(state).Error
+}
+
+// Create an alert raised or cleared by a threshold rule and save the resulting device state for
+// the rule in a single transaction, so that state never gets out of step with generated alerts.
+func (api *Api) CreateThresholdRuleAlert(ctx context.Context, request *AlertEventCreateRequest,
+	state *ThresholdRuleState) (*AlertEvent, error) {
+	created := alertEventOf(request)
+	err := api.RDB.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Create(created)
+		if result.Error != nil {
+			return result.Error
+		}
+		return tx.Save(state).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
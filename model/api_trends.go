@@ -0,0 +1,262 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Validate criteria for a trend query.
+func validateTrendCriteria(criteria MeasurementTrendCriteria) error {
+	if criteria.Name == "" {
+		return fmt.Errorf("trend requires a measurement name")
+	}
+	if criteria.Bucket <= 0 {
+		return fmt.Errorf("bucket width must be positive")
+	}
+	if !criteria.EndTime.After(criteria.StartTime) {
+		return fmt.Errorf("end time must be after start time")
+	}
+	if criteria.Horizon < 0 {
+		return fmt.Errorf("trend horizon must not be negative")
+	}
+	if criteria.Horizon/criteria.Bucket > MAX_TREND_POINTS {
+		return fmt.Errorf("trends are limited to %d projected values", MAX_TREND_POINTS)
+	}
+	if forecast := criteria.Forecast; forecast != nil {
+		for _, factor := range []float64{forecast.Alpha, forecast.Beta, forecast.Gamma} {
+			if factor < 0 || factor > 1 {
+				return fmt.Errorf("forecast smoothing factors must be between 0 and 1")
+			}
+		}
+		if forecast.SeasonLength < 0 {
+			return fmt.Errorf("forecast season length must not be negative")
+		}
+		if criteria.EndTime.Sub(criteria.StartTime)/criteria.Bucket > MAX_GAPFILL_BUCKETS {
+			return fmt.Errorf("forecasts are limited to %d buckets of history", MAX_GAPFILL_BUCKETS)
+		}
+	}
+	return nil
+}
+
+// Forecast values following a series of evenly spaced values using additive Holt-Winters
+// smoothing (or Holt's linear method without seasonality). At least two seasons of values are
+// required for a seasonal forecast.
+func HoltWintersForecast(series []float64, settings HoltWintersSettings, steps int) ([]float64, error) {
+	m := int(settings.SeasonLength)
+	var level, trend float64
+	var seasonal []float64
+	var start int
+	if m == 0 {
+		if len(series) < 2 {
+			return nil, fmt.Errorf("at least two values are required for a forecast")
+		}
+		level, trend, start = series[0], series[1]-series[0], 1
+	} else {
+		if len(series) < 2*m {
+			return nil, fmt.Errorf("at least two seasons of values are required for a seasonal forecast")
+		}
+		first, second := 0.0, 0.0
+		for i := 0; i < m; i++ {
+			first += series[i]
+			second += series[m+i]
+		}
+		level = first / float64(m)
+		trend = (second/float64(m) - level) / float64(m)
+		seasonal = make([]float64, m)
+		for i := 0; i < m; i++ {
+			seasonal[i] = series[i] - level
+		}
+		start = m
+	}
+
+	// Smooth level, trend and seasonal components over the series.
+	season := func(t int) float64 {
+		if m == 0 {
+			return 0
+		}
+		return seasonal[t%m]
+	}
+	for t := start; t < len(series); t++ {
+		previous := level
+		level = settings.Alpha*(series[t]-season(t)) + (1-settings.Alpha)*(level+trend)
+		trend = settings.Beta*(level-previous) + (1-settings.Beta)*trend
+		if m > 0 {
+			seasonal[t%m] = settings.Gamma*(series[t]-level) + (1-settings.Gamma)*seasonal[t%m]
+		}
+	}
+
+	forecast := make([]float64, steps)
+	for h := 1; h <= steps; h++ {
+		forecast[h-1] = level + float64(h)*trend + season(len(series)-1+h)
+	}
+	return forecast, nil
+}
+
+// Find the first time a series of values starting from a point reaches a threshold, interpolating
+// between values (nil if the threshold is not reached).
+func thresholdCrossing(from TrendPoint, points []TrendPoint, threshold float64) *time.Time {
+	if from.Value == threshold {
+		return &from.Time
+	}
+	rising := from.Value < threshold
+	previous := from
+	for _, point := range points {
+		if (rising && point.Value >= threshold) || (!rising && point.Value <= threshold) {
+			fraction := (threshold - previous.Value) / (point.Value - previous.Value)
+			crossed := previous.Time.Add(time.Duration(fraction * float64(point.Time.Sub(previous.Time))))
+			return &crossed
+		}
+		previous = point
+	}
+	return nil
+}
+
+// Least-squares fit of raw measurement values against time.
+type trendFit struct {
+	Count     int64
+	Slope     *float64
+	Intercept *float64
+	RSquared  *float64
+}
+
+// Fit a least-squares trend to the measurement series of a device, projecting values and the time
+// at which the trend reaches the threshold. Suspect measurements are left out of the fit.
+func (api *Api) MeasurementTrend(ctx context.Context, criteria MeasurementTrendCriteria) (*MeasurementTrend, error) {
+	err := validateTrendCriteria(criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	// Time is measured in seconds relative to the end time so the intercept is the end value.
+	query := fmt.Sprintf(`SELECT regr_count(value, t) AS count, regr_slope(value, t) AS slope,
+	regr_intercept(value, t) AS intercept, regr_r2(value, t) AS r_squared
+FROM (SELECT extract(epoch FROM occurred_time - ?::timestamptz) AS t, value FROM %s
+	WHERE device_id = ? AND name = ? AND occurred_time >= ? AND occurred_time < ? AND suspect_reason IS NULL) m`,
+		api.qualified(HYPERTABLE_MEASUREMENT_EVENTS))
+	fit := trendFit{}
+	result := api.RDB.Database.WithContext(ctx).Raw(query, criteria.EndTime, criteria.DeviceId, criteria.Name,
+		criteria.StartTime, criteria.EndTime).Scan(&fit)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if fit.Slope == nil || fit.Intercept == nil {
+		return nil, fmt.Errorf("at least two measurements at different times are required to fit a trend")
+	}
+
+	trend := &MeasurementTrend{
+		EndTime:   criteria.EndTime,
+		Count:     fit.Count,
+		Slope:     *fit.Slope * time.Hour.Seconds(),
+		EndValue:  *fit.Intercept,
+		RSquared:  fit.RSquared,
+		Projected: make([]TrendPoint, 0),
+	}
+	for offset := criteria.Bucket; offset <= criteria.Horizon; offset += criteria.Bucket {
+		trend.Projected = append(trend.Projected, TrendPoint{
+			Time:  criteria.EndTime.Add(offset),
+			Value: *fit.Intercept + *fit.Slope*offset.Seconds(),
+		})
+	}
+	if criteria.Threshold != nil && *fit.Slope != 0 {
+		seconds := (*criteria.Threshold - *fit.Intercept) / *fit.Slope
+		if seconds >= 0 {
+			crossed := criteria.EndTime.Add(time.Duration(seconds * float64(time.Second)))
+			trend.ThresholdTime = &crossed
+		}
+	}
+
+	if criteria.Forecast != nil {
+		err = api.addMeasurementForecast(ctx, criteria, trend)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return trend, nil
+}
+
+// Average of measurements in a bucket (nil if it could not be interpolated).
+type forecastBucket struct {
+	Bucket time.Time
+	Avg    *float64
+}
+
+// Build the query for interpolated bucket averages used as the forecast series. Suspect
+// measurements are left out as they are for the trend fit.
+func (api *Api) forecastSeriesQuery(criteria MeasurementTrendCriteria) (string, []interface{}) {
+	query := fmt.Sprintf(`SELECT time_bucket_gapfill(?::interval, occurred_time, ?::timestamptz, ?::timestamptz) AS bucket,
+	interpolate(avg(value)) AS avg
+FROM %s WHERE device_id = ? AND name = ? AND occurred_time >= ? AND occurred_time < ? AND suspect_reason IS NULL
+GROUP BY 1 ORDER BY 1`, api.qualified(HYPERTABLE_MEASUREMENT_EVENTS))
+	args := []interface{}{asInterval(criteria.Bucket), criteria.StartTime, criteria.EndTime, criteria.DeviceId,
+		criteria.Name, criteria.StartTime, criteria.EndTime}
+	return query, args
+}
+
+// Get the number of forecast values from the last bucket with measurements up to the horizon
+// after the end time (at most MAX_TREND_POINTS).
+func forecastSteps(criteria MeasurementTrendCriteria, last time.Time) int {
+	steps := criteria.EndTime.Add(criteria.Horizon).Sub(last) / criteria.Bucket
+	if steps > MAX_TREND_POINTS {
+		return MAX_TREND_POINTS
+	}
+	return int(steps)
+}
+
+// Add a Holt-Winters forecast computed from interpolated bucket averages to a trend. Forecast
+// values follow the last bucket with measurements up to the horizon after the end time.
+func (api *Api) addMeasurementForecast(ctx context.Context, criteria MeasurementTrendCriteria,
+	trend *MeasurementTrend) error {
+	query, args := api.forecastSeriesQuery(criteria)
+	buckets := make([]forecastBucket, 0)
+	result := api.RDB.Database.WithContext(ctx).Raw(query, args...).Scan(&buckets)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	// Buckets before the first and after the last measurement can not be interpolated.
+	series := make([]float64, 0)
+	var last TrendPoint
+	for _, bucket := range buckets {
+		if bucket.Avg == nil {
+			if len(series) > 0 {
+				break
+			}
+			continue
+		}
+		series = append(series, *bucket.Avg)
+		last = TrendPoint{Time: bucket.Bucket, Value: *bucket.Avg}
+	}
+
+	forecast, err := HoltWintersForecast(series, *criteria.Forecast, forecastSteps(criteria, last.Time))
+	if err != nil {
+		return err
+	}
+	trend.Forecast = make([]TrendPoint, 0)
+	for i, value := range forecast {
+		trend.Forecast = append(trend.Forecast, TrendPoint{
+			Time:  last.Time.Add(time.Duration(i+1) * criteria.Bucket),
+			Value: value,
+		})
+	}
+	if criteria.Threshold != nil {
+		trend.ForecastThresholdTime = thresholdCrossing(last, trend.Forecast, *criteria.Threshold)
+	}
+	return nil
+}
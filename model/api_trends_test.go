@@ -0,0 +1,110 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Holt-Winters forecasts with and without seasonality.
+func TestHoltWintersForecast(t *testing.T) {
+	// A linear series continues along its trend.
+	forecast, err := HoltWintersForecast([]float64{1, 2, 3, 4, 5, 6}, HoltWintersSettings{Alpha: 0.5, Beta: 0.5}, 3)
+	assert.Nil(t, err)
+	assert.Len(t, forecast, 3)
+	assert.InDelta(t, 7, forecast[0], 1e-9)
+	assert.InDelta(t, 9, forecast[2], 1e-9)
+
+	// A seasonal series repeats its pattern.
+	series := []float64{5, 10, 20, 5, 10, 20, 5, 10, 20, 5}
+	forecast, err = HoltWintersForecast(series, HoltWintersSettings{Alpha: 0.3, Beta: 0.1, Gamma: 0.2, SeasonLength: 3}, 4)
+	assert.Nil(t, err)
+	assert.InDelta(t, 10, forecast[0], 1e-6)
+	assert.InDelta(t, 20, forecast[1], 1e-6)
+	assert.InDelta(t, 5, forecast[2], 1e-6)
+	assert.InDelta(t, 10, forecast[3], 1e-6)
+
+	// Forecasts require enough values.
+	_, err = HoltWintersForecast([]float64{1}, HoltWintersSettings{Alpha: 0.5}, 1)
+	assert.NotNil(t, err)
+	_, err = HoltWintersForecast(series[:5], HoltWintersSettings{SeasonLength: 3}, 1)
+	assert.NotNil(t, err)
+}
+
+// Test finding the time a series reaches a threshold.
+func TestThresholdCrossing(t *testing.T) {
+	start := time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC)
+	from := TrendPoint{Time: start, Value: 100}
+	points := []TrendPoint{
+		{Time: start.Add(time.Hour), Value: 60},
+		{Time: start.Add(2 * time.Hour), Value: 20},
+		{Time: start.Add(3 * time.Hour), Value: -20},
+	}
+
+	// Falling series crossing between values is interpolated.
+	crossed := thresholdCrossing(from, points, 0)
+	assert.NotNil(t, crossed)
+	assert.Equal(t, start.Add(150*time.Minute), *crossed)
+
+	// Rising toward a threshold that is never reached.
+	assert.Nil(t, thresholdCrossing(TrendPoint{Time: start, Value: -50}, points, 70))
+	assert.Equal(t, start, *thresholdCrossing(from, points, 100))
+}
+
+// Test validation of trend criteria.
+func TestMeasurementTrendCriteria(t *testing.T) {
+	start := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	criteria := MeasurementTrendCriteria{Name: "level", StartTime: start, EndTime: start.Add(24 * time.Hour),
+		Bucket: time.Hour, Horizon: 48 * time.Hour}
+	assert.Nil(t, validateTrendCriteria(criteria))
+
+	invalid := criteria
+	invalid.Horizon = -time.Hour
+	assert.NotNil(t, validateTrendCriteria(invalid))
+	invalid = criteria
+	invalid.Horizon = (MAX_TREND_POINTS + 1) * time.Hour
+	assert.NotNil(t, validateTrendCriteria(invalid))
+	invalid = criteria
+	invalid.Forecast = &HoltWintersSettings{Alpha: 1.5}
+	assert.NotNil(t, validateTrendCriteria(invalid))
+	invalid = criteria
+	invalid.Name = ""
+	assert.NotNil(t, validateTrendCriteria(invalid))
+}
+
+// Test the forecast series leaves out suspect measurements and the forecast length is capped.
+func TestMeasurementForecastSeries(t *testing.T) {
+	api := newQueryApi()
+	start := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	criteria := MeasurementTrendCriteria{DeviceId: 1, Name: "level", StartTime: start, EndTime: start.Add(24 * time.Hour),
+		Bucket: time.Hour, Horizon: 48 * time.Hour, Forecast: &HoltWintersSettings{Alpha: 0.5}}
+	query, args := api.forecastSeriesQuery(criteria)
+	assert.Contains(t, query, "suspect_reason IS NULL")
+	assert.Contains(t, query, "interpolate(avg(value))")
+	assert.Equal(t, 7, len(args))
+
+	// Steps run from the last bucket with values to the horizon.
+	assert.Equal(t, 50, forecastSteps(criteria, start.Add(22*time.Hour)))
+	assert.Equal(t, MAX_TREND_POINTS, forecastSteps(criteria, time.Time{}))
+
+	invalid := criteria
+	invalid.StartTime = invalid.EndTime.Add(-(MAX_GAPFILL_BUCKETS + 1) * time.Hour)
+	assert.NotNil(t, validateTrendCriteria(invalid))
+}
@@ -0,0 +1,125 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Get trip detection state for a device (nil if none recorded).
+func (api *Api) TripDetectorState(ctx context.Context, deviceId uint) (*TripDetectorState, error) {
+	found := &TripDetectorState{}
+	result := api.RDB.Database.WithContext(ctx).First(found, "device_id = ?", deviceId)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
+
+// Save trip detection state for a device.
+func (api *Api) SaveTripDetectorState(ctx context.Context, state *TripDetectorState) error {
+	return api.RDB.Database.WithContext(ctx).Save(state).Error
+}
+
+// Get the trip in progress for a device (nil if none).
+func (api *Api) ActiveTrip(ctx context.Context, deviceId uint) (*Trip, error) {
+	found := make([]*Trip, 0)
+	result := api.RDB.Database.WithContext(ctx).Where("device_id = ? AND active", deviceId).
+		Order("start_time DESC").Limit(1).Find(&found)
+	if result.Error != nil || len(found) == 0 {
+		return nil, result.Error
+	}
+	return found[0], nil
+}
+
+// Get the stop in progress for a device (nil if none).
+func (api *Api) ActiveStop(ctx context.Context, deviceId uint) (*Stop, error) {
+	found := make([]*Stop, 0)
+	result := api.RDB.Database.WithContext(ctx).Where("device_id = ? AND active", deviceId).
+		Order("start_time DESC").Limit(1).Find(&found)
+	if result.Error != nil || len(found) == 0 {
+		return nil, result.Error
+	}
+	return found[0], nil
+}
+
+// Create or update a trip.
+func (api *Api) SaveTrip(ctx context.Context, trip *Trip) error {
+	return api.RDB.Database.WithContext(ctx).Save(trip).Error
+}
+
+// Create or update a stop.
+func (api *Api) SaveStop(ctx context.Context, stop *Stop) error {
+	return api.RDB.Database.WithContext(ctx).Save(stop).Error
+}
+
+// Build filters shared by trip and stop searches.
+func tripFilters(criteria TripSearchCriteria) func(db *gorm.DB) *gorm.DB {
+	return func(result *gorm.DB) *gorm.DB {
+		if len(criteria.DeviceIds) > 0 {
+			result = result.Where("device_id IN ?", criteria.DeviceIds)
+		}
+		if len(criteria.CustomerIds) > 0 {
+			result = result.Where("rel_customer_id IN ?", criteria.CustomerIds)
+		}
+		if criteria.StartTime != nil {
+			result = result.Where("end_time >= ?", *criteria.StartTime)
+		}
+		if criteria.EndTime != nil {
+			result = result.Where("start_time < ?", *criteria.EndTime)
+		}
+		return result
+	}
+}
+
+// Search for trips that meet criteria (most recent first).
+func (api *Api) Trips(ctx context.Context, criteria TripSearchCriteria) (*TripSearchResults, error) {
+	results := make([]Trip, 0)
+	db, pag := api.RDB.ListOf(&Trip{}, tripFilters(criteria), criteria.Pagination)
+	db.Order("start_time DESC").Find(&results)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+
+	// Wrap as search results.
+	return &TripSearchResults{
+		Results:    results,
+		Pagination: pag,
+	}, nil
+}
+
+// Search for stops that meet criteria (most recent first).
+func (api *Api) Stops(ctx context.Context, criteria TripSearchCriteria) (*StopSearchResults, error) {
+	results := make([]Stop, 0)
+	db, pag := api.RDB.ListOf(&Stop{}, tripFilters(criteria), criteria.Pagination)
+	db.Order("start_time DESC").Find(&results)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+
+	// Wrap as search results.
+	return &StopSearchResults{
+		Results:    results,
+		Pagination: pag,
+	}, nil
+}
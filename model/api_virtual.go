@@ -0,0 +1,318 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/devicechain-io/dc-microservice/rdb"
+	"gorm.io/gorm"
+)
+
+// Validate a virtual measurement request and apply it to the entity.
+func applyVirtualMeasurement(virtual *VirtualMeasurement, request *VirtualMeasurementCreateRequest) error {
+	if request.Token == "" || strings.Contains(request.Token, ":") {
+		return fmt.Errorf("virtual measurement requires a measurement name without unit")
+	}
+	expr, err := ParseExpression(request.Expression)
+	if err != nil {
+		return err
+	}
+	if len(expr.Variables) == 0 {
+		return fmt.Errorf("virtual measurement expression must reference at least one measurement")
+	}
+	for _, variable := range expr.Variables {
+		if variable == request.Token {
+			return fmt.Errorf("virtual measurement expression must not reference itself")
+		}
+	}
+	virtual.Token = request.Token
+	virtual.Name = rdb.NullStrOf(request.Name)
+	virtual.Description = rdb.NullStrOf(request.Description)
+	virtual.Metadata = rdb.MetadataStrOf(request.Metadata)
+	virtual.Expression = request.Expression
+	virtual.Unit = rdb.NullStrOf(request.Unit)
+	virtual.Enabled = request.Enabled
+	return nil
+}
+
+// Create a new virtual measurement.
+func (api *Api) CreateVirtualMeasurement(ctx context.Context,
+	request *VirtualMeasurementCreateRequest) (*VirtualMeasurement, error) {
+	created := &VirtualMeasurement{}
+	err := applyVirtualMeasurement(created, request)
+	if err != nil {
+		return nil, err
+	}
+	result := api.RDB.Database.Create(created)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return created, nil
+}
+
+// Update an existing virtual measurement.
+func (api *Api) UpdateVirtualMeasurement(ctx context.Context, token string,
+	request *VirtualMeasurementCreateRequest) (*VirtualMeasurement, error) {
+	matches, err := api.VirtualMeasurementsByToken(ctx, []string{token})
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	updated := matches[0]
+	err = applyVirtualMeasurement(updated, request)
+	if err != nil {
+		return nil, err
+	}
+	result := api.RDB.Database.Save(updated)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return updated, nil
+}
+
+// Delete an existing virtual measurement. Values already computed are kept.
+func (api *Api) DeleteVirtualMeasurement(ctx context.Context, token string) (*VirtualMeasurement, error) {
+	matches, err := api.VirtualMeasurementsByToken(ctx, []string{token})
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	deleted := matches[0]
+	result := api.RDB.Database.Delete(deleted)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return deleted, nil
+}
+
+// Get virtual measurements by token.
+func (api *Api) VirtualMeasurementsByToken(ctx context.Context, tokens []string) ([]*VirtualMeasurement, error) {
+	found := make([]*VirtualMeasurement, 0)
+	result := api.RDB.Database.Find(&found, "token in ?", tokens)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
+
+// Search for virtual measurements that meet criteria.
+func (api *Api) VirtualMeasurements(ctx context.Context,
+	criteria VirtualMeasurementSearchCriteria) (*VirtualMeasurementSearchResults, error) {
+	results := make([]VirtualMeasurement, 0)
+	db, pag := api.RDB.ListOf(&VirtualMeasurement{}, nil, criteria.Pagination)
+	db.Find(&results)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+
+	// Wrap as search results.
+	return &VirtualMeasurementSearchResults{
+		Results:    results,
+		Pagination: pag,
+	}, nil
+}
+
+// Get enabled virtual measurements used when persisting measurement events.
+func (api *Api) ActiveVirtualMeasurements(ctx context.Context) ([]VirtualMeasurement, error) {
+	found := make([]VirtualMeasurement, 0)
+	result := api.RDB.Database.WithContext(ctx).Order("id").Find(&found, "enabled = ?", true)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return found, nil
+}
+
+// Identifies a bucket of values for a device.
+type deviceBucket struct {
+	DeviceId uint
+	Bucket   time.Time
+}
+
+// Value of a virtual measurement input along with the bucket it falls in.
+type virtualInput struct {
+	DeviceId     uint
+	Name         string
+	OccurredTime time.Time
+	Bucket       time.Time
+	Value        float64
+}
+
+// Build the query for values of virtual measurement inputs, ordered by device and time so that
+// inputs measured together are adjacent.
+func (api *Api) virtualInputQuery(criteria MeasurementAggregateCriteria, names []string) (string, []interface{}) {
+	icriteria := criteria
+	icriteria.Names = names
+	where, wargs := rawMeasurementFilters("", icriteria)
+	query := fmt.Sprintf(`SELECT device_id, name, occurred_time, time_bucket(?::interval, occurred_time) AS bucket, value
+FROM %s WHERE %s ORDER BY device_id, occurred_time, name LIMIT ?`, api.qualified(HYPERTABLE_MEASUREMENT_EVENTS), where)
+	args := append([]interface{}{asInterval(criteria.Bucket)}, wargs...)
+	args = append(args, MAX_VIRTUAL_INPUT_VALUES+1)
+	return query, args
+}
+
+// Compute aggregates for a virtual measurement by evaluating its expression for each sample (the
+// inputs measured by a device at the same time), then aggregating the results into buckets.
+// Samples missing an input and buckets included in the skipped set are left out. Computed values
+// are converted if a conversion is given.
+func virtualMeasurementAggregates(virtual *VirtualMeasurement, expr *Expression, inputs []virtualInput,
+	skipped map[deviceBucket]bool, conversion *UnitConversion) []MeasurementAggregate {
+	keys := make([]deviceBucket, 0)
+	buckets := make(map[deviceBucket]*MeasurementAggregate)
+	add := func(key deviceBucket, values map[string]float64) {
+		if skipped[key] || len(values) < len(expr.Variables) {
+			return
+		}
+		value, err := expr.Evaluate(values)
+		if err != nil {
+			return
+		}
+		if conversion != nil {
+			value = value*conversion.Scale + conversion.Offset
+		}
+		current, ok := buckets[key]
+		if !ok {
+			low, high, sum := value, value, 0.0
+			current = &MeasurementAggregate{DeviceId: key.DeviceId, Name: virtual.Token, Bucket: key.Bucket,
+				Min: &low, Max: &high, Sum: &sum}
+			buckets[key] = current
+			keys = append(keys, key)
+		}
+		current.Count++
+		*current.Sum += value
+		if value < *current.Min {
+			*current.Min = value
+		}
+		if value > *current.Max {
+			*current.Max = value
+		}
+	}
+
+	var sample *virtualInput
+	values := make(map[string]float64)
+	for i := range inputs {
+		input := &inputs[i]
+		if sample != nil && (input.DeviceId != sample.DeviceId || !input.OccurredTime.Equal(sample.OccurredTime)) {
+			add(deviceBucket{DeviceId: sample.DeviceId, Bucket: sample.Bucket}, values)
+			values = make(map[string]float64)
+		}
+		sample = input
+		values[input.Name] = input.Value
+	}
+	if sample != nil {
+		add(deviceBucket{DeviceId: sample.DeviceId, Bucket: sample.Bucket}, values)
+	}
+
+	results := make([]MeasurementAggregate, 0, len(keys))
+	for _, key := range keys {
+		current := buckets[key]
+		avg := *current.Sum / float64(current.Count)
+		current.Avg = &avg
+		results = append(results, *current)
+	}
+	return results
+}
+
+// Get the conversion of values for a virtual measurement to the criteria unit. Returns false if
+// values can not be converted (in which case the virtual measurement is left out).
+func (api *Api) virtualConversion(virtual *VirtualMeasurement, criteria MeasurementAggregateCriteria) (*UnitConversion, bool) {
+	if criteria.Unit == nil {
+		return nil, true
+	}
+	if !virtual.Unit.Valid {
+		return nil, false
+	}
+	conversion, err := api.Units.Conversion(virtual.Unit.String, *criteria.Unit)
+	if err != nil {
+		return nil, false
+	}
+	return conversion, true
+}
+
+// Add aggregates for virtual measurements in the criteria names that were not stored, computing
+// them from the input values measured in each bucket. Gap filling is not supported for virtual
+// measurements since computed buckets can not be filled consistently with stored ones.
+func (api *Api) addVirtualMeasurementAggregates(ctx context.Context, criteria MeasurementAggregateCriteria,
+	results []MeasurementAggregate) ([]MeasurementAggregate, error) {
+	virtuals, err := api.VirtualMeasurementsByToken(ctx, criteria.Names)
+	if err != nil {
+		return nil, err
+	}
+	added := false
+	for _, virtual := range virtuals {
+		if !virtual.Enabled {
+			continue
+		}
+		if criteria.Fill != "" {
+			return nil, fmt.Errorf("gap filling is not supported for virtual measurement: %s", virtual.Token)
+		}
+		conversion, ok := api.virtualConversion(virtual, criteria)
+		if !ok {
+			continue
+		}
+		expr, err := ParseExpression(virtual.Expression)
+		if err != nil {
+			return nil, err
+		}
+		stored := make(map[deviceBucket]bool)
+		for _, result := range results {
+			if result.Name == virtual.Token {
+				stored[deviceBucket{DeviceId: result.DeviceId, Bucket: result.Bucket}] = true
+			}
+		}
+
+		query, args := api.virtualInputQuery(criteria, expr.Variables)
+		inputs := make([]virtualInput, 0)
+		result := api.RDB.Database.WithContext(ctx).Raw(query, args...).Scan(&inputs)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if len(inputs) > MAX_VIRTUAL_INPUT_VALUES {
+			return nil, fmt.Errorf("virtual measurement %s has more than %d input values; request a shorter time range",
+				virtual.Token, MAX_VIRTUAL_INPUT_VALUES)
+		}
+		computed := virtualMeasurementAggregates(virtual, expr, inputs, stored, conversion)
+		if len(computed) > 0 {
+			results = append(results, computed...)
+			added = true
+		}
+	}
+
+	// Keep the same ordering as stored aggregates.
+	if added {
+		sort.SliceStable(results, func(i, j int) bool {
+			if results[i].DeviceId != results[j].DeviceId {
+				return results[i].DeviceId < results[j].DeviceId
+			}
+			if results[i].Name != results[j].Name {
+				return results[i].Name < results[j].Name
+			}
+			return results[i].Bucket.Before(results[j].Bucket)
+		})
+	}
+	return results, nil
+}
@@ -0,0 +1,116 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"time"
+
+	gormigrate "github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Creates storage for daily time spent by devices inside areas and geofences.
+func NewAreaDwellSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018107000",
+		Migrate: func(tx *gorm.DB) error {
+			// Time a device spent inside an area on a day.
+			type AreaDwell struct {
+				DeviceId      uint      `gorm:"primaryKey"`
+				AreaType      string    `gorm:"primaryKey;size:16"`
+				AreaId        uint      `gorm:"primaryKey"`
+				Day           time.Time `gorm:"primaryKey;type:date"`
+				Seconds       float64   `gorm:"not null"`
+				RelAssetId    *uint
+				RelCustomerId *uint
+			}
+
+			err := tx.AutoMigrate(&AreaDwell{})
+			if err != nil {
+				return err
+			}
+
+			// Add indexes for report queries.
+			for _, column := range []string{"rel_customer_id", "rel_asset_id", "area_id"} {
+				err = tx.Exec("CREATE INDEX ON \"event-management\".\"area_dwells\" (" + column + ", day);").Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("area_dwells")
+		},
+	}
+}
+
+// Keeps dwell time separately per related asset and customer (rather than moving the whole day
+// to the latest relations) and stores the last position of each device so that dwell time is
+// counted across restarts.
+func NewDwellPositionSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018115000",
+		Migrate: func(tx *gorm.DB) error {
+			// Last location of a device counted toward dwell time.
+			type DwellPosition struct {
+				DeviceId      uint      `gorm:"primaryKey"`
+				Time          time.Time `gorm:"not null"`
+				Areas         string    `gorm:"type:jsonb"`
+				RelAssetId    *uint
+				RelCustomerId *uint
+			}
+
+			err := tx.AutoMigrate(&DwellPosition{})
+			if err != nil {
+				return err
+			}
+
+			// Replace the primary key with a unique index including relations. Missing relations
+			// are indexed as zero since nulls never conflict.
+			err = tx.Exec("ALTER TABLE \"event-management\".\"area_dwells\" DROP CONSTRAINT area_dwells_pkey;").Error
+			if err != nil {
+				return err
+			}
+			return tx.Exec(`CREATE UNIQUE INDEX area_dwells_key ON "event-management"."area_dwells"
+	(device_id, area_type, area_id, day, COALESCE(rel_asset_id, 0), COALESCE(rel_customer_id, 0));`).Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			err := tx.Migrator().DropTable("dwell_positions")
+			if err != nil {
+				return err
+			}
+			err = tx.Exec("DROP INDEX \"event-management\".area_dwells_key;").Error
+			if err != nil {
+				return err
+			}
+
+			// Merge time split across relations back into a row per area and day.
+			err = tx.Exec(`WITH split AS (DELETE FROM "event-management"."area_dwells" RETURNING *)
+INSERT INTO "event-management"."area_dwells"
+	(device_id, area_type, area_id, day, seconds, rel_asset_id, rel_customer_id)
+SELECT device_id, area_type, area_id, day, sum(seconds),
+	(array_agg(rel_asset_id ORDER BY seconds DESC))[1], (array_agg(rel_customer_id ORDER BY seconds DESC))[1]
+FROM split GROUP BY device_id, area_type, area_id, day;`).Error
+			if err != nil {
+				return err
+			}
+			return tx.Exec(`ALTER TABLE "event-management"."area_dwells"
+	ADD PRIMARY KEY (device_id, area_type, area_id, day);`).Error
+		},
+	}
+}
@@ -41,7 +41,8 @@ type Event struct {
 	ProcessedTime      time.Time
 }
 
-// Location event fields.
+// Location event fields. The table also holds a generated geography column (geog) which is
+// used for spatial queries but is not mapped here since it is computed by the database.
 type LocationEvent struct {
 	DeviceId     uint              `gorm:"not null"`
 	EventType    esmodel.EventType `gorm:"not null"`
@@ -49,31 +50,118 @@ type LocationEvent struct {
 	Event        Event             `gorm:"foreignKey:DeviceId,EventType,OccurredTime;References:DeviceId,EventType,OccurredTime"`
 	Latitude     sql.NullFloat64   `gorm:"type:decimal(10,8);"`
 	Longitude    sql.NullFloat64   `gorm:"type:decimal(11,8);"`
-	Elevation    sql.NullFloat64   `gorm:"type:decimal(10,8);"`
+	Elevation    sql.NullFloat64   `gorm:"type:decimal(10,3);"`
+
+	// Optional attributes reported by the receiver.
+	Accuracy   sql.NullFloat64
+	Speed      sql.NullFloat64
+	Course     sql.NullFloat64
+	Satellites sql.NullInt64  `gorm:"type:smallint;"`
+	FixType    sql.NullString `gorm:"size:16;"`
+
+	// Derived from the previous known location of the device.
+	ComputedSpeed        sql.NullFloat64
+	ComputedHeading      sql.NullFloat64
+	DistanceFromPrevious sql.NullFloat64
+
+	// Name of the validation rule that flagged the location (null unless suspect).
+	SuspectReason sql.NullString `gorm:"size:32;"`
+
+	// Resolved from the local gazetteer if geocoding is enabled.
+	PlaceName   sql.NullString `gorm:"size:128;"`
+	CountryCode sql.NullString `gorm:"size:8;"`
+	RegionCode  sql.NullString `gorm:"size:16;"`
 }
 
 // Information required to create a location event.
 type LocationEventCreateRequest struct {
 	Event
-	Latitude  *float64
-	Longitude *float64
-	Elevation *float64
+	Latitude             *float64
+	Longitude            *float64
+	Elevation            *float64
+	Accuracy             *float64
+	Speed                *float64
+	Course               *float64
+	Satellites           *int64
+	FixType              *string
+	ComputedSpeed        *float64
+	ComputedHeading      *float64
+	DistanceFromPrevious *float64
+	SuspectReason        *string
+	PlaceName            *string
+	CountryCode          *string
+	RegionCode           *string
 }
 
 // Measurement event fields.
 type MeasurementEvent struct {
-	DeviceId     uint            `gorm:"not null"`
-	OccurredTime time.Time       `gorm:"not null"`
-	Event        Event           `gorm:"foreignKey:DeviceId,OccurredTime;References:DeviceId,OccurredTime"`
+	DeviceId     uint              `gorm:"not null"`
+	EventType    esmodel.EventType `gorm:"not null"`
+	OccurredTime time.Time         `gorm:"not null"`
+	Event        Event             `gorm:"foreignKey:DeviceId,EventType,OccurredTime;References:DeviceId,EventType,OccurredTime"`
+	Name         string            `gorm:"not null;size:128"`
+	Value        float64           `gorm:"not null"`
+	Classifier   sql.NullInt64
 	Latitude     sql.NullFloat64 `gorm:"type:decimal(10,8);"`
 	Longitude    sql.NullFloat64 `gorm:"type:decimal(11,8);"`
-	Elevation    sql.NullFloat64 `gorm:"type:decimal(10,8);"`
+	Elevation    sql.NullFloat64 `gorm:"type:decimal(10,3);"`
+
+	// Unit of the value (the unit of the measurement definition if one is registered).
+	Unit sql.NullString `gorm:"size:64;"`
+
+	// Reported value and unit if the value was converted to the unit of the definition.
+	RawValue sql.NullFloat64
+	RawUnit  sql.NullString `gorm:"size:64;"`
+
+	// Reason the measurement was flagged (null unless suspect).
+	SuspectReason sql.NullString `gorm:"size:32;"`
+
+	// Set if the position is the last known device location rather than reported.
+	LocationInferred sql.NullBool
 }
 
 // Information required to create a measurement event.
 type MeasurementEventCreateRequest struct {
 	Event
-	Latitude  *float64
-	Longitude *float64
-	Elevation *float64
+	Name          string
+	Unit          *string
+	RawUnit       *string
+	RawValue      *float64
+	SuspectReason *string
+	Value         float64
+	Classifier    *int64
+	Latitude      *float64
+	Longitude     *float64
+	Elevation     *float64
+
+	LocationInferred bool
+}
+
+// Alert event fields.
+type AlertEvent struct {
+	DeviceId     uint              `gorm:"not null"`
+	EventType    esmodel.EventType `gorm:"not null"`
+	OccurredTime time.Time         `gorm:"not null"`
+	Event        Event             `gorm:"foreignKey:DeviceId,EventType,OccurredTime;References:DeviceId,EventType,OccurredTime"`
+	Type         string            `gorm:"not null;size:128"`
+	Level        uint32            `gorm:"not null"`
+	Message      string            `gorm:"size:1024"`
+	Source       string            `gorm:"size:128"`
+
+	// Set for alerts generated by threshold rules.
+	RuleId         *uint
+	TriggerValue   sql.NullFloat64
+	RuleTransition sql.NullString `gorm:"size:16"`
+}
+
+// Information required to create an alert event.
+type AlertEventCreateRequest struct {
+	Event
+	Type           string
+	Level          uint32
+	Message        string
+	Source         string
+	RuleId         *uint
+	TriggerValue   *float64
+	RuleTransition *string
 }
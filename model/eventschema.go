@@ -0,0 +1,77 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"fmt"
+
+	gormigrate "github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Relationship columns renamed to match the fields carried on resolved events.
+var eventRelationshipColumns = [][2]string{
+	{"device_group_id", "rel_device_group_id"},
+	{"customer_id", "rel_customer_id"},
+	{"customer_group_id", "rel_customer_group_id"},
+	{"area_id", "rel_area_id"},
+	{"area_group_id", "rel_area_group_id"},
+	{"asset_id", "rel_asset_id"},
+	{"asset_group_id", "rel_asset_group_id"},
+}
+
+// Aligns the base events table with the relationship fields carried on resolved events. The
+// assignment id is kept for existing rows but is no longer required since resolved events do
+// not carry it.
+func NewEventRelationshipsSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018090000",
+		Migrate: func(tx *gorm.DB) error {
+			statements := []string{
+				"ALTER TABLE \"event-management\".\"events\" ALTER COLUMN assignment_id DROP NOT NULL;",
+				"ALTER TABLE \"event-management\".\"events\" ADD COLUMN IF NOT EXISTS rel_device_id bigint;",
+			}
+			for _, column := range eventRelationshipColumns {
+				statements = append(statements, fmt.Sprintf(
+					"ALTER TABLE \"event-management\".\"events\" RENAME COLUMN %s TO %s;", column[0], column[1]))
+			}
+			for _, statement := range statements {
+				err := tx.Exec(statement).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			statements := []string{
+				"ALTER TABLE \"event-management\".\"events\" DROP COLUMN IF EXISTS rel_device_id;",
+			}
+			for _, column := range eventRelationshipColumns {
+				statements = append(statements, fmt.Sprintf(
+					"ALTER TABLE \"event-management\".\"events\" RENAME COLUMN %s TO %s;", column[1], column[0]))
+			}
+			for _, statement := range statements {
+				err := tx.Exec(statement).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
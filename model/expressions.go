@@ -0,0 +1,365 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Function that may be called from an expression.
+type expressionFunction struct {
+	Args int
+	Call func(args []float64) float64
+}
+
+// Functions available to expressions.
+var expressionFunctions = map[string]expressionFunction{
+	"abs":   {Args: 1, Call: func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {Args: 1, Call: func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"exp":   {Args: 1, Call: func(a []float64) float64 { return math.Exp(a[0]) }},
+	"ln":    {Args: 1, Call: func(a []float64) float64 { return math.Log(a[0]) }},
+	"log10": {Args: 1, Call: func(a []float64) float64 { return math.Log10(a[0]) }},
+	"pow":   {Args: 2, Call: func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"min":   {Args: 2, Call: func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":   {Args: 2, Call: func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+	"dewpoint": {Args: 2, Call: func(a []float64) float64 {
+		return DewPoint(a[0], a[1])
+	}},
+}
+
+// Compute dew point (degrees Celsius) from temperature (degrees Celsius) and relative humidity
+// (percent) using the Magnus approximation.
+func DewPoint(temperature float64, humidity float64) float64 {
+	const a, b = 17.62, 243.12
+	gamma := math.Log(humidity/100) + a*temperature/(b+temperature)
+	return b * gamma / (a - gamma)
+}
+
+// Node in a parsed expression.
+type expressionNode interface {
+	eval(values map[string]float64) (float64, error)
+}
+
+type numberNode float64
+
+func (n numberNode) eval(map[string]float64) (float64, error) {
+	return float64(n), nil
+}
+
+type variableNode string
+
+func (n variableNode) eval(values map[string]float64) (float64, error) {
+	value, ok := values[string(n)]
+	if !ok {
+		return 0, fmt.Errorf("no value for '%s'", string(n))
+	}
+	return value, nil
+}
+
+type unaryNode struct {
+	Operand expressionNode
+}
+
+func (n unaryNode) eval(values map[string]float64) (float64, error) {
+	value, err := n.Operand.eval(values)
+	return -value, err
+}
+
+type binaryNode struct {
+	Operator byte
+	Left     expressionNode
+	Right    expressionNode
+}
+
+func (n binaryNode) eval(values map[string]float64) (float64, error) {
+	left, err := n.Left.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	right, err := n.Right.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	switch n.Operator {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	case '/':
+		return left / right, nil
+	default:
+		return math.Pow(left, right), nil
+	}
+}
+
+type callNode struct {
+	Function expressionFunction
+	Args     []expressionNode
+}
+
+func (n callNode) eval(values map[string]float64) (float64, error) {
+	args := make([]float64, len(n.Args))
+	for i, arg := range n.Args {
+		value, err := arg.eval(values)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = value
+	}
+	return n.Function.Call(args), nil
+}
+
+// Arithmetic expression over measurement values. Expressions support +, -, *, /, ^ (power),
+// parentheses, numeric constants, measurement names as variables and the functions abs, sqrt,
+// exp, ln, log10, pow, min, max and dewpoint(temperature, humidity).
+type Expression struct {
+	Text      string
+	Variables []string
+
+	root expressionNode
+}
+
+// Parse an expression.
+func ParseExpression(text string) (*Expression, error) {
+	parser := &expressionParser{text: text, variables: make(map[string]bool)}
+	root, err := parser.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	parser.skipSpace()
+	if parser.pos < len(parser.text) {
+		return nil, parser.errorf("unexpected '%c'", parser.text[parser.pos])
+	}
+	variables := make([]string, 0)
+	for variable := range parser.variables {
+		variables = append(variables, variable)
+	}
+	sort.Strings(variables)
+	return &Expression{
+		Text:      text,
+		Variables: variables,
+		root:      root,
+	}, nil
+}
+
+// Evaluate the expression with values for each variable. An error is returned if a value
+// is missing or the result is not a finite number.
+func (e *Expression) Evaluate(values map[string]float64) (float64, error) {
+	result, err := e.root.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, fmt.Errorf("expression '%s' did not evaluate to a finite number", e.Text)
+	}
+	return result, nil
+}
+
+// Recursive descent parser for expressions.
+type expressionParser struct {
+	text      string
+	pos       int
+	variables map[string]bool
+}
+
+func (p *expressionParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid expression at position %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *expressionParser) skipSpace() {
+	for p.pos < len(p.text) && unicode.IsSpace(rune(p.text[p.pos])) {
+		p.pos++
+	}
+}
+
+// Consume the next character if it is one of the given operators.
+func (p *expressionParser) operator(ops string) (byte, bool) {
+	p.skipSpace()
+	if p.pos < len(p.text) && strings.IndexByte(ops, p.text[p.pos]) >= 0 {
+		p.pos++
+		return p.text[p.pos-1], true
+	}
+	return 0, false
+}
+
+// sum := product (('+' | '-') product)*
+func (p *expressionParser) parseSum() (expressionNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.operator("+-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{Operator: op, Left: left, Right: right}
+	}
+}
+
+// product := unary (('*' | '/') unary)*
+func (p *expressionParser) parseProduct() (expressionNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.operator("*/")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{Operator: op, Left: left, Right: right}
+	}
+}
+
+// unary := '-' unary | power
+func (p *expressionParser) parseUnary() (expressionNode, error) {
+	if _, ok := p.operator("-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{Operand: operand}, nil
+	}
+	return p.parsePower()
+}
+
+// power := primary ('^' unary)? (right associative)
+func (p *expressionParser) parsePower() (expressionNode, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.operator("^"); ok {
+		exponent, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return binaryNode{Operator: '^', Left: base, Right: exponent}, nil
+	}
+	return base, nil
+}
+
+// primary := number | name | name '(' args ')' | '(' sum ')'
+func (p *expressionParser) parsePrimary() (expressionNode, error) {
+	p.skipSpace()
+	if p.pos >= len(p.text) {
+		return nil, p.errorf("unexpected end of expression")
+	}
+	c := p.text[p.pos]
+	switch {
+	case c == '(':
+		p.pos++
+		inner, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.operator(")"); !ok {
+			return nil, p.errorf("missing ')'")
+		}
+		return inner, nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	case c == '_' || unicode.IsLetter(rune(c)):
+		return p.parseName()
+	default:
+		return nil, p.errorf("unexpected '%c'", c)
+	}
+}
+
+func (p *expressionParser) parseNumber() (expressionNode, error) {
+	start := p.pos
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		if (c >= '0' && c <= '9') || c == '.' {
+			p.pos++
+		} else if (c == 'e' || c == 'E') && p.pos+1 < len(p.text) {
+			p.pos++
+			if p.text[p.pos] == '+' || p.text[p.pos] == '-' {
+				p.pos++
+			}
+		} else {
+			break
+		}
+	}
+	literal := p.text[start:p.pos]
+	value, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("invalid number '%s'", literal)
+	}
+	return numberNode(value), nil
+}
+
+func (p *expressionParser) parseName() (expressionNode, error) {
+	start := p.pos
+	for p.pos < len(p.text) {
+		c := rune(p.text[p.pos])
+		if c != '_' && c != '.' && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			break
+		}
+		p.pos++
+	}
+	name := p.text[start:p.pos]
+	if _, ok := p.operator("("); !ok {
+		p.variables[name] = true
+		return variableNode(name), nil
+	}
+
+	function, ok := expressionFunctions[strings.ToLower(name)]
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("unknown function '%s'", name)
+	}
+	args := make([]expressionNode, 0)
+	if _, ok := p.operator(")"); !ok {
+		for {
+			arg, err := p.parseSum()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.operator(","); ok {
+				continue
+			}
+			if _, ok := p.operator(")"); !ok {
+				return nil, p.errorf("missing ')' after arguments to '%s'", name)
+			}
+			break
+		}
+	}
+	if len(args) != function.Args {
+		p.pos = start
+		return nil, p.errorf("function '%s' takes %d argument(s)", name, function.Args)
+	}
+	return callNode{Function: function, Args: args}, nil
+}
@@ -0,0 +1,73 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test parsing and evaluating expressions over measurement values.
+func TestExpressions(t *testing.T) {
+	// Variables are collected in sorted order.
+	expr, err := ParseExpression("voltage * current")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"current", "voltage"}, expr.Variables)
+	value, err := expr.Evaluate(map[string]float64{"voltage": 230, "current": 2.5})
+	assert.Nil(t, err)
+	assert.InDelta(t, 575, value, 1e-9)
+
+	// Precedence, unary minus and right associative powers.
+	expr, err = ParseExpression("1 + 2 * 3 - -4 / 2 ^ 2 ^ 1")
+	assert.Nil(t, err)
+	assert.Empty(t, expr.Variables)
+	value, err = expr.Evaluate(nil)
+	assert.Nil(t, err)
+	assert.InDelta(t, 8, value, 1e-9)
+	expr, err = ParseExpression("-2 ^ 2 + (1.5e1 - 5) * 2")
+	assert.Nil(t, err)
+	value, err = expr.Evaluate(nil)
+	assert.Nil(t, err)
+	assert.InDelta(t, 16, value, 1e-9)
+
+	// Functions including dew point from temperature and humidity.
+	expr, err = ParseExpression("dewpoint(temp, humidity)")
+	assert.Nil(t, err)
+	value, err = expr.Evaluate(map[string]float64{"temp": 25, "humidity": 60})
+	assert.Nil(t, err)
+	assert.InDelta(t, 16.69, value, 0.01)
+	expr, err = ParseExpression("sqrt(max(a, b)) + abs(ln(1))")
+	assert.Nil(t, err)
+	value, err = expr.Evaluate(map[string]float64{"a": 4, "b": 9})
+	assert.Nil(t, err)
+	assert.InDelta(t, 3, value, 1e-9)
+
+	// Missing values and non-finite results fail evaluation.
+	expr, err = ParseExpression("a / b")
+	assert.Nil(t, err)
+	_, err = expr.Evaluate(map[string]float64{"a": 1})
+	assert.NotNil(t, err)
+	_, err = expr.Evaluate(map[string]float64{"a": 1, "b": 0})
+	assert.NotNil(t, err)
+
+	// Syntax errors.
+	for _, invalid := range []string{"", "a +", "(a * b", "a b", "unknown(a)", "pow(a)", "3 $ 4", "1..2"} {
+		_, err = ParseExpression(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
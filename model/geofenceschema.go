@@ -0,0 +1,89 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	esmodel "github.com/devicechain-io/dc-event-sources/model"
+	"github.com/devicechain-io/dc-microservice/rdb"
+	gormigrate "github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Creates geofence definitions, per-device geofence state and geofence event storage.
+func NewGeofenceSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018101000",
+		Migrate: func(tx *gorm.DB) error {
+			// Geofence definition.
+			type Geofence struct {
+				gorm.Model
+				rdb.TokenReference
+				rdb.NamedEntity
+				rdb.MetadataEntity
+
+				AreaId          *uint
+				Vertices        sql.NullString `gorm:"type:text"`
+				CenterLatitude  sql.NullFloat64
+				CenterLongitude sql.NullFloat64
+				Radius          sql.NullFloat64
+			}
+
+			// Last known state of a device relative to a geofence.
+			type GeofenceState struct {
+				DeviceId     uint `gorm:"primaryKey"`
+				GeofenceId   uint `gorm:"primaryKey"`
+				Inside       bool `gorm:"not null"`
+				OccurredTime time.Time
+			}
+
+			// Geofence event fields.
+			type GeofenceEvent struct {
+				DeviceId     uint              `gorm:"not null"`
+				EventType    esmodel.EventType `gorm:"not null"`
+				OccurredTime time.Time         `gorm:"not null"`
+				GeofenceId   uint              `gorm:"not null"`
+				Transition   string            `gorm:"not null;size:16"`
+				Latitude     float64
+				Longitude    float64
+			}
+
+			err := tx.AutoMigrate(&Geofence{}, &GeofenceState{}, &GeofenceEvent{})
+			if err != nil {
+				return err
+			}
+
+			// Convert to a hypertable.
+			err = tx.Raw("SELECT create_hypertable('\"event-management\".\"geofence_events\"', 'occurred_time');").Row().Err()
+			if err != nil {
+				return err
+			}
+
+			// Add indexes on device id and geofence id.
+			err = tx.Exec("CREATE INDEX ON \"event-management\".\"geofence_events\" (device_id, occurred_time DESC);").Error
+			if err != nil {
+				return err
+			}
+			return tx.Exec("CREATE INDEX ON \"event-management\".\"geofence_events\" (geofence_id, occurred_time DESC);").Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("geofence_events", "geofence_states", "geofences")
+		},
+	}
+}
@@ -0,0 +1,224 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	gormigrate "github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Drops all foreign keys declared on a table.
+func dropForeignKeys(tx *gorm.DB, table string) error {
+	return tx.Exec(`DO $$
+DECLARE r record;
+BEGIN
+	FOR r IN SELECT conname FROM pg_constraint
+		WHERE conrelid = '"event-management"."` + table + `"'::regclass AND contype = 'f' LOOP
+		EXECUTE format('ALTER TABLE "event-management"."` + table + `" DROP CONSTRAINT %I', r.conname);
+	END LOOP;
+END $$;`).Error
+}
+
+// Decompresses all chunks and disables compression on a hypertable so that its columns may be
+// altered. Compression is enabled again when policies are synchronized at startup.
+func suspendCompression(tx *gorm.DB, table string) error {
+	return tx.Exec(`DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM timescaledb_information.hypertables WHERE hypertable_schema = 'event-management'
+		AND hypertable_name = '` + table + `' AND compression_enabled) THEN
+		PERFORM remove_compression_policy('"event-management"."` + table + `"', if_exists => true);
+		PERFORM decompress_chunk(c, true) FROM show_chunks('"event-management"."` + table + `"') c;
+		ALTER TABLE "event-management"."` + table + `" SET (timescaledb.compress = false);
+	END IF;
+END $$;`).Error
+}
+
+// Converts location events into a hypertable so that policies may be applied per event type.
+func NewLocationHypertableSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018093000",
+		Migrate: func(tx *gorm.DB) error {
+			// Hypertables may not hold foreign keys to other hypertables.
+			err := dropForeignKeys(tx, "location_events")
+			if err != nil {
+				return err
+			}
+
+			// Convert to a hypertable.
+			err = tx.Raw("SELECT create_hypertable('\"event-management\".\"location_events\"', 'occurred_time', migrate_data => true);").Row().Err()
+			if err != nil {
+				return err
+			}
+
+			// Add index on device id.
+			return tx.Exec("CREATE INDEX ON \"event-management\".\"location_events\" (device_id, occurred_time DESC);").Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return nil
+		},
+	}
+}
+
+// Adds a geography column generated from latitude, longitude and elevation along with a spatial
+// index. Existing rows are backfilled as the column is added.
+func NewLocationGeographySchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018100000",
+		Migrate: func(tx *gorm.DB) error {
+			err := tx.Exec("CREATE EXTENSION IF NOT EXISTS postgis;").Error
+			if err != nil {
+				return err
+			}
+
+			// Generated columns may not be added while compression is enabled.
+			err = suspendCompression(tx, "location_events")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Exec(`ALTER TABLE "event-management"."location_events" ADD COLUMN geog geography(PointZ, 4326)
+	GENERATED ALWAYS AS (CASE WHEN latitude IS NOT NULL AND longitude IS NOT NULL THEN
+		ST_SetSRID(ST_MakePoint(longitude::float8, latitude::float8, COALESCE(elevation, 0)::float8), 4326)::geography
+	END) STORED;`).Error
+			if err != nil {
+				return err
+			}
+
+			return tx.Exec("CREATE INDEX ON \"event-management\".\"location_events\" USING GIST (geog);").Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Exec("ALTER TABLE \"event-management\".\"location_events\" DROP COLUMN IF EXISTS geog;").Error
+		},
+	}
+}
+
+// Adds columns for speed (meters per second), heading (degrees from north) and distance (meters)
+// derived from the previous known location of the device.
+func NewLocationDerivedSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018103000",
+		Migrate: func(tx *gorm.DB) error {
+			// Nullable columns without defaults may be added to compressed hypertables.
+			return tx.Exec(`ALTER TABLE "event-management"."location_events"
+	ADD COLUMN IF NOT EXISTS computed_speed double precision,
+	ADD COLUMN IF NOT EXISTS computed_heading double precision,
+	ADD COLUMN IF NOT EXISTS distance_from_previous double precision;`).Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Exec(`ALTER TABLE "event-management"."location_events"
+	DROP COLUMN IF EXISTS computed_speed,
+	DROP COLUMN IF EXISTS computed_heading,
+	DROP COLUMN IF EXISTS distance_from_previous;`).Error
+		},
+	}
+}
+
+// Widens location and measurement elevation (previously limited to two integer digits) and adds
+// optional attributes reported by GPS receivers: horizontal accuracy (meters), speed (meters per second), course
+// (degrees from north), number of satellites in view and fix type (e.g. "2d", "3d").
+func NewLocationAttributesSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018104000",
+		Migrate: func(tx *gorm.DB) error {
+			// Column types may not be altered while compression is enabled.
+			err := suspendCompression(tx, "location_events")
+			if err != nil {
+				return err
+			}
+
+			// The generated geography column depends on elevation, so it is rebuilt along with
+			// its index once the type has changed.
+			err = tx.Exec(`ALTER TABLE "event-management"."location_events" DROP COLUMN IF EXISTS geog;`).Error
+			if err != nil {
+				return err
+			}
+
+			err = tx.Exec(`ALTER TABLE "event-management"."location_events"
+	ALTER COLUMN elevation TYPE decimal(10,3),
+	ADD COLUMN IF NOT EXISTS accuracy double precision,
+	ADD COLUMN IF NOT EXISTS speed double precision,
+	ADD COLUMN IF NOT EXISTS course double precision,
+	ADD COLUMN IF NOT EXISTS satellites smallint,
+	ADD COLUMN IF NOT EXISTS fix_type varchar(16);`).Error
+			if err != nil {
+				return err
+			}
+
+			err = tx.Exec(`ALTER TABLE "event-management"."location_events" ADD COLUMN geog geography(PointZ, 4326)
+	GENERATED ALWAYS AS (CASE WHEN latitude IS NOT NULL AND longitude IS NOT NULL THEN
+		ST_SetSRID(ST_MakePoint(longitude::float8, latitude::float8, COALESCE(elevation, 0)::float8), 4326)::geography
+	END) STORED;`).Error
+			if err != nil {
+				return err
+			}
+
+			err = tx.Exec("CREATE INDEX ON \"event-management\".\"location_events\" USING GIST (geog);").Error
+			if err != nil {
+				return err
+			}
+
+			err = suspendCompression(tx, "measurement_events")
+			if err != nil {
+				return err
+			}
+			return tx.Exec(`ALTER TABLE "event-management"."measurement_events"
+	ALTER COLUMN elevation TYPE decimal(10,3);`).Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Exec(`ALTER TABLE "event-management"."location_events"
+	DROP COLUMN IF EXISTS accuracy,
+	DROP COLUMN IF EXISTS speed,
+	DROP COLUMN IF EXISTS course,
+	DROP COLUMN IF EXISTS satellites,
+	DROP COLUMN IF EXISTS fix_type;`).Error
+		},
+	}
+}
+
+// Adds the name of the validation rule that flagged a location as suspect.
+func NewLocationSuspectSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018105000",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Exec(`ALTER TABLE "event-management"."location_events"
+	ADD COLUMN IF NOT EXISTS suspect_reason varchar(32);`).Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Exec(`ALTER TABLE "event-management"."location_events"
+	DROP COLUMN IF EXISTS suspect_reason;`).Error
+		},
+	}
+}
+
+// Adds the place name and region codes resolved for a location by geocoding.
+func NewLocationPlaceSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018106000",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Exec(`ALTER TABLE "event-management"."location_events"
+	ADD COLUMN IF NOT EXISTS place_name varchar(128),
+	ADD COLUMN IF NOT EXISTS country_code varchar(8),
+	ADD COLUMN IF NOT EXISTS region_code varchar(16);`).Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Exec(`ALTER TABLE "event-management"."location_events"
+	DROP COLUMN IF EXISTS place_name,
+	DROP COLUMN IF EXISTS country_code,
+	DROP COLUMN IF EXISTS region_code;`).Error
+		},
+	}
+}
@@ -0,0 +1,229 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	esmodel "github.com/devicechain-io/dc-event-sources/model"
+	"github.com/devicechain-io/dc-microservice/rdb"
+	gormigrate "github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Creates raw measurement storage as a hypertable.
+func NewMeasurementSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018091000",
+		Migrate: func(tx *gorm.DB) error {
+			// Measurement event fields. The association with the base event is not declared
+			// since hypertables may not hold foreign keys to other hypertables.
+			type MeasurementEvent struct {
+				DeviceId     uint              `gorm:"not null"`
+				EventType    esmodel.EventType `gorm:"not null"`
+				OccurredTime time.Time         `gorm:"not null"`
+				Name         string            `gorm:"not null;size:128"`
+				Value        float64           `gorm:"not null"`
+				Classifier   sql.NullInt64
+				Latitude     sql.NullFloat64 `gorm:"type:decimal(10,8);"`
+				Longitude    sql.NullFloat64 `gorm:"type:decimal(11,8);"`
+				Elevation    sql.NullFloat64 `gorm:"type:decimal(10,8);"`
+			}
+
+			err := tx.AutoMigrate(&MeasurementEvent{})
+			if err != nil {
+				return err
+			}
+
+			// Convert to a hypertable.
+			err = tx.Raw("SELECT create_hypertable('\"event-management\".\"measurement_events\"', 'occurred_time');").Row().Err()
+			if err != nil {
+				return err
+			}
+
+			// Add index for per-device, per-measurement series.
+			return tx.Exec("CREATE INDEX ON \"event-management\".\"measurement_events\" (device_id, name, occurred_time DESC);").Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("measurement_events")
+		},
+	}
+}
+
+// Filter applied to raw measurements included in rollups (leaves out suspect measurements).
+const measurementRollupFilter = "WHERE suspect_reason IS NULL"
+
+// Statement that creates a continuous aggregate of measurement stats for the given bucket width,
+// including raw measurements that match the filter (all if empty).
+func measurementRollupStatement(view string, width string, filter string) string {
+	return fmt.Sprintf(`CREATE MATERIALIZED VIEW "event-management"."%s"
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT device_id, name, time_bucket(INTERVAL '%s', occurred_time) AS bucket,
+	count(*) AS sample_count, min(value) AS min_value, max(value) AS max_value, sum(value) AS sum_value
+FROM "event-management"."measurement_events" %s
+GROUP BY device_id, name, bucket
+WITH NO DATA;`, view, width, filter)
+}
+
+// Statement that adds a refresh policy for a measurement rollup.
+func measurementRollupPolicyStatement(view string, start string, end string, schedule string) string {
+	return fmt.Sprintf(`SELECT add_continuous_aggregate_policy('"event-management"."%s"',
+	start_offset => INTERVAL '%s', end_offset => INTERVAL '%s', schedule_interval => INTERVAL '%s');`,
+		view, start, end, schedule)
+}
+
+// Statements that create hourly and daily continuous aggregates with refresh policies.
+func measurementRollupStatements(filter string) []string {
+	return []string{
+		measurementRollupStatement(MEASUREMENT_ROLLUP_HOURLY, "1 hour", filter),
+		measurementRollupPolicyStatement(MEASUREMENT_ROLLUP_HOURLY, "3 hours", "1 hour", "30 minutes"),
+		"CREATE INDEX ON \"event-management\".\"" + MEASUREMENT_ROLLUP_HOURLY + "\" (device_id, name, bucket DESC);",
+		measurementRollupStatement(MEASUREMENT_ROLLUP_DAILY, "1 day", filter),
+		measurementRollupPolicyStatement(MEASUREMENT_ROLLUP_DAILY, "3 days", "1 day", "1 hour"),
+		"CREATE INDEX ON \"event-management\".\"" + MEASUREMENT_ROLLUP_DAILY + "\" (device_id, name, bucket DESC);",
+	}
+}
+
+// Drop measurement rollups (along with their policies).
+func dropMeasurementRollups(tx *gorm.DB) error {
+	for _, view := range []string{MEASUREMENT_ROLLUP_DAILY, MEASUREMENT_ROLLUP_HOURLY} {
+		err := tx.Exec(fmt.Sprintf("DROP MATERIALIZED VIEW IF EXISTS \"event-management\".\"%s\";", view)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Creates hourly and daily continuous aggregates over raw measurements.
+func NewMeasurementRollupSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018092000",
+		Migrate: func(tx *gorm.DB) error {
+			for _, statement := range measurementRollupStatements("") {
+				err := tx.Exec(statement).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return dropMeasurementRollups(tx)
+		},
+	}
+}
+
+// Creates the measurement definition registry and adds the unit of measurement values, the value
+// and unit as reported (for values converted to the unit of their definition) and the reason a
+// measurement was flagged as suspect.
+func NewMeasurementDefinitionSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018108000",
+		Migrate: func(tx *gorm.DB) error {
+			// Describes a measurement reported by devices.
+			type MeasurementDefinition struct {
+				gorm.Model
+				rdb.TokenReference
+				rdb.NamedEntity
+				rdb.MetadataEntity
+
+				Unit      sql.NullString `gorm:"size:64"`
+				DataType  string         `gorm:"not null;size:16"`
+				MinValue  sql.NullFloat64
+				MaxValue  sql.NullFloat64
+				Precision sql.NullInt64
+			}
+
+			err := tx.AutoMigrate(&MeasurementDefinition{})
+			if err != nil {
+				return err
+			}
+
+			// Nullable columns without defaults may be added to compressed hypertables.
+			return tx.Exec(`ALTER TABLE "event-management"."measurement_events"
+	ADD COLUMN IF NOT EXISTS unit varchar(64),
+	ADD COLUMN IF NOT EXISTS raw_value double precision,
+	ADD COLUMN IF NOT EXISTS raw_unit varchar(64),
+	ADD COLUMN IF NOT EXISTS suspect_reason varchar(32);`).Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			err := tx.Exec(`ALTER TABLE "event-management"."measurement_events"
+	DROP COLUMN IF EXISTS unit,
+	DROP COLUMN IF EXISTS raw_value,
+	DROP COLUMN IF EXISTS raw_unit,
+	DROP COLUMN IF EXISTS suspect_reason;`).Error
+			if err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable("measurement_definitions")
+		},
+	}
+}
+
+// Marks measurements with a position inferred from the last known device location.
+func NewMeasurementLocationSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018112000",
+		Migrate: func(tx *gorm.DB) error {
+			// Nullable columns without defaults may be added to compressed hypertables.
+			return tx.Exec(`ALTER TABLE "event-management"."measurement_events"
+	ADD COLUMN IF NOT EXISTS location_inferred boolean;`).Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Exec(`ALTER TABLE "event-management"."measurement_events"
+	DROP COLUMN IF EXISTS location_inferred;`).Error
+		},
+	}
+}
+
+// Recreate measurement rollups with the given filter and materialize them over all data (policies
+// only refresh recent buckets). Migrations do not run in a transaction, so rollups may be refreshed.
+func recreateMeasurementRollups(tx *gorm.DB, filter string) error {
+	err := dropMeasurementRollups(tx)
+	if err != nil {
+		return err
+	}
+	for _, statement := range measurementRollupStatements(filter) {
+		err = tx.Exec(statement).Error
+		if err != nil {
+			return err
+		}
+	}
+	for _, view := range []string{MEASUREMENT_ROLLUP_HOURLY, MEASUREMENT_ROLLUP_DAILY} {
+		err = tx.Exec(fmt.Sprintf("CALL refresh_continuous_aggregate('\"event-management\".\"%s\"', NULL, NULL);", view)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Recreates measurement rollups so that measurements flagged as suspect are left out.
+func NewMeasurementRollupSuspectSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018114000",
+		Migrate: func(tx *gorm.DB) error {
+			return recreateMeasurementRollups(tx, measurementRollupFilter)
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return recreateMeasurementRollups(tx, "")
+		},
+	}
+}
@@ -23,5 +23,26 @@ import (
 var (
 	Migrations = []*gormigrate.Migration{
 		NewInitialSchema(),
+		NewEventRelationshipsSchema(),
+		NewMeasurementSchema(),
+		NewMeasurementRollupSchema(),
+		NewLocationHypertableSchema(),
+		NewAlertSchema(),
+		NewChunkTuningSchema(),
+		NewLocationGeographySchema(),
+		NewGeofenceSchema(),
+		NewTripSchema(),
+		NewLocationDerivedSchema(),
+		NewLocationAttributesSchema(),
+		NewLocationSuspectSchema(),
+		NewLocationPlaceSchema(),
+		NewAreaDwellSchema(),
+		NewMeasurementDefinitionSchema(),
+		NewThresholdRuleSchema(),
+		NewAnomalySchema(),
+		NewVirtualMeasurementSchema(),
+		NewMeasurementLocationSchema(),
+		NewMeasurementRollupSuspectSchema(),
+		NewDwellPositionSchema(),
 	}
 )
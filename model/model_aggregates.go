@@ -0,0 +1,192 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"time"
+)
+
+const (
+	MEASUREMENT_ROLLUP_HOURLY = "measurement_stats_hourly"
+	MEASUREMENT_ROLLUP_DAILY  = "measurement_stats_daily"
+
+	MAX_GAPFILL_BUCKETS = 10000 // Upper bound on buckets per series for gap filled queries
+)
+
+// Methods for filling buckets without measurements.
+const (
+	GAPFILL_NULLS       = "NULLS"       // Statistics are null
+	GAPFILL_LOCF        = "LOCF"        // Last observation carried forward
+	GAPFILL_INTERPOLATE = "INTERPOLATE" // Linear interpolation between neighboring buckets
+)
+
+// Continuous aggregate that may be used in place of raw measurements.
+type MeasurementRollup struct {
+	View  string
+	Width time.Duration
+}
+
+// Rollups in order of preference (coarsest first).
+var MeasurementRollups = []MeasurementRollup{
+	{View: MEASUREMENT_ROLLUP_DAILY, Width: 24 * time.Hour},
+	{View: MEASUREMENT_ROLLUP_HOURLY, Width: time.Hour},
+}
+
+// Criteria for aggregating measurements into time buckets. If a unit is set, values are
+// converted to it and measurements in units that can not be converted are left out. If a gap
+// fill is set, every bucket in the time range is returned for each series. Virtual measurements
+// in the names are computed for buckets without stored values unless a unit or fill is set.
+type MeasurementAggregateCriteria struct {
+	DeviceIds []uint
+	Names     []string
+	StartTime time.Time
+	EndTime   time.Time
+	Bucket    time.Duration
+	Unit      *string
+	Fill      string
+}
+
+// Measurement statistics for a single device, measurement and time bucket. Statistics are
+// only null for gap filled buckets.
+type MeasurementAggregate struct {
+	DeviceId uint
+	Name     string
+	Bucket   time.Time
+	Count    int64
+	Min      *float64
+	Max      *float64
+	Avg      *float64
+	Sum      *float64
+}
+
+// Results of measurement aggregate query. Unit is set if values were converted.
+type MeasurementAggregateResults struct {
+	Results []MeasurementAggregate
+	Source  string
+	Unit    *string
+}
+
+// Groupings available for measurement distributions.
+const (
+	DISTRIBUTION_GROUP_NONE           = "NONE" // Measurements from all devices are combined
+	DISTRIBUTION_GROUP_DEVICE         = "DEVICE"
+	DISTRIBUTION_GROUP_DEVICE_GROUP   = "DEVICE_GROUP"
+	DISTRIBUTION_GROUP_ASSET          = "ASSET"
+	DISTRIBUTION_GROUP_ASSET_GROUP    = "ASSET_GROUP"
+	DISTRIBUTION_GROUP_CUSTOMER       = "CUSTOMER"
+	DISTRIBUTION_GROUP_CUSTOMER_GROUP = "CUSTOMER_GROUP"
+)
+
+const (
+	MAX_HISTOGRAM_BINS = 1000 // Upper bound on bins in a measurement histogram
+)
+
+// Percentiles computed if none are requested.
+var DefaultPercentiles = []float64{0.5, 0.9, 0.99}
+
+// Equal width bins between min and max used for histograms.
+type HistogramSettings struct {
+	Min  float64
+	Max  float64
+	Bins int32
+}
+
+// Criteria for computing percentiles and histograms of a measurement. Percentiles are fractions
+// between 0 and 1. If a unit is set, values are converted to it and measurements in units that
+// can not be converted are left out.
+type MeasurementDistributionCriteria struct {
+	Name             string
+	StartTime        time.Time
+	EndTime          time.Time
+	Bucket           time.Duration
+	GroupBy          string
+	DeviceIds        []uint
+	DeviceGroupIds   []uint
+	AssetIds         []uint
+	AssetGroupIds    []uint
+	CustomerIds      []uint
+	CustomerGroupIds []uint
+	Percentiles      []float64
+	Histogram        *HistogramSettings
+	Unit             *string
+}
+
+// Distribution of measurement values for a group and time bucket. Percentile values are in the
+// order requested. Histograms hold counts for values below min, each bin, and values at or above max.
+type MeasurementDistribution struct {
+	GroupId     *uint
+	Bucket      time.Time
+	Count       int64
+	Percentiles []float64
+	Histogram   []int64
+}
+
+// Results of measurement distribution query.
+type MeasurementDistributionResults struct {
+	Percentiles []float64
+	Histogram   *HistogramSettings
+	Unit        *string
+	Results     []MeasurementDistribution
+}
+
+const (
+	MAX_TREND_POINTS = 10000 // Upper bound on projected or forecast values in a trend query
+)
+
+// Smoothing factors (between 0 and 1) for a Holt-Winters forecast. Season length is the number of
+// buckets in a season (0 for a forecast without seasonality).
+type HoltWintersSettings struct {
+	Alpha        float64
+	Beta         float64
+	Gamma        float64
+	SeasonLength int32
+}
+
+// Criteria for fitting a trend to the measurement series of a device. Values are projected at
+// each bucket after the end time up to the horizon. A Holt-Winters forecast is computed from
+// bucket averages if settings are given.
+type MeasurementTrendCriteria struct {
+	DeviceId  uint
+	Name      string
+	StartTime time.Time
+	EndTime   time.Time
+	Bucket    time.Duration
+	Horizon   time.Duration
+	Threshold *float64
+	Forecast  *HoltWintersSettings
+}
+
+// Value of a measurement at a point in time.
+type TrendPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+// Least-squares trend of a measurement series. Slope is the change per hour and end value is the
+// fitted value at the end time. Threshold times are set if the trend or forecast reaches the
+// threshold (the trend only after the end time).
+type MeasurementTrend struct {
+	EndTime               time.Time
+	Count                 int64
+	Slope                 float64
+	EndValue              float64
+	RSquared              *float64
+	Projected             []TrendPoint
+	ThresholdTime         *time.Time
+	Forecast              []TrendPoint
+	ForecastThresholdTime *time.Time
+}
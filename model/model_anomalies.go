@@ -0,0 +1,58 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"time"
+
+	esmodel "github.com/devicechain-io/dc-event-sources/model"
+)
+
+// Rolling statistics (exponentially weighted mean and variance) for a device measurement.
+// Statistics are kept in memory while processing and saved periodically as snapshots.
+type MeasurementStatistics struct {
+	DeviceId    uint    `gorm:"primaryKey"`
+	Name        string  `gorm:"primaryKey;size:128"`
+	Mean        float64 `gorm:"not null"`
+	Variance    float64 `gorm:"not null"`
+	Count       int64   `gorm:"not null"`
+	UpdatedTime time.Time
+}
+
+// Anomaly event generated when a measurement deviates from its rolling statistics. The event
+// type is that of the measurement, so the base event is the one for the measurement itself.
+type AnomalyEvent struct {
+	DeviceId     uint              `gorm:"not null"`
+	EventType    esmodel.EventType `gorm:"not null"`
+	OccurredTime time.Time         `gorm:"not null"`
+	Event        Event             `gorm:"foreignKey:DeviceId,EventType,OccurredTime;References:DeviceId,EventType,OccurredTime"`
+	Name         string            `gorm:"not null;size:128"`
+	Value        float64           `gorm:"not null"`
+	Mean         float64           `gorm:"not null"`
+	StdDev       float64           `gorm:"not null"`
+	ZScore       float64           `gorm:"not null"`
+}
+
+// Information required to create an anomaly event.
+type AnomalyEventCreateRequest struct {
+	Event
+	Name   string
+	Value  float64
+	Mean   float64
+	StdDev float64
+	ZScore float64
+}
@@ -0,0 +1,86 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"time"
+)
+
+// Types of areas that dwell time is tracked for.
+const (
+	AREA_TYPE_AREA     = "AREA"     // Area related to the event
+	AREA_TYPE_GEOFENCE = "GEOFENCE" // Geofence containing the location
+)
+
+// Groupings available for dwell reports.
+const (
+	DWELL_GROUP_DEVICE   = "DEVICE"
+	DWELL_GROUP_ASSET    = "ASSET"
+	DWELL_GROUP_CUSTOMER = "CUSTOMER"
+)
+
+// Reference to an area or geofence.
+type AreaRef struct {
+	Type string
+	Id   uint
+}
+
+// Time (in seconds) a device spent inside an area on a day (UTC). Time is kept separately for
+// each asset and customer the device was related to while inside the area.
+type AreaDwell struct {
+	DeviceId      uint      `gorm:"not null"`
+	AreaType      string    `gorm:"not null;size:16"`
+	AreaId        uint      `gorm:"not null"`
+	Day           time.Time `gorm:"not null;type:date"`
+	Seconds       float64   `gorm:"not null"`
+	RelAssetId    *uint
+	RelCustomerId *uint
+}
+
+// Last location of a device counted toward dwell time along with the areas it was inside.
+type DwellPosition struct {
+	DeviceId      uint      `gorm:"primaryKey"`
+	Time          time.Time `gorm:"not null"`
+	Areas         []AreaRef `gorm:"serializer:json;type:jsonb"`
+	RelAssetId    *uint
+	RelCustomerId *uint
+}
+
+// Criteria for dwell reports. Days are inclusive. Results are totaled per group (device, asset or
+// customer) and area, and also per day if daily is set.
+type AreaDwellCriteria struct {
+	StartDay    time.Time
+	EndDay      time.Time
+	GroupBy     string
+	Daily       bool
+	AreaType    *string
+	AreaIds     []uint
+	DeviceIds   []uint
+	AssetIds    []uint
+	CustomerIds []uint
+}
+
+// Total dwell time for a group inside an area. The group id is the device, asset or customer id
+// (nil for dwell time without a related asset or customer).
+type AreaDwellTotal struct {
+	GroupId  *uint
+	AreaType string
+	AreaId   uint
+	Day      *time.Time
+	Seconds  float64
+	Devices  int64
+}
@@ -0,0 +1,129 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"time"
+)
+
+const (
+	DEFAULT_GEO_MAX_RESULTS = 1000
+	MAX_TRACK_POINTS        = 100000 // Upper bound on locations returned for a device track
+	DEFAULT_HEATMAP_CELLS   = 10000  // Cells returned for a heatmap if max results is not set
+
+	DEFAULT_FLEET_MAX_AGE = 24 * time.Hour // Age after which a device position is not included in snapshots
+	MAX_PLAYBACK_FRAMES   = 1000           // Upper bound on snapshots returned for fleet playback
+
+	MAX_GEOHASH_PRECISION = 12
+	MAX_H3_RESOLUTION     = 15
+)
+
+// Grid systems used to aggregate locations for heatmaps.
+const (
+	HEATMAP_GRID_GEOHASH = "GEOHASH"
+	HEATMAP_GRID_H3      = "H3" // Requires the h3 postgres extension
+)
+
+// Point on the earth in decimal degrees (WGS 84).
+type GeoPoint struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Bounding box in decimal degrees. A box where west is greater than east crosses the antimeridian.
+type GeoBoundingBox struct {
+	South float64
+	West  float64
+	North float64
+	East  float64
+}
+
+// Criteria shared by geospatial location queries.
+type LocationSearchCriteria struct {
+	StartTime        time.Time
+	EndTime          time.Time
+	DeviceIds        []uint
+	DeviceGroupIds   []uint
+	CustomerIds      []uint
+	CustomerGroupIds []uint
+	AreaIds          []uint
+	AreaGroupIds     []uint
+	MaxResults       int
+	IncludeSuspect   bool // Include rows flagged as suspect by validation
+}
+
+// Location event with relationships from the related event.
+type LocatedEvent struct {
+	DeviceId             uint
+	OccurredTime         time.Time
+	Source               string
+	Latitude             float64
+	Longitude            float64
+	Elevation            *float64
+	Accuracy             *float64
+	Speed                *float64
+	Course               *float64
+	Satellites           *int32
+	FixType              *string
+	ComputedSpeed        *float64
+	ComputedHeading      *float64
+	DistanceFromPrevious *float64
+	SuspectReason        *string
+	PlaceName            *string
+	CountryCode          *string
+	RegionCode           *string
+	RelCustomerId        *uint
+	RelCustomerGroupId   *uint
+	RelAreaId            *uint
+	RelAreaGroupId       *uint
+	RelAssetId           *uint
+}
+
+// Closest location reported by a device to a point of interest.
+type DeviceProximity struct {
+	LocatedEvent
+	Distance float64
+}
+
+// Last known location of a device at a snapshot time.
+type FleetPosition struct {
+	LocatedEvent
+	SnapshotTime time.Time
+}
+
+// Last known locations of devices at a point in time.
+type FleetSnapshot struct {
+	Time      time.Time
+	Locations []LocatedEvent
+}
+
+// Grid used to aggregate locations. Precision is the geohash length or the H3 resolution.
+type HeatmapGrid struct {
+	Type      string
+	Precision int
+}
+
+// Location counts (and optionally the average of a measurement) within a grid cell. The cell
+// position is its center.
+type HeatmapCell struct {
+	Cell               string
+	Latitude           float64
+	Longitude          float64
+	Count              int64
+	MeasurementAverage *float64
+	MeasurementCount   int64
+}
@@ -0,0 +1,137 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/geo"
+	esmodel "github.com/devicechain-io/dc-event-sources/model"
+	"github.com/devicechain-io/dc-microservice/rdb"
+	"gorm.io/gorm"
+)
+
+const (
+	GEOFENCE_ENTER = "enter"
+	GEOFENCE_EXIT  = "exit"
+)
+
+// Data required to create a geofence. Either vertices (polygon) or a center and radius in
+// meters (circle) must be provided.
+type GeofenceCreateRequest struct {
+	Token       string
+	Name        *string
+	Description *string
+	AreaId      *uint
+	Vertices    []GeoPoint
+	Center      *GeoPoint
+	Radius      *float64
+	Metadata    *string
+}
+
+// Represents a geofence. Fences linked to an area only apply to events related to that area.
+type Geofence struct {
+	gorm.Model
+	rdb.TokenReference
+	rdb.NamedEntity
+	rdb.MetadataEntity
+
+	AreaId          *uint
+	Vertices        sql.NullString `gorm:"type:text"`
+	CenterLatitude  sql.NullFloat64
+	CenterLongitude sql.NullFloat64
+	Radius          sql.NullFloat64
+}
+
+// Get polygon vertices for the geofence (empty for circles).
+func (g *Geofence) Polygon() ([]GeoPoint, error) {
+	vertices := make([]GeoPoint, 0)
+	if !g.Vertices.Valid {
+		return vertices, nil
+	}
+	err := json.Unmarshal([]byte(g.Vertices.String), &vertices)
+	if err != nil {
+		return nil, err
+	}
+	return vertices, nil
+}
+
+// Get shape used to evaluate whether points are inside the geofence.
+func (g *Geofence) Shape() (geo.Shape, error) {
+	if g.CenterLatitude.Valid && g.CenterLongitude.Valid && g.Radius.Valid {
+		return geo.Circle{
+			Center: geo.Point{Latitude: g.CenterLatitude.Float64, Longitude: g.CenterLongitude.Float64},
+			Radius: g.Radius.Float64,
+		}, nil
+	}
+	vertices, err := g.Polygon()
+	if err != nil {
+		return nil, err
+	}
+	if len(vertices) < 3 {
+		return nil, fmt.Errorf("geofence '%s' has no valid shape", g.Token)
+	}
+	polygon := make(geo.Polygon, 0, len(vertices))
+	for _, vertex := range vertices {
+		polygon = append(polygon, geo.Point{Latitude: vertex.Latitude, Longitude: vertex.Longitude})
+	}
+	return polygon, nil
+}
+
+// Search criteria for locating geofences.
+type GeofenceSearchCriteria struct {
+	rdb.Pagination
+	AreaId *uint
+}
+
+// Results for geofence search.
+type GeofenceSearchResults struct {
+	Results    []Geofence
+	Pagination rdb.SearchResultsPagination
+}
+
+// Last known state of a device relative to a geofence. Devices without a state are outside.
+type GeofenceState struct {
+	DeviceId     uint `gorm:"primaryKey"`
+	GeofenceId   uint `gorm:"primaryKey"`
+	Inside       bool `gorm:"not null"`
+	OccurredTime time.Time
+}
+
+// Geofence enter/exit event generated from location events.
+type GeofenceEvent struct {
+	DeviceId     uint              `gorm:"not null"`
+	EventType    esmodel.EventType `gorm:"not null"`
+	OccurredTime time.Time         `gorm:"not null"`
+	Event        Event             `gorm:"foreignKey:DeviceId,EventType,OccurredTime;References:DeviceId,EventType,OccurredTime"`
+	GeofenceId   uint              `gorm:"not null"`
+	Transition   string            `gorm:"not null;size:16"`
+	Latitude     float64
+	Longitude    float64
+}
+
+// Information required to create a geofence event.
+type GeofenceEventCreateRequest struct {
+	Event
+	GeofenceId uint
+	Transition string
+	Latitude   float64
+	Longitude  float64
+}
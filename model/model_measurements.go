@@ -0,0 +1,116 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/devicechain-io/dc-microservice/rdb"
+	"gorm.io/gorm"
+)
+
+// Data types for measurement values.
+const (
+	MEASUREMENT_TYPE_FLOAT   = "FLOAT"
+	MEASUREMENT_TYPE_INTEGER = "INTEGER"
+	MEASUREMENT_TYPE_BOOLEAN = "BOOLEAN" // Stored as 0 or 1
+)
+
+// Split a raw measurement name such as "temp:inDegreesCelcius" into a name ("temp") and unit
+// ("degreesCelcius"). The unit is empty if the raw name does not include one.
+func ParseMeasurementName(raw string) (string, string) {
+	name, unit := raw, ""
+	if index := strings.Index(raw, ":"); index >= 0 {
+		name, unit = raw[:index], raw[index+1:]
+	}
+	name = strings.TrimSpace(name)
+	unit = strings.TrimSpace(unit)
+
+	// Drop "in" prefix from units such as "inMilesPerHour" unless the unit is already known with
+	// the prefix (such as "inHg").
+	if _, known := builtInUnits.Lookup(unit); known {
+		return name, unit
+	}
+	if len(unit) > 2 && strings.HasPrefix(unit, "in") && unicode.IsUpper(rune(unit[2])) {
+		unit = string(unicode.ToLower(rune(unit[2]))) + unit[3:]
+	}
+	return name, unit
+}
+
+// Data required to create a measurement definition. The token is the measurement name.
+type MeasurementDefinitionCreateRequest struct {
+	Token       string
+	Name        *string
+	Description *string
+	Unit        *string
+	DataType    string
+	MinValue    *float64
+	MaxValue    *float64
+	Precision   *int64
+	Metadata    *string
+}
+
+// Describes a measurement reported by devices. The token is the measurement name, while the
+// name is used for display. Values outside of the valid range are considered invalid.
+type MeasurementDefinition struct {
+	gorm.Model
+	rdb.TokenReference
+	rdb.NamedEntity
+	rdb.MetadataEntity
+
+	Unit      sql.NullString `gorm:"size:64"`
+	DataType  string         `gorm:"not null;size:16"`
+	MinValue  sql.NullFloat64
+	MaxValue  sql.NullFloat64
+	Precision sql.NullInt64
+}
+
+// Check whether a value is valid for the measurement data type and range.
+func (d *MeasurementDefinition) Validate(value float64) error {
+	switch d.DataType {
+	case MEASUREMENT_TYPE_INTEGER:
+		if value != math.Trunc(value) {
+			return fmt.Errorf("measurement '%s' requires an integer value: %v", d.Token, value)
+		}
+	case MEASUREMENT_TYPE_BOOLEAN:
+		if value != 0 && value != 1 {
+			return fmt.Errorf("measurement '%s' requires a boolean (0 or 1) value: %v", d.Token, value)
+		}
+	}
+	if d.MinValue.Valid && value < d.MinValue.Float64 {
+		return fmt.Errorf("measurement '%s' is below minimum of %v: %v", d.Token, d.MinValue.Float64, value)
+	}
+	if d.MaxValue.Valid && value > d.MaxValue.Float64 {
+		return fmt.Errorf("measurement '%s' is above maximum of %v: %v", d.Token, d.MaxValue.Float64, value)
+	}
+	return nil
+}
+
+// Search criteria for locating measurement definitions.
+type MeasurementDefinitionSearchCriteria struct {
+	rdb.Pagination
+}
+
+// Results for measurement definition search.
+type MeasurementDefinitionSearchResults struct {
+	Results    []MeasurementDefinition
+	Pagination rdb.SearchResultsPagination
+}
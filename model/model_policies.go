@@ -0,0 +1,75 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"time"
+)
+
+const (
+	HYPERTABLE_EVENTS             = "events"
+	HYPERTABLE_LOCATION_EVENTS    = "location_events"
+	HYPERTABLE_MEASUREMENT_EVENTS = "measurement_events"
+	HYPERTABLE_ALERT_EVENTS       = "alert_events"
+	HYPERTABLE_GEOFENCE_EVENTS    = "geofence_events"
+	HYPERTABLE_ANOMALY_EVENTS     = "anomaly_events"
+
+	TABLE_MEASUREMENT_DEFINITIONS = "measurement_definitions"
+)
+
+// Hypertables holding event data.
+var EventHypertables = []string{
+	HYPERTABLE_EVENTS,
+	HYPERTABLE_LOCATION_EVENTS,
+	HYPERTABLE_MEASUREMENT_EVENTS,
+	HYPERTABLE_ALERT_EVENTS,
+	HYPERTABLE_GEOFENCE_EVENTS,
+	HYPERTABLE_ANOMALY_EVENTS,
+}
+
+// Desired interval for a policy on a hypertable or continuous aggregate (nil to remove policy).
+type PolicySetting struct {
+	Hypertable string
+	Interval   *string
+}
+
+// Policy job currently applied to a hypertable or continuous aggregate. Interval holds the
+// policy-specific setting (drop_after for retention, compress_after for compression).
+type HypertablePolicy struct {
+	Hypertable       string
+	JobId            int32
+	Interval         string
+	ScheduleInterval string
+	NextStart        *time.Time
+}
+
+// Compression statistics for a hypertable.
+type CompressionStats struct {
+	Hypertable       string
+	TotalChunks      int64
+	CompressedChunks int64
+	BeforeBytes      int64
+	AfterBytes       int64
+}
+
+// Ratio of uncompressed to compressed size for compressed chunks.
+func (stats CompressionStats) Ratio() float64 {
+	if stats.AfterBytes == 0 {
+		return 0
+	}
+	return float64(stats.BeforeBytes) / float64(stats.AfterBytes)
+}
@@ -0,0 +1,170 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/devicechain-io/dc-microservice/rdb"
+	"gorm.io/gorm"
+)
+
+// Operators used to compare measurement values against rule thresholds.
+const (
+	RULE_OPERATOR_GREATER_THAN     = "GREATER_THAN"
+	RULE_OPERATOR_GREATER_OR_EQUAL = "GREATER_OR_EQUAL"
+	RULE_OPERATOR_LESS_THAN        = "LESS_THAN"
+	RULE_OPERATOR_LESS_OR_EQUAL    = "LESS_OR_EQUAL"
+	RULE_OPERATOR_EQUAL            = "EQUAL"
+	RULE_OPERATOR_NOT_EQUAL        = "NOT_EQUAL"
+)
+
+// Transitions recorded on alerts generated by threshold rules.
+const (
+	RULE_ALERT_RAISED  = "raised"
+	RULE_ALERT_CLEARED = "cleared"
+)
+
+// Symbols used when describing rule operators in alert messages.
+var RuleOperatorSymbols = map[string]string{
+	RULE_OPERATOR_GREATER_THAN:     ">",
+	RULE_OPERATOR_GREATER_OR_EQUAL: ">=",
+	RULE_OPERATOR_LESS_THAN:        "<",
+	RULE_OPERATOR_LESS_OR_EQUAL:    "<=",
+	RULE_OPERATOR_EQUAL:            "=",
+	RULE_OPERATOR_NOT_EQUAL:        "!=",
+}
+
+// Compare a value against a threshold using a rule operator.
+func CompareThreshold(operator string, value float64, threshold float64) bool {
+	switch operator {
+	case RULE_OPERATOR_GREATER_THAN:
+		return value > threshold
+	case RULE_OPERATOR_GREATER_OR_EQUAL:
+		return value >= threshold
+	case RULE_OPERATOR_LESS_THAN:
+		return value < threshold
+	case RULE_OPERATOR_LESS_OR_EQUAL:
+		return value <= threshold
+	case RULE_OPERATOR_EQUAL:
+		return value == threshold
+	case RULE_OPERATOR_NOT_EQUAL:
+		return value != threshold
+	}
+	return false
+}
+
+// Data required to create a threshold rule. Group ids limit the rule to events related to
+// the given groups.
+type ThresholdRuleCreateRequest struct {
+	Token           string
+	Name            *string
+	Description     *string
+	Measurement     string
+	Operator        string
+	Threshold       float64
+	ClearThreshold  *float64
+	Unit            *string
+	Duration        time.Duration
+	ClearDuration   time.Duration
+	DeviceGroupId   *uint
+	AssetGroupId    *uint
+	CustomerGroupId *uint
+	AreaGroupId     *uint
+	AlertType       *string
+	AlertLevel      uint32
+	Enabled         bool
+	Metadata        *string
+}
+
+// Rule that raises an alert once a measurement meets a threshold for the duration (in seconds).
+// The alert is cleared once the measurement no longer meets the clear threshold (the threshold
+// if not set) for the clear duration, so a clear threshold past the threshold adds hysteresis.
+// Thresholds are in the rule unit if set (measurements are converted to it before comparison)
+// and otherwise in the unit measurements are stored in.
+type ThresholdRule struct {
+	gorm.Model
+	rdb.TokenReference
+	rdb.NamedEntity
+	rdb.MetadataEntity
+
+	Measurement     string  `gorm:"not null;size:128"`
+	Operator        string  `gorm:"not null;size:32"`
+	Threshold       float64 `gorm:"not null"`
+	ClearThreshold  sql.NullFloat64
+	Unit            sql.NullString `gorm:"size:64"`
+	Duration        int64          `gorm:"not null"`
+	ClearDuration   int64          `gorm:"not null"`
+	DeviceGroupId   *uint
+	AssetGroupId    *uint
+	CustomerGroupId *uint
+	AreaGroupId     *uint
+	AlertType       string `gorm:"not null;size:128"`
+	AlertLevel      uint32 `gorm:"not null"`
+	Enabled         bool   `gorm:"not null"`
+}
+
+// Indicates whether an optional group id matches the related group of an event.
+func groupMatches(group *uint, related *uint) bool {
+	return group == nil || (related != nil && *related == *group)
+}
+
+// Indicates whether the rule applies to a measurement for the given event.
+func (r *ThresholdRule) Applies(event Event, measurement string) bool {
+	return r.Measurement == measurement &&
+		groupMatches(r.DeviceGroupId, event.RelDeviceGroupId) &&
+		groupMatches(r.AssetGroupId, event.RelAssetGroupId) &&
+		groupMatches(r.CustomerGroupId, event.RelCustomerGroupId) &&
+		groupMatches(r.AreaGroupId, event.RelAreaGroupId)
+}
+
+// Indicates whether a value meets the threshold.
+func (r *ThresholdRule) Triggered(value float64) bool {
+	return CompareThreshold(r.Operator, value, r.Threshold)
+}
+
+// Indicates whether a value allows an active alert to clear.
+func (r *ThresholdRule) Cleared(value float64) bool {
+	threshold := r.Threshold
+	if r.ClearThreshold.Valid {
+		threshold = r.ClearThreshold.Float64
+	}
+	return !CompareThreshold(r.Operator, value, threshold)
+}
+
+// Search criteria for locating threshold rules.
+type ThresholdRuleSearchCriteria struct {
+	rdb.Pagination
+	Measurement *string
+}
+
+// Results for threshold rule search.
+type ThresholdRuleSearchResults struct {
+	Results    []ThresholdRule
+	Pagination rdb.SearchResultsPagination
+}
+
+// State of a device relative to a threshold rule. The pending time is when the current
+// condition (trigger if inactive, clear if active) was first met.
+type ThresholdRuleState struct {
+	DeviceId     uint `gorm:"primaryKey"`
+	RuleId       uint `gorm:"primaryKey"`
+	Active       bool `gorm:"not null"`
+	PendingSince sql.NullTime
+	OccurredTime time.Time
+}
@@ -0,0 +1,100 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"time"
+
+	"github.com/devicechain-io/dc-microservice/rdb"
+)
+
+// Period during which a device was moving between stops.
+type Trip struct {
+	ID                 uint `gorm:"primaryKey"`
+	DeviceId           uint `gorm:"not null"`
+	RelCustomerId      *uint
+	RelCustomerGroupId *uint
+	RelAreaId          *uint
+	RelAssetId         *uint
+	StartTime          time.Time `gorm:"not null"`
+	EndTime            time.Time `gorm:"not null"`
+	StartLatitude      float64
+	StartLongitude     float64
+	EndLatitude        float64
+	EndLongitude       float64
+	Distance           float64
+	Active             bool `gorm:"not null"`
+}
+
+// Duration of the trip.
+func (trip *Trip) Duration() time.Duration {
+	return trip.EndTime.Sub(trip.StartTime)
+}
+
+// Period during which a device stayed in one place.
+type Stop struct {
+	ID                 uint `gorm:"primaryKey"`
+	DeviceId           uint `gorm:"not null"`
+	RelCustomerId      *uint
+	RelCustomerGroupId *uint
+	RelAreaId          *uint
+	RelAssetId         *uint
+	StartTime          time.Time `gorm:"not null"`
+	EndTime            time.Time `gorm:"not null"`
+	Latitude           float64
+	Longitude          float64
+	Active             bool `gorm:"not null"`
+}
+
+// Duration of the stop.
+func (stop *Stop) Duration() time.Duration {
+	return stop.EndTime.Sub(stop.StartTime)
+}
+
+// Incremental trip detection state for a device. The anchor is the point where the device
+// began dwelling (while moving) or the location of the current stop (while stationary).
+type TripDetectorState struct {
+	DeviceId        uint `gorm:"primaryKey"`
+	Moving          bool `gorm:"not null"`
+	LastTime        time.Time
+	LastLatitude    float64
+	LastLongitude   float64
+	AnchorTime      time.Time
+	AnchorLatitude  float64
+	AnchorLongitude float64
+}
+
+// Search criteria for locating trips or stops. Results overlap the time range if given.
+type TripSearchCriteria struct {
+	rdb.Pagination
+	DeviceIds   []uint
+	CustomerIds []uint
+	StartTime   *time.Time
+	EndTime     *time.Time
+}
+
+// Results for trip search.
+type TripSearchResults struct {
+	Results    []Trip
+	Pagination rdb.SearchResultsPagination
+}
+
+// Results for stop search.
+type StopSearchResults struct {
+	Results    []Stop
+	Pagination rdb.SearchResultsPagination
+}
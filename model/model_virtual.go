@@ -0,0 +1,63 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"database/sql"
+
+	"github.com/devicechain-io/dc-microservice/rdb"
+	"gorm.io/gorm"
+)
+
+const (
+	MAX_VIRTUAL_INPUT_VALUES = 100000 // Upper bound on input values read to compute virtual measurement aggregates
+)
+
+// Data required to create a virtual measurement. The token is the name of the computed measurement.
+type VirtualMeasurementCreateRequest struct {
+	Token       string
+	Name        *string
+	Description *string
+	Expression  string
+	Unit        *string
+	Enabled     bool
+	Metadata    *string
+}
+
+// Measurement computed from an expression over other measurements of the same device (for
+// example "voltage * current"). The token is the name of the computed measurement.
+type VirtualMeasurement struct {
+	gorm.Model
+	rdb.TokenReference
+	rdb.NamedEntity
+	rdb.MetadataEntity
+
+	Expression string         `gorm:"not null;size:1024"`
+	Unit       sql.NullString `gorm:"size:64"`
+	Enabled    bool           `gorm:"not null"`
+}
+
+// Search criteria for locating virtual measurements.
+type VirtualMeasurementSearchCriteria struct {
+	rdb.Pagination
+}
+
+// Results for virtual measurement search.
+type VirtualMeasurementSearchResults struct {
+	Results    []VirtualMeasurement
+	Pagination rdb.SearchResultsPagination
+}
@@ -0,0 +1,102 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	gormigrate "github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	CHUNK_TUNING_PROCEDURE = "tune_chunk_interval"
+)
+
+// Statement that creates the procedure run as a timescaledb job to size chunks based on observed
+// ingest rate. The ingest rate (bytes per second of chunk time range) is measured over the most
+// recent uncompressed time slices and the chunk interval is set so new chunks approach the target
+// size. When no target is configured, a share of shared_buffers is used so that recent chunks of
+// all tuned hypertables stay in memory.
+func chunkTuningProcedureStatement() string {
+	return `CREATE OR REPLACE PROCEDURE "event-management"."` + CHUNK_TUNING_PROCEDURE + `"(job_id int, config jsonb)
+LANGUAGE plpgsql AS $$
+DECLARE
+	ht_schema text := config->>'hypertable_schema';
+	ht_name text := config->>'hypertable_name';
+	ht regclass := format('%I.%I', ht_schema, ht_name)::regclass;
+	target numeric := (config->>'target_bytes')::numeric;
+	min_interval interval := COALESCE((config->>'min_interval')::interval, INTERVAL '1 hour');
+	max_interval interval := COALESCE((config->>'max_interval')::interval, INTERVAL '7 days');
+	current_interval interval;
+	total_bytes numeric;
+	total_seconds numeric;
+	proposed interval;
+BEGIN
+	IF target IS NULL OR target <= 0 THEN
+		target := pg_size_bytes(current_setting('shared_buffers')) / 4
+			/ GREATEST(COALESCE((config->>'share')::int, 1), 1);
+	END IF;
+
+	SELECT time_interval INTO current_interval FROM timescaledb_information.dimensions
+	WHERE hypertable_schema = ht_schema AND hypertable_name = ht_name AND dimension_type = 'Time';
+
+	-- Chunks in the same time slice (one per device partition) share a time range, so bytes are
+	-- summed across all chunks of the slices while time is only counted once per slice.
+	WITH slices AS (
+		SELECT DISTINCT range_start, range_end FROM timescaledb_information.chunks
+		WHERE hypertable_schema = ht_schema AND hypertable_name = ht_name
+		AND range_end < now() AND NOT is_compressed
+		ORDER BY range_end DESC LIMIT 10
+	)
+	SELECT (SELECT sum(s.total_bytes)
+			FROM timescaledb_information.chunks c
+			JOIN slices ON slices.range_start = c.range_start AND slices.range_end = c.range_end
+			JOIN chunks_detailed_size(ht) s ON s.chunk_schema = c.chunk_schema AND s.chunk_name = c.chunk_name
+			WHERE c.hypertable_schema = ht_schema AND c.hypertable_name = ht_name AND NOT c.is_compressed),
+		(SELECT sum(extract(epoch FROM range_end - range_start)) FROM slices)
+	INTO total_bytes, total_seconds;
+
+	IF total_bytes IS NULL OR total_bytes = 0 OR total_seconds IS NULL OR total_seconds = 0 THEN
+		RETURN;
+	END IF;
+
+	proposed := make_interval(secs => (target / (total_bytes / total_seconds))::double precision);
+	proposed := GREATEST(min_interval, LEAST(max_interval, proposed));
+
+	-- Ignore small changes to avoid churning chunk sizes.
+	IF current_interval IS NOT NULL AND
+		abs(extract(epoch FROM proposed) - extract(epoch FROM current_interval)) < extract(epoch FROM current_interval) * 0.2 THEN
+		RETURN;
+	END IF;
+
+	PERFORM set_chunk_time_interval(ht, proposed);
+	RAISE LOG 'Chunk interval for % changed from % to %', ht, current_interval, proposed;
+END
+$$;`
+}
+
+// Creates the chunk interval tuning procedure.
+func NewChunkTuningSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018095000",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Exec(chunkTuningProcedureStatement()).Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Exec(`DROP PROCEDURE IF EXISTS "event-management"."` + CHUNK_TUNING_PROCEDURE + `"(int, jsonb);`).Error
+		},
+	}
+}
@@ -0,0 +1,88 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/devicechain-io/dc-microservice/rdb"
+	gormigrate "github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Creates threshold rules and per-device rule state, and adds the rule that generated an
+// alert along with the triggering value.
+func NewThresholdRuleSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018109000",
+		Migrate: func(tx *gorm.DB) error {
+			// Threshold rule definition.
+			type ThresholdRule struct {
+				gorm.Model
+				rdb.TokenReference
+				rdb.NamedEntity
+				rdb.MetadataEntity
+
+				Measurement     string  `gorm:"not null;size:128"`
+				Operator        string  `gorm:"not null;size:32"`
+				Threshold       float64 `gorm:"not null"`
+				ClearThreshold  sql.NullFloat64
+				Unit            sql.NullString `gorm:"size:64"`
+				Duration        int64          `gorm:"not null"`
+				ClearDuration   int64          `gorm:"not null"`
+				DeviceGroupId   *uint
+				AssetGroupId    *uint
+				CustomerGroupId *uint
+				AreaGroupId     *uint
+				AlertType       string `gorm:"not null;size:128"`
+				AlertLevel      uint32 `gorm:"not null"`
+				Enabled         bool   `gorm:"not null"`
+			}
+
+			// State of a device relative to a threshold rule.
+			type ThresholdRuleState struct {
+				DeviceId     uint `gorm:"primaryKey"`
+				RuleId       uint `gorm:"primaryKey"`
+				Active       bool `gorm:"not null"`
+				PendingSince sql.NullTime
+				OccurredTime time.Time
+			}
+
+			err := tx.AutoMigrate(&ThresholdRule{}, &ThresholdRuleState{})
+			if err != nil {
+				return err
+			}
+
+			// Nullable columns without defaults may be added to compressed hypertables.
+			return tx.Exec(`ALTER TABLE "event-management"."alert_events"
+	ADD COLUMN IF NOT EXISTS rule_id bigint,
+	ADD COLUMN IF NOT EXISTS trigger_value double precision,
+	ADD COLUMN IF NOT EXISTS rule_transition varchar(16);`).Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			err := tx.Exec(`ALTER TABLE "event-management"."alert_events"
+	DROP COLUMN IF EXISTS rule_id,
+	DROP COLUMN IF EXISTS trigger_value,
+	DROP COLUMN IF EXISTS rule_transition;`).Error
+			if err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable("threshold_rule_states", "threshold_rules")
+		},
+	}
+}
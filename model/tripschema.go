@@ -0,0 +1,98 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"time"
+
+	gormigrate "github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Creates storage for trips, stops and trip detection state.
+func NewTripSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018102000",
+		Migrate: func(tx *gorm.DB) error {
+			// Period during which a device was moving between stops.
+			type Trip struct {
+				ID                 uint `gorm:"primaryKey"`
+				DeviceId           uint `gorm:"not null"`
+				RelCustomerId      *uint
+				RelCustomerGroupId *uint
+				RelAreaId          *uint
+				RelAssetId         *uint
+				StartTime          time.Time `gorm:"not null"`
+				EndTime            time.Time `gorm:"not null"`
+				StartLatitude      float64
+				StartLongitude     float64
+				EndLatitude        float64
+				EndLongitude       float64
+				Distance           float64
+				Active             bool `gorm:"not null"`
+			}
+
+			// Period during which a device stayed in one place.
+			type Stop struct {
+				ID                 uint `gorm:"primaryKey"`
+				DeviceId           uint `gorm:"not null"`
+				RelCustomerId      *uint
+				RelCustomerGroupId *uint
+				RelAreaId          *uint
+				RelAssetId         *uint
+				StartTime          time.Time `gorm:"not null"`
+				EndTime            time.Time `gorm:"not null"`
+				Latitude           float64
+				Longitude          float64
+				Active             bool `gorm:"not null"`
+			}
+
+			// Incremental trip detection state for a device.
+			type TripDetectorState struct {
+				DeviceId        uint `gorm:"primaryKey"`
+				Moving          bool `gorm:"not null"`
+				LastTime        time.Time
+				LastLatitude    float64
+				LastLongitude   float64
+				AnchorTime      time.Time
+				AnchorLatitude  float64
+				AnchorLongitude float64
+			}
+
+			err := tx.AutoMigrate(&Trip{}, &Stop{}, &TripDetectorState{})
+			if err != nil {
+				return err
+			}
+
+			// Add indexes for device and customer queries.
+			for _, table := range []string{"trips", "stops"} {
+				err = tx.Exec("CREATE INDEX ON \"event-management\".\"" + table + "\" (device_id, start_time DESC);").Error
+				if err != nil {
+					return err
+				}
+				err = tx.Exec("CREATE INDEX ON \"event-management\".\"" + table + "\" (rel_customer_id, start_time DESC);").Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("trip_detector_states", "stops", "trips")
+		},
+	}
+}
@@ -0,0 +1,214 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Physical quantities covered by the built-in unit table.
+const (
+	QUANTITY_TEMPERATURE = "temperature" // Base unit is celsius
+	QUANTITY_SPEED       = "speed"       // Base unit is meters per second
+	QUANTITY_LENGTH      = "length"      // Base unit is meters
+	QUANTITY_PRESSURE    = "pressure"    // Base unit is pascals
+	QUANTITY_MASS        = "mass"        // Base unit is kilograms
+	QUANTITY_VOLUME      = "volume"      // Base unit is liters
+)
+
+// Unit of a physical quantity. Values are converted to the base unit of the quantity as
+// value * factor + offset. Units are matched case-insensitively by name or alias.
+type UnitDefinition struct {
+	Quantity string
+	Unit     string
+	Aliases  []string
+	Factor   float64
+	Offset   float64
+}
+
+// Linear conversion from a source unit (value * scale + offset).
+type UnitConversion struct {
+	Unit   string
+	Scale  float64
+	Offset float64
+}
+
+// Units that are built in to the conversion table.
+var BuiltInUnits = []UnitDefinition{
+	{Quantity: QUANTITY_TEMPERATURE, Unit: "celsius", Factor: 1,
+		Aliases: []string{"c", "°c", "degc", "degreesCelsius", "degreesCelcius", "celcius"}},
+	{Quantity: QUANTITY_TEMPERATURE, Unit: "fahrenheit", Factor: 5.0 / 9.0, Offset: -32 * 5.0 / 9.0,
+		Aliases: []string{"f", "°f", "degf", "degreesFahrenheit"}},
+	{Quantity: QUANTITY_TEMPERATURE, Unit: "kelvin", Factor: 1, Offset: -273.15,
+		Aliases: []string{"k", "degreesKelvin"}},
+
+	{Quantity: QUANTITY_SPEED, Unit: "m/s", Factor: 1,
+		Aliases: []string{"mps", "metersPerSecond", "metresPerSecond"}},
+	{Quantity: QUANTITY_SPEED, Unit: "km/h", Factor: 1 / 3.6,
+		Aliases: []string{"kph", "kmh", "kilometersPerHour", "kilometresPerHour"}},
+	{Quantity: QUANTITY_SPEED, Unit: "mph", Factor: 0.44704,
+		Aliases: []string{"milesPerHour"}},
+	{Quantity: QUANTITY_SPEED, Unit: "knots", Factor: 1852.0 / 3600.0,
+		Aliases: []string{"kn", "kt", "knot"}},
+
+	{Quantity: QUANTITY_LENGTH, Unit: "meters", Factor: 1,
+		Aliases: []string{"m", "meter", "metre", "metres"}},
+	{Quantity: QUANTITY_LENGTH, Unit: "kilometers", Factor: 1000,
+		Aliases: []string{"km", "kilometer", "kilometre", "kilometres"}},
+	{Quantity: QUANTITY_LENGTH, Unit: "miles", Factor: 1609.344,
+		Aliases: []string{"mi", "mile"}},
+	{Quantity: QUANTITY_LENGTH, Unit: "feet", Factor: 0.3048,
+		Aliases: []string{"ft", "foot"}},
+	{Quantity: QUANTITY_LENGTH, Unit: "nauticalMiles", Factor: 1852,
+		Aliases: []string{"nmi", "nauticalMile"}},
+
+	{Quantity: QUANTITY_PRESSURE, Unit: "pascals", Factor: 1,
+		Aliases: []string{"pa", "pascal"}},
+	{Quantity: QUANTITY_PRESSURE, Unit: "hectopascals", Factor: 100,
+		Aliases: []string{"hpa", "mbar", "millibars"}},
+	{Quantity: QUANTITY_PRESSURE, Unit: "kilopascals", Factor: 1000,
+		Aliases: []string{"kpa"}},
+	{Quantity: QUANTITY_PRESSURE, Unit: "bar", Factor: 100000,
+		Aliases: []string{"bars"}},
+	{Quantity: QUANTITY_PRESSURE, Unit: "psi", Factor: 6894.757293168,
+		Aliases: []string{"poundsPerSquareInch"}},
+	{Quantity: QUANTITY_PRESSURE, Unit: "inHg", Factor: 3386.389,
+		Aliases: []string{"inchesOfMercury"}},
+
+	{Quantity: QUANTITY_MASS, Unit: "kilograms", Factor: 1,
+		Aliases: []string{"kg", "kilogram"}},
+	{Quantity: QUANTITY_MASS, Unit: "grams", Factor: 0.001,
+		Aliases: []string{"g", "gram"}},
+	{Quantity: QUANTITY_MASS, Unit: "pounds", Factor: 0.45359237,
+		Aliases: []string{"lb", "lbs", "pound"}},
+	{Quantity: QUANTITY_MASS, Unit: "ounces", Factor: 0.028349523125,
+		Aliases: []string{"oz", "ounce"}},
+
+	{Quantity: QUANTITY_VOLUME, Unit: "liters", Factor: 1,
+		Aliases: []string{"l", "liter", "litre", "litres"}},
+	{Quantity: QUANTITY_VOLUME, Unit: "milliliters", Factor: 0.001,
+		Aliases: []string{"ml", "milliliter", "millilitre", "millilitres"}},
+	{Quantity: QUANTITY_VOLUME, Unit: "gallons", Factor: 3.785411784,
+		Aliases: []string{"gal", "gallon"}},
+	{Quantity: QUANTITY_VOLUME, Unit: "cubicMeters", Factor: 1000,
+		Aliases: []string{"m3", "cubicMeter", "cubicMetres"}},
+}
+
+// Converter with only the built-in units.
+var builtInUnits = NewUnitConverter()
+
+// Normalize a unit name for matching.
+func unitKey(unit string) string {
+	return strings.ToLower(strings.TrimSpace(unit))
+}
+
+// Converts values between units of the same quantity. Units are registered on startup and
+// the converter is read-only afterward.
+type UnitConverter struct {
+	units map[string]UnitDefinition
+}
+
+// Create a unit converter populated with the built-in units.
+func NewUnitConverter() *UnitConverter {
+	uc := &UnitConverter{units: make(map[string]UnitDefinition)}
+	err := uc.Register(BuiltInUnits...)
+	if err != nil {
+		panic(err)
+	}
+	return uc
+}
+
+// Add units to the conversion table. Names and aliases that are already registered are
+// replaced so that built-in units may be overridden.
+func (uc *UnitConverter) Register(units ...UnitDefinition) error {
+	for _, unit := range units {
+		if unit.Quantity == "" || unitKey(unit.Unit) == "" {
+			return fmt.Errorf("unit definitions require a quantity and unit name")
+		}
+		if unit.Factor == 0 {
+			return fmt.Errorf("unit '%s' requires a non-zero factor", unit.Unit)
+		}
+		uc.units[unitKey(unit.Unit)] = unit
+		for _, alias := range unit.Aliases {
+			uc.units[unitKey(alias)] = unit
+		}
+	}
+	return nil
+}
+
+// Find the definition for a unit name or alias.
+func (uc *UnitConverter) Lookup(unit string) (*UnitDefinition, bool) {
+	if found, ok := uc.units[unitKey(unit)]; ok {
+		return &found, true
+	}
+	return nil, false
+}
+
+// Get the linear conversion between two units of the same quantity.
+func (uc *UnitConverter) Conversion(from string, to string) (*UnitConversion, error) {
+	source, ok := uc.Lookup(from)
+	if !ok {
+		return nil, fmt.Errorf("unknown unit: %s", from)
+	}
+	target, ok := uc.Lookup(to)
+	if !ok {
+		return nil, fmt.Errorf("unknown unit: %s", to)
+	}
+	if source.Quantity != target.Quantity {
+		return nil, fmt.Errorf("unable to convert %s (%s) to %s (%s)", from, source.Quantity, to, target.Quantity)
+	}
+	return &UnitConversion{
+		Unit:   unitKey(from),
+		Scale:  source.Factor / target.Factor,
+		Offset: (source.Offset - target.Offset) / target.Factor,
+	}, nil
+}
+
+// Convert a value between two units of the same quantity.
+func (uc *UnitConverter) Convert(value float64, from string, to string) (float64, error) {
+	conversion, err := uc.Conversion(from, to)
+	if err != nil {
+		return 0, err
+	}
+	return value*conversion.Scale + conversion.Offset, nil
+}
+
+// Get conversions to a target unit for every registered name and alias of the same quantity.
+// Conversions are keyed by normalized (lowercase) unit name and sorted for stable queries.
+func (uc *UnitConverter) ConversionsTo(to string) ([]UnitConversion, error) {
+	target, ok := uc.Lookup(to)
+	if !ok {
+		return nil, fmt.Errorf("unknown unit: %s", to)
+	}
+	conversions := make([]UnitConversion, 0)
+	for key, unit := range uc.units {
+		if unit.Quantity != target.Quantity {
+			continue
+		}
+		conversion, err := uc.Conversion(key, to)
+		if err != nil {
+			return nil, err
+		}
+		conversions = append(conversions, *conversion)
+	}
+	sort.Slice(conversions, func(i, j int) bool {
+		return conversions[i].Unit < conversions[j].Unit
+	})
+	return conversions, nil
+}
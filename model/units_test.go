@@ -0,0 +1,65 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test conversion between built-in and registered units.
+func TestUnitConversion(t *testing.T) {
+	units := NewUnitConverter()
+
+	// Offsets are applied for temperatures.
+	value, err := units.Convert(100, "celsius", "fahrenheit")
+	assert.Nil(t, err)
+	assert.InDelta(t, 212, value, 1e-9)
+	value, err = units.Convert(32, "degreesFahrenheit", "K")
+	assert.Nil(t, err)
+	assert.InDelta(t, 273.15, value, 1e-9)
+
+	// Units are matched by alias regardless of case.
+	value, err = units.Convert(100, "KPH", "milesPerHour")
+	assert.Nil(t, err)
+	assert.InDelta(t, 62.1371, value, 1e-4)
+
+	// Units of different quantities can not be converted.
+	_, err = units.Convert(1, "km", "kg")
+	assert.NotNil(t, err)
+	_, err = units.Convert(1, "furlongs", "m")
+	assert.NotNil(t, err)
+
+	// Registered units extend the table.
+	assert.Nil(t, units.Register(UnitDefinition{Quantity: QUANTITY_LENGTH, Unit: "furlongs", Factor: 201.168}))
+	value, err = units.Convert(1, "furlongs", "m")
+	assert.Nil(t, err)
+	assert.InDelta(t, 201.168, value, 1e-9)
+	assert.NotNil(t, units.Register(UnitDefinition{Quantity: QUANTITY_LENGTH, Unit: "broken"}))
+
+	// Conversions to a unit cover every alias of the quantity.
+	conversions, err := units.ConversionsTo("c")
+	assert.Nil(t, err)
+	found := make(map[string]UnitConversion)
+	for _, conversion := range conversions {
+		found[conversion.Unit] = conversion
+	}
+	assert.Contains(t, found, "degreescelcius")
+	assert.InDelta(t, -273.15, found["kelvin"].Offset, 1e-9)
+	assert.NotContains(t, found, "mph")
+}
@@ -0,0 +1,50 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"database/sql"
+
+	"github.com/devicechain-io/dc-microservice/rdb"
+	gormigrate "github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Creates virtual measurements computed from expressions over other measurements.
+func NewVirtualMeasurementSchema() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "20261018111000",
+		Migrate: func(tx *gorm.DB) error {
+			// Measurement computed from an expression.
+			type VirtualMeasurement struct {
+				gorm.Model
+				rdb.TokenReference
+				rdb.NamedEntity
+				rdb.MetadataEntity
+
+				Expression string         `gorm:"not null;size:1024"`
+				Unit       sql.NullString `gorm:"size:64"`
+				Enabled    bool           `gorm:"not null"`
+			}
+
+			return tx.AutoMigrate(&VirtualMeasurement{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("virtual_measurements")
+		},
+	}
+}
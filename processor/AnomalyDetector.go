@@ -0,0 +1,229 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	esmodel "github.com/devicechain-io/dc-event-sources/model"
+)
+
+// Settings that control anomaly detection.
+type AnomalySettings struct {
+	Alpha            float64
+	ZScore           float64
+	Warmup           int64
+	SnapshotInterval time.Duration
+}
+
+// Identifies the series for a device measurement.
+type seriesKey struct {
+	DeviceId uint
+	Name     string
+}
+
+// Statistics for measurements of a device along with names of series changed since the last
+// snapshot.
+type deviceSeries struct {
+	Stats map[string]*model.MeasurementStatistics
+	Dirty map[string]bool
+}
+
+// Flags measurements that deviate from rolling per-device, per-measurement statistics
+// (exponentially weighted mean and variance), so each sensor is judged against its own
+// baseline. Statistics are held in memory and saved periodically, so updates since the last
+// snapshot are lost on restart. A single detector is shared by all workers. Updates are
+// serialized per device and statistics for idle devices are released once saved.
+type AnomalyDetector struct {
+	Api      model.EventManagementApi
+	Settings AnomalySettings
+
+	lock     sync.Mutex
+	snapshot time.Time
+	flushing sync.Mutex
+	devices  *deviceStates
+}
+
+// Create a new anomaly detector.
+func NewAnomalyDetector(api model.EventManagementApi, settings AnomalySettings) *AnomalyDetector {
+	return &AnomalyDetector{
+		Api:      api,
+		Settings: settings,
+		snapshot: time.Now(),
+		devices:  newDeviceStates(DEVICE_STATE_IDLE_TIME),
+	}
+}
+
+// Get statistics for a device (with its entry locked), loading saved snapshots on first use.
+func (ad *AnomalyDetector) deviceSeries(ctx context.Context, entry *deviceEntry, deviceId uint) (*deviceSeries, error) {
+	if series, ok := entry.State.(*deviceSeries); ok {
+		return series, nil
+	}
+	found, err := ad.Api.MeasurementStatistics(ctx, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	series := &deviceSeries{
+		Stats: make(map[string]*model.MeasurementStatistics),
+		Dirty: make(map[string]bool),
+	}
+	for i := range found {
+		series.Stats[found[i].Name] = &found[i]
+	}
+	entry.State = series
+	return series, nil
+}
+
+// Add a value to rolling statistics.
+func updateStatistics(stats *model.MeasurementStatistics, value float64, alpha float64) {
+	if stats.Count == 0 {
+		stats.Mean = value
+		stats.Variance = 0
+	} else {
+		diff := value - stats.Mean
+		increment := alpha * diff
+		stats.Mean += increment
+		stats.Variance = (1 - alpha) * (stats.Variance + diff*increment)
+	}
+	stats.Count++
+}
+
+// Check a measurement against the rolling statistics for its series, then add it to them.
+// Returns the anomaly event generated (nil if the value is within range or the series is still
+// warming up). Measurements older than the last one seen for a series are ignored. Anomalies
+// refer to the event of the measurement they were detected in.
+func (ad *AnomalyDetector) Update(ctx context.Context, event model.Event, name string,
+	value float64) (*model.AnomalyEvent, error) {
+	created, err := ad.update(ctx, event, name, value)
+	if err != nil {
+		return nil, err
+	}
+	if ad.snapshotDue() {
+		err = ad.Flush(ctx)
+		if err != nil {
+			return created, err
+		}
+	}
+	return created, nil
+}
+
+// Update statistics for a measurement with the device entry locked.
+func (ad *AnomalyDetector) update(ctx context.Context, event model.Event, name string,
+	value float64) (*model.AnomalyEvent, error) {
+	entry := ad.devices.acquire(event.DeviceId)
+	defer ad.devices.release(entry)
+
+	series, err := ad.deviceSeries(ctx, entry, event.DeviceId)
+	if err != nil {
+		return nil, err
+	}
+	stats := series.Stats[name]
+	if stats == nil {
+		stats = &model.MeasurementStatistics{DeviceId: event.DeviceId, Name: name}
+		series.Stats[name] = stats
+	}
+	if event.OccurredTime.Before(stats.UpdatedTime) {
+		return nil, nil
+	}
+
+	// Score against statistics before the value is added.
+	var request *model.AnomalyEventCreateRequest
+	stddev := math.Sqrt(stats.Variance)
+	if stats.Count >= ad.Settings.Warmup && stddev > 0 {
+		zscore := (value - stats.Mean) / stddev
+		if math.Abs(zscore) >= ad.Settings.ZScore {
+			aevent := event
+			aevent.EventType = esmodel.Measurement
+			request = &model.AnomalyEventCreateRequest{
+				Event:  aevent,
+				Name:   name,
+				Value:  value,
+				Mean:   stats.Mean,
+				StdDev: stddev,
+				ZScore: zscore,
+			}
+		}
+	}
+
+	updateStatistics(stats, value, ad.Settings.Alpha)
+	stats.UpdatedTime = event.OccurredTime
+	series.Dirty[name] = true
+	ad.devices.pin(entry, true)
+
+	if request == nil {
+		return nil, nil
+	}
+	return ad.Api.CreateAnomalyEvent(ctx, request)
+}
+
+// Indicates whether statistics are due to be saved. Claims the snapshot so that only one
+// worker saves statistics per interval.
+func (ad *AnomalyDetector) snapshotDue() bool {
+	ad.lock.Lock()
+	defer ad.lock.Unlock()
+	if time.Since(ad.snapshot) < ad.Settings.SnapshotInterval {
+		return false
+	}
+	ad.snapshot = time.Now()
+	return true
+}
+
+// Save statistics that changed since the last snapshot. Changes are copied with each device
+// locked in turn and saved without holding any locks. Devices with no further changes are
+// unpinned so that their statistics are released once idle.
+func (ad *AnomalyDetector) Flush(ctx context.Context) error {
+	ad.flushing.Lock()
+	defer ad.flushing.Unlock()
+
+	entries := ad.devices.pinnedEntries()
+	snapshots := make([]model.MeasurementStatistics, 0)
+	for _, entry := range entries {
+		entry.lock.Lock()
+		if series, ok := entry.State.(*deviceSeries); ok {
+			for name := range series.Dirty {
+				snapshots = append(snapshots, *series.Stats[name])
+			}
+		}
+		entry.lock.Unlock()
+	}
+	err := ad.Api.SaveMeasurementStatistics(ctx, snapshots)
+
+	// Series updated while saving stay dirty for the next snapshot.
+	saved := make(map[seriesKey]int64)
+	for _, snapshot := range snapshots {
+		saved[seriesKey{DeviceId: snapshot.DeviceId, Name: snapshot.Name}] = snapshot.Count
+	}
+	for _, entry := range entries {
+		entry.lock.Lock()
+		if series, ok := entry.State.(*deviceSeries); ok && err == nil {
+			for name := range series.Dirty {
+				stats := series.Stats[name]
+				count, ok := saved[seriesKey{DeviceId: stats.DeviceId, Name: name}]
+				if ok && count == stats.Count {
+					delete(series.Dirty, name)
+				}
+			}
+			ad.devices.pin(entry, len(series.Dirty) > 0)
+		}
+		ad.devices.release(entry)
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	"github.com/devicechain-io/dc-event-management/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Test rolling statistics converge on a steady series.
+func TestUpdateStatistics(t *testing.T) {
+	stats := &model.MeasurementStatistics{}
+	for i := 0; i < 1000; i++ {
+		value := 20.0
+		if i%2 == 1 {
+			value = 22.0
+		}
+		updateStatistics(stats, value, 0.05)
+	}
+	assert.Equal(t, int64(1000), stats.Count)
+	assert.InDelta(t, 21, stats.Mean, 0.1)
+	assert.InDelta(t, 1, math.Sqrt(stats.Variance), 0.1)
+}
+
+// Test values beyond the z-score are flagged once the series has warmed up.
+func TestAnomalyDetection(t *testing.T) {
+	api := &test.MockApi{}
+	api.Mock.On("MeasurementStatistics", mock.Anything).Return([]model.MeasurementStatistics{}, nil)
+	api.Mock.On("SaveMeasurementStatistics", mock.Anything).Return(nil)
+	api.Mock.On("CreateAnomalyEvent", mock.Anything).Return(&model.AnomalyEvent{}, nil)
+
+	detector := NewAnomalyDetector(api, AnomalySettings{Alpha: 0.1, ZScore: 4, Warmup: 10, SnapshotInterval: time.Hour})
+	ctx := context.Background()
+	start := time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC)
+	update := func(offset int, value float64) *model.AnomalyEvent {
+		event := model.Event{DeviceId: 1, OccurredTime: start.Add(time.Duration(offset) * time.Minute)}
+		anomaly, err := detector.Update(ctx, event, "temp", value)
+		assert.Nil(t, err)
+		return anomaly
+	}
+
+	// Large values are not flagged while warming up.
+	assert.Nil(t, update(0, 50))
+	for i := 1; i < 40; i++ {
+		assert.Nil(t, update(i, 50+float64(i%3)))
+	}
+
+	// Values far from the baseline are flagged, values near it are not.
+	assert.NotNil(t, update(40, 80))
+	assert.Nil(t, update(41, 51))
+
+	// Late values are ignored.
+	assert.Nil(t, update(0, 500))
+	api.Mock.AssertNumberOfCalls(t, "CreateAnomalyEvent", 1)
+
+	// Statistics are saved when flushed.
+	api.Mock.AssertNotCalled(t, "SaveMeasurementStatistics")
+	assert.Nil(t, detector.Flush(ctx))
+	api.Mock.AssertNumberOfCalls(t, "SaveMeasurementStatistics", 1)
+}
+
+// Test statistics stay in memory until saved, then are released once idle.
+func TestAnomalySeriesEviction(t *testing.T) {
+	api := &test.MockApi{}
+	api.Mock.On("MeasurementStatistics", mock.Anything).Return([]model.MeasurementStatistics{}, nil)
+	api.Mock.On("SaveMeasurementStatistics", mock.Anything).Return(nil)
+
+	detector := NewAnomalyDetector(api, AnomalySettings{Alpha: 0.1, ZScore: 4, Warmup: 10, SnapshotInterval: time.Hour})
+	ctx := context.Background()
+	start := time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC)
+	for device := uint(1); device <= 3; device++ {
+		_, err := detector.Update(ctx, model.Event{DeviceId: device, OccurredTime: start}, "temp", 20)
+		assert.Nil(t, err)
+	}
+	idle := func() {
+		for _, entry := range detector.devices.entries {
+			entry.used = time.Now().Add(-2 * time.Hour)
+		}
+		detector.devices.swept = time.Now().Add(-2 * time.Hour)
+	}
+
+	// Unsaved statistics are kept while idle.
+	idle()
+	_, err := detector.Update(ctx, model.Event{DeviceId: 1, OccurredTime: start.Add(time.Minute)}, "temp", 21)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, detector.devices.size())
+
+	// Saved statistics are released once idle.
+	assert.Nil(t, detector.Flush(ctx))
+	idle()
+	_, err = detector.Update(ctx, model.Event{DeviceId: 4, OccurredTime: start}, "temp", 20)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, detector.devices.size())
+	assert.Contains(t, detector.devices.entries, uint(4))
+}
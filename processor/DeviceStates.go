@@ -0,0 +1,120 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DEVICE_STATE_IDLE_TIME = time.Hour // Time after which in-memory state for an inactive device is released
+)
+
+// In-memory state for a device along with the lock serializing its updates. The state is
+// guarded by the entry lock while the other fields are guarded by the lock of the holder.
+type deviceEntry struct {
+	lock   sync.Mutex
+	users  int
+	used   time.Time
+	pinned bool
+	State  interface{}
+}
+
+// Holds per-device state for components shared by all workers. Each device has its own lock so
+// that datastore calls for one device do not block updates for others. State for devices that
+// have been idle longer than the idle time is released (unless pinned) and reloaded on next use.
+type deviceStates struct {
+	Idle time.Duration
+
+	lock    sync.Mutex
+	entries map[uint]*deviceEntry
+	swept   time.Time
+}
+
+// Create per-device state holder.
+func newDeviceStates(idle time.Duration) *deviceStates {
+	return &deviceStates{
+		Idle:    idle,
+		entries: make(map[uint]*deviceEntry),
+		swept:   time.Now(),
+	}
+}
+
+// Get the entry for a device with its lock held, creating the entry if necessary.
+func (ds *deviceStates) acquire(deviceId uint) *deviceEntry {
+	ds.lock.Lock()
+	entry := ds.entries[deviceId]
+	if entry == nil {
+		entry = &deviceEntry{}
+		ds.entries[deviceId] = entry
+	}
+	entry.users++
+	ds.lock.Unlock()
+
+	entry.lock.Lock()
+	return entry
+}
+
+// Pin or unpin a device entry. State for pinned entries is kept while idle (for instance while
+// it has changes that are not yet persisted).
+func (ds *deviceStates) pin(entry *deviceEntry, pinned bool) {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	entry.pinned = pinned
+}
+
+// Get entries that are pinned. The entries are counted as in use so that they are not released,
+// but are not locked. Each must be locked, then passed to release.
+func (ds *deviceStates) pinnedEntries() []*deviceEntry {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	pinned := make([]*deviceEntry, 0)
+	for _, entry := range ds.entries {
+		if entry.pinned {
+			entry.users++
+			pinned = append(pinned, entry)
+		}
+	}
+	return pinned
+}
+
+// Release the lock on a device entry, then release state for idle devices if due.
+func (ds *deviceStates) release(entry *deviceEntry) {
+	entry.lock.Unlock()
+
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	entry.users--
+	entry.used = time.Now()
+	if time.Since(ds.swept) < ds.Idle {
+		return
+	}
+	for id, candidate := range ds.entries {
+		if candidate.users == 0 && !candidate.pinned && time.Since(candidate.used) >= ds.Idle {
+			delete(ds.entries, id)
+		}
+	}
+	ds.swept = time.Now()
+}
+
+// Get number of devices with state held in memory.
+func (ds *deviceStates) size() int {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	return len(ds.entries)
+}
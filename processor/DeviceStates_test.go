@@ -0,0 +1,57 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test releasing state for idle devices.
+func TestDeviceStatesEviction(t *testing.T) {
+	states := newDeviceStates(time.Hour)
+
+	// State is kept between uses.
+	entry := states.acquire(1)
+	entry.State = "loaded"
+	states.release(entry)
+	entry = states.acquire(1)
+	assert.Equal(t, "loaded", entry.State)
+	states.release(entry)
+
+	// Idle entries are released unless pinned or in use.
+	states.acquire(2).pinned = true
+	states.release(states.entries[2])
+	busy := states.acquire(3)
+	for _, entry := range states.entries {
+		entry.used = time.Now().Add(-2 * time.Hour)
+	}
+	states.swept = time.Now().Add(-2 * time.Hour)
+	states.release(states.acquire(4))
+	assert.Equal(t, 3, states.size())
+	assert.NotContains(t, states.entries, uint(1))
+	assert.Contains(t, states.entries, uint(2))
+	assert.Contains(t, states.entries, uint(3))
+
+	// Released entries are reloaded on next use.
+	states.release(busy)
+	entry = states.acquire(1)
+	assert.Nil(t, entry.State)
+	states.release(entry)
+}
@@ -0,0 +1,133 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/model"
+)
+
+// Split the time between two positions of a device into dwell time per area and day (UTC). Time
+// is attributed to the areas the device was inside at the earlier position.
+func splitDwell(deviceId uint, previous *model.DwellPosition, until time.Time) []model.AreaDwell {
+	dwells := make([]model.AreaDwell, 0)
+	if len(previous.Areas) == 0 {
+		return dwells
+	}
+	for start := previous.Time.UTC(); start.Before(until); {
+		day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		end := day.AddDate(0, 0, 1)
+		if until.Before(end) {
+			end = until
+		}
+		for _, area := range previous.Areas {
+			dwells = append(dwells, model.AreaDwell{
+				DeviceId:      deviceId,
+				AreaType:      area.Type,
+				AreaId:        area.Id,
+				Day:           day,
+				Seconds:       end.Sub(start).Seconds(),
+				RelAssetId:    previous.RelAssetId,
+				RelCustomerId: previous.RelCustomerId,
+			})
+		}
+		start = end
+	}
+	return dwells
+}
+
+// Dwell state for a device. Position is nil if no location has been counted yet.
+type deviceDwell struct {
+	Position *model.DwellPosition
+}
+
+// Accumulates time devices spend inside areas and geofences as locations arrive. Time between
+// locations further apart than the max gap is not counted since the device may have been offline.
+// The last position of each device is persisted with the dwell time it adds. A single tracker is
+// shared by all workers. Updates are serialized per device and positions for idle devices are
+// released and reloaded on next use.
+type DwellTracker struct {
+	Api    model.EventManagementApi
+	MaxGap time.Duration
+
+	devices *deviceStates
+}
+
+// Create a new dwell tracker.
+func NewDwellTracker(api model.EventManagementApi, maxGap time.Duration) *DwellTracker {
+	return &DwellTracker{
+		Api:     api,
+		MaxGap:  maxGap,
+		devices: newDeviceStates(DEVICE_STATE_IDLE_TIME),
+	}
+}
+
+// Get dwell state for a device (with its entry locked), loading the persisted position on first use.
+func (dt *DwellTracker) deviceDwell(ctx context.Context, entry *deviceEntry, deviceId uint) (*deviceDwell, error) {
+	if dwell, ok := entry.State.(*deviceDwell); ok {
+		return dwell, nil
+	}
+	position, err := dt.Api.DwellPosition(ctx, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	dwell := &deviceDwell{Position: position}
+	entry.State = dwell
+	return dwell, nil
+}
+
+// Record a device location along with ids of geofences containing it. Locations arriving out of
+// order are ignored.
+func (dt *DwellTracker) Update(ctx context.Context, event model.Event, geofences []uint) error {
+	current := &model.DwellPosition{
+		DeviceId:      event.DeviceId,
+		Time:          event.OccurredTime,
+		Areas:         make([]model.AreaRef, 0),
+		RelAssetId:    event.RelAssetId,
+		RelCustomerId: event.RelCustomerId,
+	}
+	if event.RelAreaId != nil {
+		current.Areas = append(current.Areas, model.AreaRef{Type: model.AREA_TYPE_AREA, Id: *event.RelAreaId})
+	}
+	for _, id := range geofences {
+		current.Areas = append(current.Areas, model.AreaRef{Type: model.AREA_TYPE_GEOFENCE, Id: id})
+	}
+
+	entry := dt.devices.acquire(event.DeviceId)
+	defer dt.devices.release(entry)
+
+	dwell, err := dt.deviceDwell(ctx, entry, event.DeviceId)
+	if err != nil {
+		return err
+	}
+	previous := dwell.Position
+	if previous != nil && !event.OccurredTime.After(previous.Time) {
+		return nil
+	}
+	dwells := make([]model.AreaDwell, 0)
+	if previous != nil && event.OccurredTime.Sub(previous.Time) <= dt.MaxGap {
+		dwells = splitDwell(event.DeviceId, previous, event.OccurredTime)
+	}
+	err = dt.Api.AddAreaDwells(ctx, current, dwells)
+	if err != nil {
+		return err
+	}
+	dwell.Position = current
+	return nil
+}
@@ -0,0 +1,115 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	emtest "github.com/devicechain-io/dc-event-management/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Test splitting dwell time across days.
+func TestSplitDwell(t *testing.T) {
+	asset := uint(7)
+	previous := &model.DwellPosition{
+		Time:       time.Date(2022, 6, 1, 23, 30, 0, 0, time.UTC),
+		Areas:      []model.AreaRef{{Type: model.AREA_TYPE_AREA, Id: 3}, {Type: model.AREA_TYPE_GEOFENCE, Id: 4}},
+		RelAssetId: &asset,
+	}
+	dwells := splitDwell(1, previous, time.Date(2022, 6, 2, 0, 15, 0, 0, time.UTC))
+	assert.Equal(t, 4, len(dwells))
+	assert.Equal(t, time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC), dwells[0].Day)
+	assert.Equal(t, 1800.0, dwells[0].Seconds)
+	assert.Equal(t, model.AREA_TYPE_GEOFENCE, dwells[1].AreaType)
+	assert.Equal(t, time.Date(2022, 6, 2, 0, 0, 0, 0, time.UTC), dwells[2].Day)
+	assert.Equal(t, 900.0, dwells[2].Seconds)
+	assert.Equal(t, &asset, dwells[3].RelAssetId)
+
+	// Nothing is attributed outside of areas.
+	assert.Empty(t, splitDwell(1, &model.DwellPosition{Time: previous.Time}, previous.Time.Add(time.Hour)))
+}
+
+// Test accumulating dwell time as locations arrive.
+func TestDwellTracker(t *testing.T) {
+	api := new(emtest.MockApi)
+	api.Mock.On("DwellPosition").Return((*model.DwellPosition)(nil), nil)
+	api.Mock.On("AddAreaDwells", mock.Anything, mock.Anything).Return(nil)
+	tracker := NewDwellTracker(api, time.Hour)
+	ctx := context.Background()
+	added := func(call int) []model.AreaDwell {
+		return api.Calls[call].Arguments.Get(1).([]model.AreaDwell)
+	}
+
+	area := uint(3)
+	start := time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC)
+	event := model.Event{DeviceId: 1, OccurredTime: start, RelAreaId: &area}
+
+	// First location only records position.
+	assert.Nil(t, tracker.Update(ctx, event, nil))
+	api.AssertNumberOfCalls(t, "AddAreaDwells", 1)
+	assert.Empty(t, added(1))
+
+	// Following location adds time; late locations are ignored.
+	event.OccurredTime = start.Add(10 * time.Minute)
+	assert.Nil(t, tracker.Update(ctx, event, []uint{5}))
+	event.OccurredTime = start.Add(5 * time.Minute)
+	assert.Nil(t, tracker.Update(ctx, event, nil))
+	api.AssertNumberOfCalls(t, "AddAreaDwells", 2)
+	assert.Len(t, added(2), 1)
+
+	// Gaps longer than the maximum are not counted.
+	event.OccurredTime = start.Add(3 * time.Hour)
+	assert.Nil(t, tracker.Update(ctx, event, nil))
+	api.AssertNumberOfCalls(t, "AddAreaDwells", 3)
+	assert.Empty(t, added(3))
+}
+
+// Test dwell time is counted from a persisted position after state is released.
+func TestDwellTrackerPersistedPosition(t *testing.T) {
+	area := uint(3)
+	start := time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC)
+	api := new(emtest.MockApi)
+	api.Mock.On("DwellPosition").Return(&model.DwellPosition{
+		DeviceId: 1,
+		Time:     start,
+		Areas:    []model.AreaRef{{Type: model.AREA_TYPE_AREA, Id: area}},
+	}, nil)
+	api.Mock.On("AddAreaDwells", mock.Anything, mock.Anything).Return(nil)
+	tracker := NewDwellTracker(api, time.Hour)
+	ctx := context.Background()
+
+	event := model.Event{DeviceId: 1, OccurredTime: start.Add(10 * time.Minute), RelAreaId: &area}
+	assert.Nil(t, tracker.Update(ctx, event, nil))
+	dwells := api.Calls[1].Arguments.Get(1).([]model.AreaDwell)
+	assert.Len(t, dwells, 1)
+	assert.Equal(t, 600.0, dwells[0].Seconds)
+
+	// Idle devices are released and reloaded on next use.
+	for _, entry := range tracker.devices.entries {
+		entry.used = time.Now().Add(-2 * time.Hour)
+	}
+	tracker.devices.swept = time.Now().Add(-2 * time.Hour)
+	event.DeviceId = 2
+	assert.Nil(t, tracker.Update(ctx, event, nil))
+	assert.Equal(t, 1, tracker.devices.size())
+	api.AssertNumberOfCalls(t, "DwellPosition", 2)
+}
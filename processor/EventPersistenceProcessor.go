@@ -18,13 +18,18 @@ package processor
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"strconv"
+	"time"
 
 	dmodel "github.com/devicechain-io/dc-device-management/model"
 	"github.com/devicechain-io/dc-device-management/proto"
+	"github.com/devicechain-io/dc-event-management/config"
+	"github.com/devicechain-io/dc-event-management/geo"
 	emmodel "github.com/devicechain-io/dc-event-management/model"
 	"github.com/devicechain-io/dc-microservice/core"
 	kcore "github.com/devicechain-io/dc-microservice/kafka"
@@ -37,6 +42,12 @@ const (
 	KAFKA_BACKLOG_SIZE           = 100 // Number of kafka messages that can be read and waiting to be processed
 	FAILED_EVENT_BACKLOG_SIZE    = 100 // Number of failed events that can be waiting to be sent to kafka
 	PERSISTED_EVENT_BACKLOG_SIZE = 100 // Number of persisted events that can be waiting to be sent to kafka
+
+	DEFAULT_REFRESH_INTERVAL = 30 * time.Second // Interval at which geofences, definitions, rules and virtual measurements are reloaded if not configured
+	DEFAULT_STOP_DURATION    = 5 * time.Minute  // Dwell time that ends a trip if not configured
+	DEFAULT_DWELL_MAX_GAP    = time.Hour        // Gap between locations not counted as dwell time if not configured
+	DEFAULT_ANOMALY_SNAPSHOT = 5 * time.Minute  // Interval at which rolling statistics are saved if not configured
+	DEFAULT_LOCATION_MAX_AGE = time.Hour        // Age after which locations are not attached to measurements if not configured
 )
 
 type EventPersistenceProcessor struct {
@@ -45,24 +56,28 @@ type EventPersistenceProcessor struct {
 	PersistedEventsWriter kcore.KafkaWriter
 	FailedEventsWriter    kcore.KafkaWriter
 	Api                   emmodel.EventManagementApi
+	Configuration         config.EventManagementConfiguration
 
 	messages  chan kafka.Message
 	persisted chan interface{}
 	failed    chan dmodel.FailedEvent
 	workers   []*EventPersistenceWorker
+	anomalies *AnomalyDetector
 
 	lifecycle core.LifecycleManager
 }
 
 // Create a new inbound events processor.
 func NewEventPersistenceProcessor(ms *core.Microservice, resolved kcore.KafkaReader, persisted kcore.KafkaWriter,
-	failed kcore.KafkaWriter, callbacks core.LifecycleCallbacks, api emmodel.EventManagementApi) *EventPersistenceProcessor {
+	failed kcore.KafkaWriter, callbacks core.LifecycleCallbacks, api emmodel.EventManagementApi,
+	cfg config.EventManagementConfiguration) *EventPersistenceProcessor {
 	eproc := &EventPersistenceProcessor{
 		Microservice:          ms,
 		ResolvedEventsReader:  resolved,
 		PersistedEventsWriter: persisted,
 		FailedEventsWriter:    failed,
 		Api:                   api,
+		Configuration:         cfg,
 	}
 
 	// Create lifecycle manager.
@@ -117,14 +132,18 @@ func (eproc *EventPersistenceProcessor) OnFailedEvent(reason uint, event dmodel.
 
 // Handle case where event was successfully persisted.
 func (eproc *EventPersistenceProcessor) ProcessPersistedEvent(ctx context.Context) bool {
-	_, more := <-eproc.persisted
+	persisted, more := <-eproc.persisted
 	if more {
-		bytes := []byte("test")
+		bytes, err := json.Marshal(persisted)
+		if err != nil {
+			log.Error().Err(err).Msg("unable to marshal persisted event")
+			return false
+		}
 		msg := kafka.Message{
-			Key:   []byte("xxx"),
+			Key:   persistedEventKey(persisted),
 			Value: bytes,
 		}
-		err := eproc.PersistedEventsWriter.WriteMessages(ctx, msg)
+		err = eproc.PersistedEventsWriter.WriteMessages(ctx, msg)
 		eproc.PersistedEventsWriter.HandleResponse(err)
 		return false
 	} else {
@@ -132,22 +151,190 @@ func (eproc *EventPersistenceProcessor) ProcessPersistedEvent(ctx context.Contex
 	}
 }
 
+// Get message key for a persisted event so that events for a device share a partition.
+func persistedEventKey(persisted interface{}) []byte {
+	var deviceId uint
+	switch event := persisted.(type) {
+	case *emmodel.LocationEvent:
+		deviceId = event.DeviceId
+	case *emmodel.MeasurementEvent:
+		deviceId = event.DeviceId
+	case *emmodel.AlertEvent:
+		deviceId = event.DeviceId
+	case *emmodel.GeofenceEvent:
+		deviceId = event.DeviceId
+	case *emmodel.AnomalyEvent:
+		deviceId = event.DeviceId
+	}
+	return []byte(strconv.FormatUint(uint64(deviceId), 10))
+}
+
+// Parse a configured duration (checked by Validate at startup), using the default if it is not set
+// or invalid.
+func durationOrDefault(value string, fallback time.Duration, setting string) time.Duration {
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Warn().Err(err).Msg("Invalid " + setting + ". Using default.")
+		return fallback
+	}
+	return parsed
+}
+
+// Create geofence evaluator shared by workers (nil if geofencing is disabled).
+func (eproc *EventPersistenceProcessor) newGeofenceEvaluator() *GeofenceEvaluator {
+	if !eproc.Configuration.Geofencing.Enabled {
+		return nil
+	}
+	refresh := durationOrDefault(eproc.Configuration.Geofencing.RefreshInterval, DEFAULT_REFRESH_INTERVAL,
+		"geofence refresh interval")
+	return NewGeofenceEvaluator(eproc.Api, refresh)
+}
+
+// Create threshold rule evaluator shared by workers (nil if rules are disabled).
+func (eproc *EventPersistenceProcessor) newRuleEvaluator() *RuleEvaluator {
+	if !eproc.Configuration.ThresholdRules.Enabled {
+		return nil
+	}
+	refresh := durationOrDefault(eproc.Configuration.ThresholdRules.RefreshInterval, DEFAULT_REFRESH_INTERVAL,
+		"threshold rule refresh interval")
+	return NewRuleEvaluator(eproc.Api, refresh)
+}
+
+// Create virtual measurement evaluator shared by workers (nil if virtual measurements are disabled).
+func (eproc *EventPersistenceProcessor) newVirtualMeasurementEvaluator() *VirtualMeasurementEvaluator {
+	if !eproc.Configuration.VirtualMeasurements.Enabled {
+		return nil
+	}
+	refresh := durationOrDefault(eproc.Configuration.VirtualMeasurements.RefreshInterval, DEFAULT_REFRESH_INTERVAL,
+		"virtual measurement refresh interval")
+	return NewVirtualMeasurementEvaluator(eproc.Api, refresh)
+}
+
+// Create anomaly detector shared by workers (nil if anomaly detection is disabled).
+func (eproc *EventPersistenceProcessor) newAnomalyDetector() *AnomalyDetector {
+	adconfig := eproc.Configuration.AnomalyDetection
+	if !adconfig.Enabled {
+		return nil
+	}
+	settings := AnomalySettings{
+		Alpha:            adconfig.Alpha,
+		ZScore:           adconfig.ZScore,
+		Warmup:           adconfig.Warmup,
+		SnapshotInterval: durationOrDefault(adconfig.SnapshotInterval, DEFAULT_ANOMALY_SNAPSHOT, "anomaly snapshot interval"),
+	}
+	return NewAnomalyDetector(eproc.Api, settings)
+}
+
+// Create trip detector shared by workers (nil if trip detection is disabled).
+func (eproc *EventPersistenceProcessor) newTripDetector() *TripDetector {
+	tdconfig := eproc.Configuration.TripDetection
+	if !tdconfig.Enabled {
+		return nil
+	}
+	settings := TripSettings{
+		MovingSpeed:  tdconfig.MovingSpeed,
+		StopRadius:   tdconfig.StopRadius,
+		StopDuration: durationOrDefault(tdconfig.StopDuration, DEFAULT_STOP_DURATION, "trip stop duration"),
+	}
+	return NewTripDetector(eproc.Api, settings)
+}
+
+// Create dwell tracker shared by workers (nil if dwell tracking is disabled).
+func (eproc *EventPersistenceProcessor) newDwellTracker() *DwellTracker {
+	if !eproc.Configuration.Dwell.Enabled {
+		return nil
+	}
+	maxGap := durationOrDefault(eproc.Configuration.Dwell.MaxGap, DEFAULT_DWELL_MAX_GAP, "dwell max gap")
+	return NewDwellTracker(eproc.Api, maxGap)
+}
+
+// Get the max age of locations attached to measurements (zero if measurement locations are disabled).
+func (eproc *EventPersistenceProcessor) measurementLocationAge() time.Duration {
+	mlconfig := eproc.Configuration.MeasurementLocation
+	if !mlconfig.Enabled {
+		return 0
+	}
+	return durationOrDefault(mlconfig.MaxAge, DEFAULT_LOCATION_MAX_AGE, "measurement location max age")
+}
+
+// Create measurement registry shared by workers (nil if measurements are not checked).
+func (eproc *EventPersistenceProcessor) newMeasurementRegistry() *MeasurementRegistry {
+	mrconfig := eproc.Configuration.Measurements
+	if !mrconfig.Enabled {
+		return nil
+	}
+	refresh := durationOrDefault(mrconfig.RefreshInterval, DEFAULT_REFRESH_INTERVAL,
+		"measurement definition refresh interval")
+	return NewMeasurementRegistry(eproc.Api, eproc.Api.UnitConverter(), refresh, mrconfig.Unknown, mrconfig.InvalidValues)
+}
+
+// Load gazetteer shared by workers from local files (nil if geocoding is disabled).
+func (eproc *EventPersistenceProcessor) loadGazetteer() (*geo.Gazetteer, error) {
+	gcconfig := eproc.Configuration.Geocoding
+	if !gcconfig.Enabled {
+		return nil, nil
+	}
+	gazetteer := geo.NewGazetteer(gcconfig.PlaceDistance)
+	load := func(path string, loader func(io.Reader) error) error {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if err := loader(file); err != nil {
+			return fmt.Errorf("unable to load %s: %w", path, err)
+		}
+		return nil
+	}
+	for _, path := range gcconfig.Boundaries {
+		if err := load(path, gazetteer.LoadBoundaries); err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range gcconfig.Places {
+		if err := load(path, gazetteer.LoadPlaces); err != nil {
+			return nil, err
+		}
+	}
+	log.Info().Int("boundaries", len(gazetteer.Boundaries)).Int("places", len(gazetteer.Places)).
+		Msg("Loaded gazetteer for geocoding.")
+	return gazetteer, nil
+}
+
 // Called when an event is successfully resolved.
 func (eproc *EventPersistenceProcessor) OnPersistedEvent(event interface{}) {
 	eproc.persisted <- event
 }
 
 // Initialize pool of workers for persisting events.
-func (eproc *EventPersistenceProcessor) initializeEventPersistenceWorkers(ctx context.Context) {
+func (eproc *EventPersistenceProcessor) initializeEventPersistenceWorkers(ctx context.Context) error {
 	// Make channels and workers for distributed processing.
 	eproc.messages = make(chan kafka.Message, KAFKA_BACKLOG_SIZE)
 	eproc.workers = make([]*EventPersistenceWorker, 0)
+	locations := NewLocationCache(eproc.Api)
+	geofences := eproc.newGeofenceEvaluator()
+	trips := eproc.newTripDetector()
+	dwell := eproc.newDwellTracker()
+	validator := NewLocationValidator(eproc.Configuration.LocationValidation)
+	registry := eproc.newMeasurementRegistry()
+	rules := eproc.newRuleEvaluator()
+	eproc.anomalies = eproc.newAnomalyDetector()
+	virtual := eproc.newVirtualMeasurementEvaluator()
+	gazetteer, err := eproc.loadGazetteer()
+	if err != nil {
+		return err
+	}
 	for w := 1; w <= WORKER_COUNT; w++ {
-		resolver := NewEventPersistenceWorker(w, eproc.Api, eproc.messages,
+		resolver := NewEventPersistenceWorker(w, eproc.Api, locations, geofences, trips, dwell,
+			validator, gazetteer, registry, rules, eproc.anomalies, virtual, eproc.measurementLocationAge(), eproc.messages,
 			eproc.OnInvalidEvent, eproc.OnPersistedEvent, eproc.OnFailedEvent)
 		eproc.workers = append(eproc.workers, resolver)
 		go resolver.Process(ctx)
 	}
+	return nil
 }
 
 // Initialize outbound processing.
@@ -164,7 +351,10 @@ func (eproc *EventPersistenceProcessor) Initialize(ctx context.Context) error {
 // Lifecycle callback that runs initialization logic.
 func (eproc *EventPersistenceProcessor) ExecuteInitialize(ctx context.Context) error {
 	// Initialize pool of event resolvers.
-	eproc.initializeEventPersistenceWorkers(ctx)
+	err := eproc.initializeEventPersistenceWorkers(ctx)
+	if err != nil {
+		return err
+	}
 
 	// Initialize outbound processing channels.
 	eproc.initializeOutboundProcessing(ctx)
@@ -230,7 +420,14 @@ func (eproc *EventPersistenceProcessor) Stop(ctx context.Context) error {
 }
 
 // Lifecycle callback that runs shutdown logic.
-func (eproc *EventPersistenceProcessor) ExecuteStop(context.Context) error {
+func (eproc *EventPersistenceProcessor) ExecuteStop(ctx context.Context) error {
+	// Save rolling statistics so that baselines survive restart.
+	if eproc.anomalies != nil {
+		err := eproc.anomalies.Flush(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to save measurement statistics.")
+		}
+	}
 	close(eproc.messages)
 	close(eproc.persisted)
 	close(eproc.failed)
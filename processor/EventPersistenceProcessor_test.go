@@ -18,12 +18,14 @@ package processor
 
 import (
 	"context"
+	"database/sql"
 	"io"
 	"testing"
 
 	dmodel "github.com/devicechain-io/dc-device-management/model"
 	dmproto "github.com/devicechain-io/dc-device-management/proto"
 	dmtest "github.com/devicechain-io/dc-device-management/test"
+	"github.com/devicechain-io/dc-event-management/config"
 	"github.com/devicechain-io/dc-event-management/model"
 	emtest "github.com/devicechain-io/dc-event-management/test"
 	esmodel "github.com/devicechain-io/dc-event-sources/model"
@@ -58,7 +60,8 @@ func (suite *EventPersistenceProcessorTestSuite) SetupTest() {
 		suite.Persisted,
 		suite.Failed,
 		core.NewNoOpLifecycleCallbacks(),
-		suite.API)
+		suite.API,
+		*config.NewEventManagementConfiguration())
 	ctx := context.Background()
 	suite.EP.Initialize(ctx)
 }
@@ -151,6 +154,33 @@ func buildMeasurementsEvent() *dmodel.ResolvedEvent {
 	return buildResolvedEvent(esmodel.Measurement, loc)
 }
 
+// Build an alerts event.
+func buildAlertsEvent() *dmodel.ResolvedEvent {
+	entry := dmodel.ResolvedAlertEntry{
+		Type:    "engine.overheat",
+		Level:   3,
+		Message: "Engine temperature above threshold",
+		Source:  "device",
+	}
+	entries := make([]dmodel.ResolvedAlertEntry, 0)
+	entries = append(entries, entry)
+	alerts := &dmodel.ResolvedAlertsPayload{
+		Entries: entries,
+	}
+	return buildResolvedEvent(esmodel.Alert, alerts)
+}
+
+// Mock trip detection and dwell tracking for a device without history.
+func (suite *EventPersistenceProcessorTestSuite) mockTrips() {
+	suite.API.Mock.On("TripDetectorState", mock.Anything, mock.Anything).Return((*model.TripDetectorState)(nil), nil)
+	suite.API.Mock.On("ActiveTrip", mock.Anything, mock.Anything).Return((*model.Trip)(nil), nil)
+	suite.API.Mock.On("ActiveStop", mock.Anything, mock.Anything).Return((*model.Stop)(nil), nil)
+	suite.API.Mock.On("SaveStop", mock.Anything, mock.Anything).Return(nil)
+	suite.API.Mock.On("SaveTripDetectorState", mock.Anything, mock.Anything).Return(nil)
+	suite.API.Mock.On("DwellPosition", mock.Anything, mock.Anything).Return((*model.DwellPosition)(nil), nil)
+	suite.API.Mock.On("AddAreaDwells", mock.Anything, mock.Anything).Return(nil)
+}
+
 // Test failed event flow for a given message.
 func (suite *EventPersistenceProcessorTestSuite) FailedEventFlowFor(msg kafka.Message) {
 	// Emulate kafka read/write.
@@ -206,9 +236,66 @@ func (suite *EventPersistenceProcessorTestSuite) TestSingleLocationEvent() {
 
 	// Test event flow.
 	suite.API.Mock.On("CreateLocationEvent", mock.Anything, mock.Anything).Return(&model.LocationEvent{}, nil)
+	suite.API.Mock.On("LastLocation", mock.Anything, mock.Anything, mock.Anything).Return((*model.LocationEvent)(nil), nil)
+	suite.API.Mock.On("ActiveGeofences", mock.Anything).Return([]model.Geofence{}, nil)
+	suite.mockTrips()
 	suite.SuccessEventFlowFor(msg)
 }
 
+// Test location event that enters a geofence.
+func (suite *EventPersistenceProcessorTestSuite) TestGeofenceEnterEvent() {
+	// Encode payload as bytes.
+	loc := buildLocationsEvent()
+	bytes, err := dmproto.MarshalResolvedEvent(loc)
+	assert.Nil(suite.T(), err)
+
+	// Build kafka message.
+	key := []byte(loc.Source)
+	msg := kafka.Message{Key: key, Value: bytes}
+
+	// Circular fence around the event location.
+	fence := model.Geofence{
+		CenterLatitude:  sql.NullFloat64{Float64: 33.7490, Valid: true},
+		CenterLongitude: sql.NullFloat64{Float64: -84.3880, Valid: true},
+		Radius:          sql.NullFloat64{Float64: 100, Valid: true},
+	}
+	fence.ID = 1
+
+	// Test event flow.
+	suite.API.Mock.On("CreateLocationEvent", mock.Anything, mock.Anything).Return(&model.LocationEvent{}, nil)
+	suite.API.Mock.On("LastLocation", mock.Anything, mock.Anything, mock.Anything).Return((*model.LocationEvent)(nil), nil)
+	suite.API.Mock.On("ActiveGeofences", mock.Anything).Return([]model.Geofence{fence}, nil)
+	suite.API.Mock.On("GeofenceStates", mock.Anything, mock.Anything).Return([]model.GeofenceState{}, nil)
+	suite.API.Mock.On("SaveGeofenceState", mock.Anything, mock.Anything).Return(nil)
+	suite.API.Mock.On("CreateGeofenceEvent", mock.Anything, mock.Anything).Return(&model.GeofenceEvent{}, nil)
+	suite.mockTrips()
+	suite.SuccessEventFlowFor(msg)
+
+	// Verify enter event was created and state saved.
+	suite.EP.ProcessPersistedEvent(context.Background())
+	suite.API.AssertCalled(suite.T(), "CreateGeofenceEvent", mock.Anything, mock.Anything)
+	suite.API.AssertCalled(suite.T(), "SaveGeofenceState", mock.Anything, mock.Anything)
+}
+
+// Test location with coordinates out of range.
+func (suite *EventPersistenceProcessorTestSuite) TestRejectedLocationEvent() {
+	// Encode payload as bytes.
+	loc := buildLocationsEvent()
+	lat := "123.45"
+	loc.Payload.(*dmodel.ResolvedLocationsPayload).Entries[0].Latitude = &lat
+	bytes, err := dmproto.MarshalResolvedEvent(loc)
+	assert.Nil(suite.T(), err)
+
+	// Build kafka message.
+	key := []byte(loc.Source)
+	msg := kafka.Message{Key: key, Value: bytes}
+
+	// Test event flow.
+	suite.API.Mock.On("LastLocation", mock.Anything, mock.Anything, mock.Anything).Return((*model.LocationEvent)(nil), nil)
+	suite.FailedEventFlowFor(msg)
+	suite.API.AssertNotCalled(suite.T(), "CreateLocationEvent", mock.Anything, mock.Anything)
+}
+
 // Test measurements event with one entry.
 func (suite *EventPersistenceProcessorTestSuite) TestSingleMeasurementEvent() {
 	// Encode payload as bytes.
@@ -222,6 +309,26 @@ func (suite *EventPersistenceProcessorTestSuite) TestSingleMeasurementEvent() {
 
 	// Test event flow.
 	suite.API.Mock.On("CreateMeasurementEvent", mock.Anything, mock.Anything).Return(&model.MeasurementEvent{}, nil)
+	suite.API.Mock.On("AllMeasurementDefinitions", mock.Anything).Return([]model.MeasurementDefinition{}, nil)
+	suite.API.Mock.On("ActiveThresholdRules", mock.Anything).Return([]model.ThresholdRule{}, nil)
+	suite.API.Mock.On("ActiveVirtualMeasurements", mock.Anything).Return([]model.VirtualMeasurement{}, nil)
+	suite.API.Mock.On("LastLocation", mock.Anything, mock.Anything, mock.Anything).Return((*model.LocationEvent)(nil), nil)
+	suite.SuccessEventFlowFor(msg)
+}
+
+// Test alerts event with one entry.
+func (suite *EventPersistenceProcessorTestSuite) TestSingleAlertEvent() {
+	// Encode payload as bytes.
+	alert := buildAlertsEvent()
+	bytes, err := dmproto.MarshalResolvedEvent(alert)
+	assert.Nil(suite.T(), err)
+
+	// Build kafka message.
+	key := []byte(alert.Source)
+	msg := kafka.Message{Key: key, Value: bytes}
+
+	// Test event flow.
+	suite.API.Mock.On("CreateAlertEvent", mock.Anything, mock.Anything).Return(&model.AlertEvent{}, nil)
 	suite.SuccessEventFlowFor(msg)
 }
 
@@ -19,11 +19,15 @@ package processor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
 	dmmodel "github.com/devicechain-io/dc-device-management/model"
 	dmproto "github.com/devicechain-io/dc-device-management/proto"
+	"github.com/devicechain-io/dc-event-management/config"
+	"github.com/devicechain-io/dc-event-management/geo"
 	"github.com/devicechain-io/dc-event-management/model"
 	esmodel "github.com/devicechain-io/dc-event-sources/model"
 	"github.com/devicechain-io/dc-microservice/rdb"
@@ -35,6 +39,17 @@ import (
 type EventPersistenceWorker struct {
 	WorkerId    int
 	Api         model.EventManagementApi
+	Locations   *LocationCache
+	Geofences   *GeofenceEvaluator
+	Trips       *TripDetector
+	Dwell       *DwellTracker
+	Validator   *LocationValidator
+	Gazetteer   *geo.Gazetteer
+	Registry    *MeasurementRegistry
+	Rules       *RuleEvaluator
+	Anomalies   *AnomalyDetector
+	Virtual     *VirtualMeasurementEvaluator
+	LocationAge time.Duration
 	Unpersisted <-chan kafka.Message
 	Invalid     func(error, kafka.Message)
 	Persisted   func(interface{})
@@ -48,6 +63,17 @@ type EventPersistenceResults struct {
 
 // Create a new event resolver.
 func NewEventPersistenceWorker(workerId int, api model.EventManagementApi,
+	locations *LocationCache,
+	geofences *GeofenceEvaluator,
+	trips *TripDetector,
+	dwell *DwellTracker,
+	validator *LocationValidator,
+	gazetteer *geo.Gazetteer,
+	registry *MeasurementRegistry,
+	rules *RuleEvaluator,
+	anomalies *AnomalyDetector,
+	virtual *VirtualMeasurementEvaluator,
+	locationAge time.Duration,
 	unpersisted <-chan kafka.Message,
 	invalid func(error, kafka.Message),
 	persisted func(interface{}),
@@ -55,6 +81,17 @@ func NewEventPersistenceWorker(workerId int, api model.EventManagementApi,
 	return &EventPersistenceWorker{
 		WorkerId:    workerId,
 		Api:         api,
+		Locations:   locations,
+		Geofences:   geofences,
+		Trips:       trips,
+		Dwell:       dwell,
+		Validator:   validator,
+		Gazetteer:   gazetteer,
+		Registry:    registry,
+		Rules:       rules,
+		Anomalies:   anomalies,
+		Virtual:     virtual,
+		LocationAge: locationAge,
 		Unpersisted: unpersisted,
 		Invalid:     invalid,
 		Persisted:   persisted,
@@ -74,10 +111,43 @@ func parseNullableFloat64(val *string) (*float64, error) {
 	return &parsed, nil
 }
 
-// Persists a location event to the datastore.
-func (ep *EventPersistenceWorker) PersistLocationEvents(ctx context.Context, event model.Event,
-	payload dmmodel.ResolvedLocationsPayload) (*EventPersistenceResults, error) {
-	events := make([]interface{}, 0)
+// Get a pointer to a string (nil if empty).
+func optionalString(val string) *string {
+	if val == "" {
+		return nil
+	}
+	return &val
+}
+
+// Parse the occurred time for a payload entry (RFC3339 or epoch milliseconds). Entries without
+// a time use the time of the event.
+func parseEntryTime(val *string, fallback time.Time) (time.Time, error) {
+	if val == nil || *val == "" {
+		return fallback, nil
+	}
+	if millis, err := strconv.ParseInt(*val, 10, 64); err == nil {
+		return time.UnixMilli(millis).UTC(), nil
+	}
+	return time.Parse(time.RFC3339Nano, *val)
+}
+
+// Location entry that was parsed and validated. Current is only set for tracked locations.
+type validatedLocation struct {
+	Event     model.Event
+	Latitude  *float64
+	Longitude *float64
+	Elevation *float64
+	Suspect   *string
+	Current   *TimedLocation
+	Derived   LocationDerivation
+}
+
+// Parse and validate the entries of a location payload. Entries are checked against the location
+// before them, whether stored already or earlier in the payload. Returns an error if any entry
+// can not be parsed or is rejected, so that nothing is stored for the payload.
+func (ep *EventPersistenceWorker) validateLocations(ctx context.Context, event model.Event,
+	payload dmmodel.ResolvedLocationsPayload) ([]validatedLocation, error) {
+	validated := make([]validatedLocation, 0)
 	for _, location := range payload.Entries {
 		lat, err := parseNullableFloat64(location.Latitude)
 		if err != nil {
@@ -91,17 +161,307 @@ func (ep *EventPersistenceWorker) PersistLocationEvents(ctx context.Context, eve
 		if err != nil {
 			return nil, err
 		}
+		occurred, err := parseEntryTime(location.OccurredTime, event.OccurredTime)
+		if err != nil {
+			return nil, err
+		}
+		levent := event
+		levent.OccurredTime = occurred
+
+		// Look up the previous known location of the device.
+		var previous *TimedLocation
+		if lat != nil && lon != nil {
+			previous, err = ep.Locations.Previous(ctx, event.DeviceId, occurred)
+			if err != nil {
+				return nil, err
+			}
+			for _, earlier := range validated {
+				if earlier.Current != nil && earlier.Current.Time.Before(occurred) &&
+					(previous == nil || earlier.Current.Time.After(previous.Time)) {
+					previous = earlier.Current
+				}
+			}
+		}
+
+		// Apply validation rules. Suspect locations are stored but not tracked.
+		var suspect *string
+		if ep.Validator != nil {
+			if violation := ep.Validator.Validate(lat, lon, ele, occurred, previous); violation != nil {
+				switch violation.Action {
+				case config.VALIDATION_REJECT:
+					return nil, violation
+				case config.VALIDATION_DROP:
+					log.Debug().Uint("device", event.DeviceId).Str("rule", violation.Rule).Msg("Dropped invalid location.")
+					continue
+				default:
+					suspect = &violation.Rule
+					if violation.Unusable {
+						lat, lon, ele = nil, nil, nil
+					}
+				}
+			}
+		}
+
+		// Derive movement since the previous known location.
+		entry := validatedLocation{Event: levent, Latitude: lat, Longitude: lon, Elevation: ele, Suspect: suspect}
+		if lat != nil && lon != nil && suspect == nil {
+			entry.Current = &TimedLocation{Point: geo.Point{Latitude: *lat, Longitude: *lon}, Elevation: ele, Time: occurred}
+			if previous != nil {
+				entry.Derived = DeriveMovement(*previous, *entry.Current)
+			}
+		}
+		validated = append(validated, entry)
+	}
+	return validated, nil
+}
+
+// Persists a location event to the datastore. All entries are validated before any is stored.
+func (ep *EventPersistenceWorker) PersistLocationEvents(ctx context.Context, event model.Event,
+	payload dmmodel.ResolvedLocationsPayload) (*EventPersistenceResults, error) {
+	validated, err := ep.validateLocations(ctx, event, payload)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]interface{}, 0)
+	for _, entry := range validated {
+		levent, current := entry.Event, entry.Current
+		tracked := current != nil
+
+		// Resolve place name and region codes.
+		var place *geo.PlaceMatch
+		if ep.Gazetteer != nil && tracked {
+			place = ep.Gazetteer.Lookup(current.Point)
+		}
+
+		// Resolved location entries only carry position and time, so accuracy, speed, course,
+		// satellites and fix type are left unset until device management passes them through.
 		lreq := &model.LocationEventCreateRequest{
-			Event:     event,
-			Latitude:  lat,
-			Longitude: lon,
-			Elevation: ele,
+			Event:                levent,
+			Latitude:             entry.Latitude,
+			Longitude:            entry.Longitude,
+			Elevation:            entry.Elevation,
+			ComputedSpeed:        entry.Derived.Speed,
+			ComputedHeading:      entry.Derived.Heading,
+			DistanceFromPrevious: entry.Derived.Distance,
+			SuspectReason:        entry.Suspect,
+		}
+		if place != nil {
+			lreq.PlaceName = optionalString(place.Name)
+			lreq.CountryCode = optionalString(place.CountryCode)
+			lreq.RegionCode = optionalString(place.RegionCode)
 		}
 		locevt, err := ep.Api.CreateLocationEvent(ctx, lreq)
 		if err != nil {
 			return nil, err
 		}
 		events = append(events, locevt)
+		if tracked {
+			ep.Locations.Update(event.DeviceId, *current)
+		}
+
+		// Evaluate geofences for the new location.
+		if ep.Geofences != nil && tracked {
+			generated, err := ep.Geofences.Evaluate(ctx, levent, current.Latitude, current.Longitude)
+			if err != nil {
+				log.Error().Err(err).Uint("device", event.DeviceId).Msg("Unable to evaluate geofences.")
+			}
+			for _, gevent := range generated {
+				events = append(events, gevent)
+			}
+		}
+
+		// Update trips and stops for the new location.
+		if ep.Trips != nil && tracked {
+			err := ep.Trips.Update(ctx, levent, current.Latitude, current.Longitude)
+			if err != nil {
+				log.Error().Err(err).Uint("device", event.DeviceId).Msg("Unable to update trips.")
+			}
+		}
+
+		// Accumulate time spent inside areas and geofences.
+		if ep.Dwell != nil && tracked {
+			fences := make([]uint, 0)
+			if ep.Geofences != nil {
+				fences = ep.Geofences.Inside(event.DeviceId)
+			}
+			err := ep.Dwell.Update(ctx, levent, fences)
+			if err != nil {
+				log.Error().Err(err).Uint("device", event.DeviceId).Msg("Unable to update area dwell time.")
+			}
+		}
+	}
+	results := &EventPersistenceResults{
+		Events: events,
+	}
+	return results, nil
+}
+
+// Store a measurement and evaluate threshold rules and anomaly detection for it. The location is
+// stored as the inferred position of the measurement if given. Returns the persisted events
+// (including generated alerts and anomalies).
+func (ep *EventPersistenceWorker) storeMeasurement(ctx context.Context, mevent model.Event, name string,
+	measured MeasurementValue, suspect *string, classifier *int64, located *TimedLocation) ([]interface{}, error) {
+	events := make([]interface{}, 0)
+	value := measured.Value
+	mreq := &model.MeasurementEventCreateRequest{
+		Event:         mevent,
+		Name:          name,
+		Unit:          optionalString(measured.Unit),
+		RawUnit:       measured.RawUnit,
+		RawValue:      measured.RawValue,
+		SuspectReason: suspect,
+		Value:         value,
+		Classifier:    classifier,
+	}
+
+	if located != nil {
+		mreq.Latitude = &located.Latitude
+		mreq.Longitude = &located.Longitude
+		mreq.Elevation = located.Elevation
+		mreq.LocationInferred = true
+	}
+	mxevt, err := ep.Api.CreateMeasurementEvent(ctx, mreq)
+	if err != nil {
+		return nil, err
+	}
+	events = append(events, mxevt)
+
+	// Evaluate threshold rules for the new measurement.
+	if ep.Rules != nil && suspect == nil {
+		generated, err := ep.Rules.Evaluate(ctx, mevent, name, value, measured.Unit)
+		if err != nil {
+			log.Error().Err(err).Uint("device", mevent.DeviceId).Msg("Unable to evaluate threshold rules.")
+		}
+		for _, aevent := range generated {
+			events = append(events, aevent)
+		}
+	}
+
+	// Check the measurement against rolling statistics for the series.
+	if ep.Anomalies != nil && suspect == nil {
+		anomaly, err := ep.Anomalies.Update(ctx, mevent, name, value)
+		if err != nil {
+			log.Error().Err(err).Uint("device", mevent.DeviceId).Msg("Unable to update measurement statistics.")
+		}
+		if anomaly != nil {
+			events = append(events, anomaly)
+		}
+	}
+	return events, nil
+}
+
+// Persists a measurements event to the datastore.
+func (ep *EventPersistenceWorker) PersistMeasurementEvents(ctx context.Context, event model.Event,
+	payload dmmodel.ResolvedMeasurementsPayload) (*EventPersistenceResults, error) {
+	events := make([]interface{}, 0)
+	for _, measurements := range payload.Entries {
+		occurred, err := parseEntryTime(measurements.OccurredTime, event.OccurredTime)
+		if err != nil {
+			return nil, err
+		}
+		mevent := event
+		mevent.OccurredTime = occurred
+		values := make(map[string]float64)
+
+		// Measurements do not carry a position, so use the last known device location.
+		var located *TimedLocation
+		if ep.LocationAge > 0 {
+			located, err = ep.Locations.Recent(ctx, event.DeviceId, occurred, ep.LocationAge)
+			if err != nil {
+				log.Error().Err(err).Uint("device", event.DeviceId).Msg("Unable to look up last known location.")
+			}
+		}
+		for _, measurement := range measurements.Entries {
+			value, err := strconv.ParseFloat(measurement.Value, 64)
+			if err != nil {
+				return nil, err
+			}
+			var classifier *int64
+			if measurement.Classifier != nil {
+				cval := int64(*measurement.Classifier)
+				classifier = &cval
+			}
+
+			// Check against registered definitions, converting to the unit of the definition.
+			name, unit := model.ParseMeasurementName(measurement.Name)
+			measured := MeasurementValue{Value: value, Unit: unit}
+			var suspect *string
+			if ep.Registry != nil {
+				var violation *MeasurementViolation
+				_, measured, violation, err = ep.Registry.Validate(ctx, name, unit, value)
+				if err != nil {
+					log.Error().Err(err).Msg("Unable to load measurement definitions.")
+				}
+				if violation != nil {
+					switch violation.Action {
+					case config.VALIDATION_REJECT:
+						return nil, violation
+					case config.VALIDATION_DROP:
+						log.Debug().Uint("device", event.DeviceId).Str("rule", violation.Rule).Msg("Dropped invalid measurement.")
+						continue
+					default:
+						suspect = &violation.Rule
+					}
+				}
+			}
+			stored, err := ep.storeMeasurement(ctx, mevent, name, measured, suspect, classifier, located)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, stored...)
+			if suspect == nil {
+				values[name] = measured.Value
+			}
+		}
+
+		// Compute virtual measurements with all inputs in this entry.
+		if ep.Virtual != nil && len(values) > 0 {
+			computed, err := ep.Virtual.Evaluate(ctx, values)
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to load virtual measurements.")
+			}
+			for _, virtual := range computed {
+				measured := MeasurementValue{Value: virtual.Value}
+				if virtual.Definition.Unit.Valid {
+					measured.Unit = virtual.Definition.Unit.String
+				}
+				stored, err := ep.storeMeasurement(ctx, mevent, virtual.Definition.Token, measured, nil, nil, located)
+				if err != nil {
+					return nil, err
+				}
+				events = append(events, stored...)
+			}
+		}
+	}
+	results := &EventPersistenceResults{
+		Events: events,
+	}
+	return results, nil
+}
+
+// Persists an alerts event to the datastore.
+func (ep *EventPersistenceWorker) PersistAlertEvents(ctx context.Context, event model.Event,
+	payload dmmodel.ResolvedAlertsPayload) (*EventPersistenceResults, error) {
+	events := make([]interface{}, 0)
+	for _, alert := range payload.Entries {
+		occurred, err := parseEntryTime(alert.OccurredTime, event.OccurredTime)
+		if err != nil {
+			return nil, err
+		}
+		aevent := event
+		aevent.OccurredTime = occurred
+		areq := &model.AlertEventCreateRequest{
+			Event:   aevent,
+			Type:    alert.Type,
+			Level:   alert.Level,
+			Message: alert.Message,
+			Source:  alert.Source,
+		}
+		alevt, err := ep.Api.CreateAlertEvent(ctx, areq)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, alevt)
 	}
 	results := &EventPersistenceResults{
 		Events: events,
@@ -133,6 +493,16 @@ func (ep *EventPersistenceWorker) PersistEvent(ctx context.Context, event dmmode
 			return ep.PersistLocationEvents(ctx, pevent, *payload)
 		}
 		return nil, fmt.Errorf("non-location payload in location event")
+	case esmodel.Measurement:
+		if payload, ok := event.Payload.(*dmmodel.ResolvedMeasurementsPayload); ok {
+			return ep.PersistMeasurementEvents(ctx, pevent, *payload)
+		}
+		return nil, fmt.Errorf("non-measurement payload in measurement event")
+	case esmodel.Alert:
+		if payload, ok := event.Payload.(*dmmodel.ResolvedAlertsPayload); ok {
+			return ep.PersistAlertEvents(ctx, pevent, *payload)
+		}
+		return nil, fmt.Errorf("non-alert payload in alert event")
 	}
 	return nil, fmt.Errorf("unhandled event type in persistence: %s", event.EventType.String())
 }
@@ -161,7 +531,12 @@ func (ep *EventPersistenceWorker) Process(ctx context.Context) {
 			// Attempt to resolve event.
 			results, err := ep.PersistEvent(ctx, *event)
 			if err != nil {
-				ep.Failed(0, *event, err)
+				var violation interface{ FailureReason() uint }
+				if errors.As(err, &violation) {
+					ep.Failed(violation.FailureReason(), *event, err)
+				} else {
+					ep.Failed(0, *event, err)
+				}
 			} else {
 				for _, result := range results.Events {
 					ep.Persisted(result)
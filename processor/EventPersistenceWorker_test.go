@@ -0,0 +1,197 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	dmodel "github.com/devicechain-io/dc-device-management/model"
+	"github.com/devicechain-io/dc-event-management/config"
+	"github.com/devicechain-io/dc-event-management/geo"
+	"github.com/devicechain-io/dc-event-management/model"
+	emtest "github.com/devicechain-io/dc-event-management/test"
+	"github.com/devicechain-io/dc-microservice/rdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Create a worker with only the location cache enabled.
+func newTestWorker(api model.EventManagementApi) *EventPersistenceWorker {
+	return NewEventPersistenceWorker(0, api, NewLocationCache(api), nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		0, nil, nil, nil, nil)
+}
+
+// Get location requests sent to the api.
+func locationRequests(api *emtest.MockApi) []*model.LocationEventCreateRequest {
+	requests := make([]*model.LocationEventCreateRequest, 0)
+	for _, call := range api.Calls {
+		if call.Method == "CreateLocationEvent" {
+			requests = append(requests, call.Arguments.Get(1).(*model.LocationEventCreateRequest))
+		}
+	}
+	return requests
+}
+
+// Test optional location attributes when present in and absent from entries.
+func TestPersistLocationAttributes(t *testing.T) {
+	api := new(emtest.MockApi)
+	api.Mock.On("LastLocation", mock.Anything, mock.Anything, mock.Anything).Return((*model.LocationEvent)(nil), nil)
+	api.Mock.On("CreateLocationEvent", mock.Anything, mock.Anything).Return(&model.LocationEvent{}, nil)
+	worker := newTestWorker(api)
+	ctx := context.Background()
+
+	lat, lon, ele, at := "33.7490", "-84.3880", "1234.5", "1654077600000"
+	event := model.Event{DeviceId: 1, OccurredTime: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)}
+	payload := dmodel.ResolvedLocationsPayload{Entries: []dmodel.ResolvedLocationEntry{
+		{Latitude: &lat, Longitude: &lon, Elevation: &ele, OccurredTime: &at},
+		{Latitude: &lat, Longitude: &lon},
+	}}
+	_, err := worker.PersistLocationEvents(ctx, event, payload)
+	assert.Nil(t, err)
+
+	requests := locationRequests(api)
+	assert.Equal(t, 2, len(requests))
+
+	// Present values are parsed.
+	assert.Equal(t, 33.7490, *requests[0].Latitude)
+	assert.Equal(t, -84.3880, *requests[0].Longitude)
+	assert.Equal(t, 1234.5, *requests[0].Elevation)
+	assert.Equal(t, time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC), requests[0].OccurredTime)
+
+	// Absent values are left unset and the event time is used.
+	assert.Nil(t, requests[1].Elevation)
+	assert.Equal(t, event.OccurredTime, requests[1].OccurredTime)
+
+	// Attributes not carried by resolved entries are never set.
+	for _, request := range requests {
+		assert.Nil(t, request.Accuracy)
+		assert.Nil(t, request.Speed)
+		assert.Nil(t, request.Course)
+		assert.Nil(t, request.Satellites)
+		assert.Nil(t, request.FixType)
+	}
+
+	// Values that do not parse fail the event.
+	bad := "high"
+	payload = dmodel.ResolvedLocationsPayload{Entries: []dmodel.ResolvedLocationEntry{
+		{Latitude: &lat, Longitude: &lon, Elevation: &bad},
+	}}
+	_, err = worker.PersistLocationEvents(ctx, event, payload)
+	assert.NotNil(t, err)
+}
+
+// Test measurements are stored in the unit of their definition.
+func TestPersistConvertedMeasurement(t *testing.T) {
+	api := new(emtest.MockApi)
+	definition := model.MeasurementDefinition{
+		TokenReference: rdb.TokenReference{Token: "temp"},
+		Unit:           sql.NullString{String: "celsius", Valid: true},
+		DataType:       model.MEASUREMENT_TYPE_FLOAT,
+		MaxValue:       sql.NullFloat64{Float64: 85, Valid: true},
+	}
+	api.Mock.On("AllMeasurementDefinitions", mock.Anything).Return([]model.MeasurementDefinition{definition}, nil)
+	api.Mock.On("CreateMeasurementEvent", mock.Anything, mock.Anything).Return(&model.MeasurementEvent{}, nil)
+	worker := newTestWorker(api)
+	worker.Registry = NewMeasurementRegistry(api, api.UnitConverter(), time.Hour, config.VALIDATION_ACCEPT,
+		config.VALIDATION_FLAG)
+
+	event := model.Event{DeviceId: 1, OccurredTime: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)}
+	payload := dmodel.ResolvedMeasurementsPayload{Entries: []dmodel.ResolvedMeasurementsEntry{{
+		Entries: []dmodel.ResolvedMeasurementEntry{
+			{Name: "temp:inDegreesFahrenheit", Value: "212"},
+			{Name: "temp:celsius", Value: "21.5"},
+		},
+	}}}
+	_, err := worker.PersistMeasurementEvents(context.Background(), event, payload)
+	assert.Nil(t, err)
+
+	requests := make([]*model.MeasurementEventCreateRequest, 0)
+	for _, call := range api.Calls {
+		if call.Method == "CreateMeasurementEvent" {
+			requests = append(requests, call.Arguments.Get(1).(*model.MeasurementEventCreateRequest))
+		}
+	}
+	assert.Equal(t, 2, len(requests))
+
+	// Converted values keep the reported value and unit and are checked in the definition unit.
+	assert.InDelta(t, 100, requests[0].Value, 1e-9)
+	assert.Equal(t, "celsius", *requests[0].Unit)
+	assert.Equal(t, 212.0, *requests[0].RawValue)
+	assert.Equal(t, "degreesFahrenheit", *requests[0].RawUnit)
+	assert.Equal(t, RULE_INVALID_VALUE, *requests[0].SuspectReason)
+
+	// Values already in the definition unit are stored as reported.
+	assert.Equal(t, 21.5, requests[1].Value)
+	assert.Nil(t, requests[1].RawValue)
+	assert.Nil(t, requests[1].RawUnit)
+	assert.Nil(t, requests[1].SuspectReason)
+}
+
+// Test measurements take the position (including elevation) of the last known device location.
+func TestPersistInferredMeasurementLocation(t *testing.T) {
+	api := new(emtest.MockApi)
+	api.Mock.On("CreateMeasurementEvent", mock.Anything, mock.Anything).Return(&model.MeasurementEvent{}, nil)
+	worker := newTestWorker(api)
+	worker.LocationAge = time.Hour
+
+	at := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	ele := 1523.25
+	worker.Locations.Update(1, TimedLocation{Point: geo.Point{Latitude: 33.749, Longitude: -84.388}, Elevation: &ele,
+		Time: at.Add(-time.Minute)})
+	event := model.Event{DeviceId: 1, OccurredTime: at}
+	payload := dmodel.ResolvedMeasurementsPayload{Entries: []dmodel.ResolvedMeasurementsEntry{{
+		Entries: []dmodel.ResolvedMeasurementEntry{{Name: "temp", Value: "21.5"}},
+	}}}
+	_, err := worker.PersistMeasurementEvents(context.Background(), event, payload)
+	assert.Nil(t, err)
+
+	request := api.Calls[0].Arguments.Get(1).(*model.MeasurementEventCreateRequest)
+	assert.True(t, request.LocationInferred)
+	assert.Equal(t, 33.749, *request.Latitude)
+	assert.Equal(t, ele, *request.Elevation)
+}
+
+// Test a rejected entry fails the event before any entry is stored. Entries are checked against
+// earlier entries of the same payload.
+func TestPersistRejectedLocation(t *testing.T) {
+	api := new(emtest.MockApi)
+	api.Mock.On("LastLocation", mock.Anything, mock.Anything, mock.Anything).Return((*model.LocationEvent)(nil), nil)
+	api.Mock.On("CreateLocationEvent", mock.Anything, mock.Anything).Return(&model.LocationEvent{}, nil)
+	worker := newTestWorker(api)
+	worker.Validator = &LocationValidator{ImplausibleJump: config.VALIDATION_REJECT, MaxSpeed: 100}
+	ctx := context.Background()
+
+	lat, lon, far := "33.7490", "-84.3880", "34.7490"
+	first, second := "1654077600000", "1654077610000"
+	event := model.Event{DeviceId: 1, OccurredTime: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)}
+	payload := dmodel.ResolvedLocationsPayload{Entries: []dmodel.ResolvedLocationEntry{
+		{Latitude: &lat, Longitude: &lon, OccurredTime: &first},
+		{Latitude: &far, Longitude: &lon, OccurredTime: &second},
+	}}
+	_, err := worker.PersistLocationEvents(ctx, event, payload)
+	assert.NotNil(t, err)
+	assert.Empty(t, locationRequests(api))
+
+	// Dropped entries do not keep the others from being stored.
+	worker.Validator.ImplausibleJump = config.VALIDATION_DROP
+	_, err = worker.PersistLocationEvents(ctx, event, payload)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(locationRequests(api)))
+}
@@ -0,0 +1,203 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/geo"
+	"github.com/devicechain-io/dc-event-management/model"
+	esmodel "github.com/devicechain-io/dc-event-sources/model"
+	"github.com/rs/zerolog/log"
+)
+
+// Geofence with its shape prepared for evaluation.
+type compiledGeofence struct {
+	Fence model.Geofence
+	Shape geo.Shape
+}
+
+// Evaluates device locations against geofences, generating enter/exit events on transitions.
+// A single evaluator is shared by all workers so that device state stays consistent. Updates
+// are serialized per device and state for idle devices is released (it is persisted, so it is
+// reloaded on next use).
+type GeofenceEvaluator struct {
+	Api             model.EventManagementApi
+	RefreshInterval time.Duration
+
+	lock    sync.Mutex
+	fences  []compiledGeofence
+	loaded  time.Time
+	devices *deviceStates
+}
+
+// Create a new geofence evaluator.
+func NewGeofenceEvaluator(api model.EventManagementApi, refresh time.Duration) *GeofenceEvaluator {
+	return &GeofenceEvaluator{
+		Api:             api,
+		RefreshInterval: refresh,
+		devices:         newDeviceStates(DEVICE_STATE_IDLE_TIME),
+	}
+}
+
+// Get geofences, reloading them if the refresh interval has elapsed. Geofences are loaded
+// without holding the lock so that evaluation for other devices is not blocked.
+func (ge *GeofenceEvaluator) refreshGeofences(ctx context.Context) ([]compiledGeofence, error) {
+	ge.lock.Lock()
+	fences, loaded := ge.fences, ge.loaded
+	ge.lock.Unlock()
+	if fences != nil && time.Since(loaded) < ge.RefreshInterval {
+		return fences, nil
+	}
+
+	found, err := ge.Api.ActiveGeofences(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fences = make([]compiledGeofence, 0)
+	for _, fence := range found {
+		shape, err := fence.Shape()
+		if err != nil {
+			log.Warn().Err(err).Str("geofence", fence.Token).Msg("Skipping geofence with invalid shape.")
+			continue
+		}
+		fences = append(fences, compiledGeofence{Fence: fence, Shape: shape})
+	}
+	ge.lock.Lock()
+	ge.fences = fences
+	ge.loaded = time.Now()
+	ge.lock.Unlock()
+	return fences, nil
+}
+
+// Geofence states for a device along with the time of the latest location evaluated against each
+// geofence. Only transitions are persisted, so evaluated times start at the last transition when
+// state is loaded.
+type geofenceDevice struct {
+	States    map[uint]*model.GeofenceState
+	Evaluated map[uint]time.Time
+}
+
+// Get geofence states for a device (with its entry locked), loading persisted state on first use.
+func (ge *GeofenceEvaluator) deviceStates(ctx context.Context, entry *deviceEntry,
+	deviceId uint) (*geofenceDevice, error) {
+	if device, ok := entry.State.(*geofenceDevice); ok {
+		return device, nil
+	}
+	found, err := ge.Api.GeofenceStates(ctx, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	device := &geofenceDevice{
+		States:    make(map[uint]*model.GeofenceState),
+		Evaluated: make(map[uint]time.Time),
+	}
+	for i := range found {
+		device.States[found[i].GeofenceId] = &found[i]
+		device.Evaluated[found[i].GeofenceId] = found[i].OccurredTime
+	}
+	entry.State = device
+	return device, nil
+}
+
+// Evaluate a device location against relevant geofences. Geofences linked to an area only
+// apply to events related to that area. Locations older than the latest one evaluated for a
+// geofence are ignored, so fixes processed out of order do not cause spurious transitions.
+func (ge *GeofenceEvaluator) Evaluate(ctx context.Context, event model.Event, latitude float64,
+	longitude float64) ([]*model.GeofenceEvent, error) {
+	fences, err := ge.refreshGeofences(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entry := ge.devices.acquire(event.DeviceId)
+	defer ge.devices.release(entry)
+
+	point := geo.Point{Latitude: latitude, Longitude: longitude}
+	var device *geofenceDevice
+	generated := make([]*model.GeofenceEvent, 0)
+	for _, fence := range fences {
+		if fence.Fence.AreaId != nil && (event.RelAreaId == nil || *event.RelAreaId != *fence.Fence.AreaId) {
+			continue
+		}
+		if device == nil {
+			device, err = ge.deviceStates(ctx, entry, event.DeviceId)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if event.OccurredTime.Before(device.Evaluated[fence.Fence.ID]) {
+			continue
+		}
+		device.Evaluated[fence.Fence.ID] = event.OccurredTime
+
+		inside := fence.Shape.Contains(point)
+		previous := device.States[fence.Fence.ID]
+		if inside == (previous != nil && previous.Inside) {
+			continue
+		}
+
+		transition := model.GEOFENCE_EXIT
+		if inside {
+			transition = model.GEOFENCE_ENTER
+		}
+		gevent := event
+		gevent.EventType = esmodel.StateChange
+		created, err := ge.Api.CreateGeofenceEvent(ctx, &model.GeofenceEventCreateRequest{
+			Event:      gevent,
+			GeofenceId: fence.Fence.ID,
+			Transition: transition,
+			Latitude:   latitude,
+			Longitude:  longitude,
+		})
+		if err != nil {
+			return generated, err
+		}
+
+		state := &model.GeofenceState{
+			DeviceId:     event.DeviceId,
+			GeofenceId:   fence.Fence.ID,
+			Inside:       inside,
+			OccurredTime: event.OccurredTime,
+		}
+		err = ge.Api.SaveGeofenceState(ctx, state)
+		if err != nil {
+			return generated, err
+		}
+		device.States[fence.Fence.ID] = state
+		generated = append(generated, created)
+	}
+	return generated, nil
+}
+
+// Get ids of geofences a device was last known to be inside.
+func (ge *GeofenceEvaluator) Inside(deviceId uint) []uint {
+	entry := ge.devices.acquire(deviceId)
+	defer ge.devices.release(entry)
+	inside := make([]uint, 0)
+	if device, ok := entry.State.(*geofenceDevice); ok {
+		for id, state := range device.States {
+			if state.Inside {
+				inside = append(inside, id)
+			}
+		}
+	}
+	sort.Slice(inside, func(i, j int) bool { return inside[i] < inside[j] })
+	return inside
+}
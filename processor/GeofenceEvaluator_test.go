@@ -0,0 +1,86 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	"github.com/devicechain-io/dc-event-management/test"
+	"github.com/devicechain-io/dc-microservice/rdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+// Test enter/exit transitions, including locations processed out of order.
+func TestGeofenceEvaluation(t *testing.T) {
+	area := uint(5)
+	fences := []model.Geofence{
+		{
+			Model:           gorm.Model{ID: 1},
+			TokenReference:  rdb.TokenReference{Token: "depot"},
+			CenterLatitude:  sql.NullFloat64{Float64: 33.75, Valid: true},
+			CenterLongitude: sql.NullFloat64{Float64: -84.39, Valid: true},
+			Radius:          sql.NullFloat64{Float64: 500, Valid: true},
+		},
+		{
+			Model:           gorm.Model{ID: 2},
+			TokenReference:  rdb.TokenReference{Token: "yard"},
+			AreaId:          &area,
+			CenterLatitude:  sql.NullFloat64{Float64: 33.75, Valid: true},
+			CenterLongitude: sql.NullFloat64{Float64: -84.39, Valid: true},
+			Radius:          sql.NullFloat64{Float64: 500, Valid: true},
+		},
+	}
+	api := &test.MockApi{}
+	api.Mock.On("ActiveGeofences", mock.Anything).Return(fences, nil)
+	api.Mock.On("GeofenceStates", mock.Anything).Return([]model.GeofenceState{}, nil)
+	api.Mock.On("CreateGeofenceEvent", mock.Anything).Return(&model.GeofenceEvent{}, nil)
+	api.Mock.On("SaveGeofenceState", mock.Anything).Return(nil)
+
+	evaluator := NewGeofenceEvaluator(api, time.Hour)
+	ctx := context.Background()
+	start := time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC)
+	evaluate := func(offset time.Duration, inside bool) int {
+		lat := 33.75
+		if !inside {
+			lat = 33.80
+		}
+		event := model.Event{DeviceId: 1, OccurredTime: start.Add(offset)}
+		generated, err := evaluator.Evaluate(ctx, event, lat, -84.39)
+		assert.Nil(t, err)
+		return len(generated)
+	}
+
+	// Entering and staying inside generates a single transition.
+	assert.Equal(t, 1, evaluate(10*time.Second, true))
+	assert.Equal(t, 0, evaluate(20*time.Second, true))
+	assert.Equal(t, []uint{1}, evaluator.Inside(1))
+
+	// A late location outside does not cause an exit (and a re-enter).
+	assert.Equal(t, 0, evaluate(15*time.Second, false))
+	assert.Equal(t, 0, evaluate(30*time.Second, true))
+
+	// Leaving generates an exit. Geofences for an area only apply to events related to it.
+	assert.Equal(t, 1, evaluate(40*time.Second, false))
+	assert.Empty(t, evaluator.Inside(1))
+	api.Mock.AssertNumberOfCalls(t, "CreateGeofenceEvent", 2)
+}
@@ -0,0 +1,167 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/geo"
+	"github.com/devicechain-io/dc-event-management/model"
+)
+
+// Location reported by a device at a point in time (elevation in meters if reported).
+type TimedLocation struct {
+	geo.Point
+	Elevation *float64
+	Time      time.Time
+}
+
+const (
+	LOCATION_UNLOCATED_TIME = 5 * time.Minute // Time a device without stored locations is not looked up again
+)
+
+// Cached locations for a device. Unlocated is when a lookup last found no stored location (zero
+// unless the device is known to have none).
+type deviceLocations struct {
+	Latest    *TimedLocation
+	Unlocated time.Time
+}
+
+// Caches the most recent location of each device so that values derived from the previous
+// location do not require a query per event. A single cache is shared by all workers. Lookups
+// are serialized per device and locations for idle devices are released.
+type LocationCache struct {
+	Api           model.EventManagementApi
+	UnlocatedTime time.Duration
+
+	devices *deviceStates
+}
+
+// Create a new location cache.
+func NewLocationCache(api model.EventManagementApi) *LocationCache {
+	return &LocationCache{
+		Api:           api,
+		UnlocatedTime: LOCATION_UNLOCATED_TIME,
+		devices:       newDeviceStates(DEVICE_STATE_IDLE_TIME),
+	}
+}
+
+// Get cached locations for a device with its entry locked.
+func (lc *LocationCache) deviceLocations(entry *deviceEntry) *deviceLocations {
+	if locations, ok := entry.State.(*deviceLocations); ok {
+		return locations
+	}
+	locations := &deviceLocations{}
+	entry.State = locations
+	return locations
+}
+
+// Get the previous location from the cache or datastore with the device entry locked.
+func (lc *LocationCache) previous(ctx context.Context, locations *deviceLocations, deviceId uint,
+	before time.Time) (*TimedLocation, error) {
+	if locations.Latest != nil && locations.Latest.Time.Before(before) {
+		latest := *locations.Latest
+		return &latest, nil
+	}
+
+	found, err := lc.Api.LastLocation(ctx, deviceId, before)
+	if err != nil || found == nil {
+		return nil, err
+	}
+	location := &TimedLocation{
+		Point: geo.Point{Latitude: found.Latitude.Float64, Longitude: found.Longitude.Float64},
+		Time:  found.OccurredTime,
+	}
+	if found.Elevation.Valid {
+		location.Elevation = &found.Elevation.Float64
+	}
+	return location, nil
+}
+
+// Get the location reported by a device most recently before the given time (nil if none).
+// Locations arriving out of order are looked up in the datastore.
+func (lc *LocationCache) Previous(ctx context.Context, deviceId uint, before time.Time) (*TimedLocation, error) {
+	entry := lc.devices.acquire(deviceId)
+	defer lc.devices.release(entry)
+	return lc.previous(ctx, lc.deviceLocations(entry), deviceId, before)
+}
+
+// Get the most recent location of a device at or before the given time if it is no older than
+// the max age (nil if none). Devices without any stored location are remembered for the
+// unlocated time so that devices which never report locations do not require a query per event,
+// while locations stored by other instances are still found.
+func (lc *LocationCache) Recent(ctx context.Context, deviceId uint, at time.Time,
+	maxAge time.Duration) (*TimedLocation, error) {
+	entry := lc.devices.acquire(deviceId)
+	defer lc.devices.release(entry)
+
+	locations := lc.deviceLocations(entry)
+	if !locations.Unlocated.IsZero() && time.Since(locations.Unlocated) < lc.UnlocatedTime {
+		return nil, nil
+	}
+	location, err := lc.previous(ctx, locations, deviceId, at.Add(time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+	if location == nil {
+		if locations.Latest == nil {
+			locations.Unlocated = time.Now()
+		}
+		return nil, nil
+	}
+	locations.Unlocated = time.Time{}
+	if at.Sub(location.Time) > maxAge {
+		return nil, nil
+	}
+	return location, nil
+}
+
+// Record a location for a device if it is newer than the cached one.
+func (lc *LocationCache) Update(deviceId uint, location TimedLocation) {
+	entry := lc.devices.acquire(deviceId)
+	defer lc.devices.release(entry)
+
+	locations := lc.deviceLocations(entry)
+	locations.Unlocated = time.Time{}
+	if locations.Latest == nil || location.Time.After(locations.Latest.Time) {
+		locations.Latest = &location
+	}
+}
+
+// Values derived from the movement between two locations.
+type LocationDerivation struct {
+	Speed    *float64 // Meters per second
+	Heading  *float64 // Degrees clockwise from north
+	Distance *float64 // Meters
+}
+
+// Derive speed, heading and distance traveled since a previous location. Heading is left unset
+// when the device has not moved and speed when no time has elapsed.
+func DeriveMovement(previous TimedLocation, current TimedLocation) LocationDerivation {
+	distance := geo.Distance(previous.Point, current.Point)
+	derived := LocationDerivation{Distance: &distance}
+	if distance > 0 {
+		heading := geo.Bearing(previous.Point, current.Point)
+		derived.Heading = &heading
+	}
+	if elapsed := current.Time.Sub(previous.Time).Seconds(); elapsed > 0 {
+		speed := distance / elapsed
+		derived.Speed = &speed
+	}
+	return derived
+}
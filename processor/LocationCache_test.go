@@ -0,0 +1,121 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/geo"
+	"github.com/devicechain-io/dc-event-management/model"
+	"github.com/devicechain-io/dc-event-management/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Test values derived from consecutive locations.
+func TestDeriveMovement(t *testing.T) {
+	start := time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC)
+	previous := TimedLocation{Point: geo.Point{Latitude: 0, Longitude: 0}, Time: start}
+
+	// Moving east about 111 meters in 10 seconds.
+	derived := DeriveMovement(previous, TimedLocation{Point: geo.Point{Latitude: 0, Longitude: 0.001}, Time: start.Add(10 * time.Second)})
+	assert.InDelta(t, 111.2, *derived.Distance, 0.5)
+	assert.InDelta(t, 11.12, *derived.Speed, 0.05)
+	assert.InDelta(t, 90, *derived.Heading, 0.001)
+
+	// Stationary device has no heading.
+	derived = DeriveMovement(previous, TimedLocation{Point: previous.Point, Time: start.Add(time.Minute)})
+	assert.Equal(t, 0.0, *derived.Distance)
+	assert.Equal(t, 0.0, *derived.Speed)
+	assert.Nil(t, derived.Heading)
+
+	// Locations at the same time have no speed.
+	derived = DeriveMovement(previous, TimedLocation{Point: geo.Point{Latitude: 0.001, Longitude: 0}, Time: start})
+	assert.Nil(t, derived.Speed)
+}
+
+// Test looking up recent locations attached to measurements.
+func TestRecentLocation(t *testing.T) {
+	start := time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC)
+	api := &test.MockApi{}
+	api.Mock.On("LastLocation", mock.Anything).Return((*model.LocationEvent)(nil), nil).Once()
+	api.Mock.On("LastLocation", mock.Anything).Return(&model.LocationEvent{
+		OccurredTime: start.Add(-time.Hour),
+		Latitude:     sql.NullFloat64{Float64: 33.75, Valid: true},
+		Longitude:    sql.NullFloat64{Float64: -84.39, Valid: true},
+		Elevation:    sql.NullFloat64{Float64: 320.5, Valid: true},
+	}, nil)
+	cache := NewLocationCache(api)
+	ctx := context.Background()
+
+	// Devices without stored locations are only looked up once.
+	found, err := cache.Recent(ctx, 1, start, time.Hour)
+	assert.Nil(t, err)
+	assert.Nil(t, found)
+	found, err = cache.Recent(ctx, 1, start, time.Hour)
+	assert.Nil(t, err)
+	assert.Nil(t, found)
+	api.Mock.AssertNumberOfCalls(t, "LastLocation", 1)
+
+	// Devices are looked up again once the unlocated time has passed.
+	cache.UnlocatedTime = 0
+	found, err = cache.Recent(ctx, 1, start, time.Hour)
+	assert.Nil(t, err)
+	assert.NotNil(t, found)
+	api.Mock.AssertNumberOfCalls(t, "LastLocation", 2)
+	cache.UnlocatedTime = LOCATION_UNLOCATED_TIME
+
+	// Cached locations at the same time are used.
+	cache.Update(1, TimedLocation{Point: geo.Point{Latitude: 1, Longitude: 2}, Time: start})
+	found, err = cache.Recent(ctx, 1, start, time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 1.0, found.Latitude)
+
+	// Locations older than the max age are not used.
+	found, err = cache.Recent(ctx, 1, start.Add(2*time.Hour), time.Hour)
+	assert.Nil(t, err)
+	assert.Nil(t, found)
+
+	// Earlier measurements fall back to the datastore.
+	found, err = cache.Recent(ctx, 1, start.Add(-time.Minute), time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 33.75, found.Latitude)
+	assert.Equal(t, 320.5, *found.Elevation)
+	found, err = cache.Recent(ctx, 1, start.Add(-time.Minute), time.Minute)
+	assert.Nil(t, err)
+	assert.Nil(t, found)
+}
+
+// Test cached locations are released once devices are idle.
+func TestLocationCacheEviction(t *testing.T) {
+	start := time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC)
+	cache := NewLocationCache(&test.MockApi{})
+	for device := uint(1); device <= 3; device++ {
+		cache.Update(device, TimedLocation{Point: geo.Point{Latitude: 1, Longitude: 2}, Time: start})
+	}
+	assert.Equal(t, 3, cache.devices.size())
+
+	for _, entry := range cache.devices.entries {
+		entry.used = time.Now().Add(-2 * time.Hour)
+	}
+	cache.devices.swept = time.Now().Add(-2 * time.Hour)
+	cache.Update(4, TimedLocation{Point: geo.Point{Latitude: 1, Longitude: 2}, Time: start})
+	assert.Equal(t, 1, cache.devices.size())
+}
@@ -0,0 +1,121 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/config"
+	"github.com/devicechain-io/dc-event-management/geo"
+)
+
+// Failure reasons reported for rejected locations. Values start after the reasons defined by
+// device management so that consumers of the failed events topic can tell them apart.
+const (
+	FAILURE_LOCATION_OUT_OF_RANGE uint = 100 + iota
+	FAILURE_LOCATION_NOT_FINITE
+	FAILURE_LOCATION_NULL_ISLAND
+	FAILURE_LOCATION_IMPLAUSIBLE_JUMP
+)
+
+// Names of location validation rules (stored as the reason for suspect locations).
+const (
+	RULE_OUT_OF_RANGE     = "out-of-range"
+	RULE_NOT_FINITE       = "not-finite"
+	RULE_NULL_ISLAND      = "null-island"
+	RULE_IMPLAUSIBLE_JUMP = "implausible-jump"
+)
+
+// Describes a location that failed a validation rule. Coordinates that are unusable may not be
+// stored and are cleared if the location is flagged rather than rejected.
+type LocationViolation struct {
+	Rule     string
+	Reason   uint
+	Action   string
+	Message  string
+	Unusable bool
+}
+
+func (v *LocationViolation) Error() string {
+	return v.Message
+}
+
+// Reason reported on the failed events topic.
+func (v *LocationViolation) FailureReason() uint {
+	return v.Reason
+}
+
+// Validates locations against the configured rules before they are stored.
+type LocationValidator struct {
+	OutOfRange      string
+	NotFinite       string
+	NullIsland      string
+	ImplausibleJump string
+	MaxSpeed        float64
+}
+
+// Create a new location validator (nil if validation is disabled).
+func NewLocationValidator(cfg config.LocationValidationConfiguration) *LocationValidator {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &LocationValidator{
+		OutOfRange:      cfg.OutOfRange,
+		NotFinite:       cfg.NotFinite,
+		NullIsland:      cfg.NullIsland,
+		ImplausibleJump: cfg.ImplausibleJump,
+		MaxSpeed:        cfg.MaxSpeed,
+	}
+}
+
+// Checks whether a (possibly null) value is NaN or infinite.
+func notFinite(val *float64) bool {
+	return val != nil && (math.IsNaN(*val) || math.IsInf(*val, 0))
+}
+
+// Validate a location against each enforced rule in order, returning the first violation (nil if
+// the location is valid). Rules set to accept are not checked. The previous location of the device is used to detect jumps.
+func (lv *LocationValidator) Validate(lat *float64, lon *float64, ele *float64, occurred time.Time,
+	previous *TimedLocation) *LocationViolation {
+	if enforced(lv.NotFinite) && (notFinite(lat) || notFinite(lon) || notFinite(ele)) {
+		return &LocationViolation{Rule: RULE_NOT_FINITE, Reason: FAILURE_LOCATION_NOT_FINITE, Action: lv.NotFinite,
+			Message: "location has non-finite coordinates", Unusable: true}
+	}
+	if lat == nil || lon == nil {
+		return nil
+	}
+	if enforced(lv.OutOfRange) && (*lat < -90 || *lat > 90 || *lon < -180 || *lon > 180) {
+		return &LocationViolation{Rule: RULE_OUT_OF_RANGE, Reason: FAILURE_LOCATION_OUT_OF_RANGE, Action: lv.OutOfRange,
+			Message: fmt.Sprintf("location coordinates out of range: (%f, %f)", *lat, *lon), Unusable: true}
+	}
+	if enforced(lv.NullIsland) && *lat == 0 && *lon == 0 {
+		return &LocationViolation{Rule: RULE_NULL_ISLAND, Reason: FAILURE_LOCATION_NULL_ISLAND, Action: lv.NullIsland,
+			Message: "location reported at (0, 0)"}
+	}
+	if enforced(lv.ImplausibleJump) && lv.MaxSpeed > 0 && previous != nil {
+		elapsed := occurred.Sub(previous.Time).Seconds()
+		distance := geo.Distance(previous.Point, geo.Point{Latitude: *lat, Longitude: *lon})
+		if elapsed > 0 && distance/elapsed > lv.MaxSpeed {
+			return &LocationViolation{Rule: RULE_IMPLAUSIBLE_JUMP, Reason: FAILURE_LOCATION_IMPLAUSIBLE_JUMP,
+				Action: lv.ImplausibleJump, Message: fmt.Sprintf("location is %.0f meters from previous after %.0f seconds",
+					distance, elapsed)}
+		}
+	}
+	return nil
+}
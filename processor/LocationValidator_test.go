@@ -0,0 +1,86 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/config"
+	"github.com/devicechain-io/dc-event-management/geo"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test each location validation rule.
+func TestLocationValidation(t *testing.T) {
+	validator := NewLocationValidator(config.NewEventManagementConfiguration().LocationValidation)
+	now := time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC)
+	float := func(val float64) *float64 { return &val }
+
+	// Valid location with and without a previous location.
+	assert.Nil(t, validator.Validate(float(33.749), float(-84.388), nil, now, nil))
+	previous := &TimedLocation{Point: geo.Point{Latitude: 33.748, Longitude: -84.388}, Time: now.Add(-time.Minute)}
+	assert.Nil(t, validator.Validate(float(33.749), float(-84.388), float(300), now, previous))
+
+	// Non-finite values are unusable.
+	violation := validator.Validate(float(math.NaN()), float(-84.388), nil, now, nil)
+	assert.Equal(t, RULE_NOT_FINITE, violation.Rule)
+	assert.Equal(t, FAILURE_LOCATION_NOT_FINITE, violation.Reason)
+	assert.True(t, violation.Unusable)
+	violation = validator.Validate(float(33.749), float(-84.388), float(math.Inf(1)), now, nil)
+	assert.Equal(t, RULE_NOT_FINITE, violation.Rule)
+
+	// Coordinates out of range are rejected by default.
+	violation = validator.Validate(float(91), float(0), nil, now, nil)
+	assert.Equal(t, RULE_OUT_OF_RANGE, violation.Rule)
+	assert.Equal(t, config.VALIDATION_REJECT, violation.Action)
+	violation = validator.Validate(float(0), float(-180.5), nil, now, nil)
+	assert.Equal(t, RULE_OUT_OF_RANGE, violation.Rule)
+
+	// Null island is flagged by default.
+	violation = validator.Validate(float(0), float(0), nil, now, nil)
+	assert.Equal(t, RULE_NULL_ISLAND, violation.Rule)
+	assert.Equal(t, config.VALIDATION_FLAG, violation.Action)
+	assert.False(t, violation.Unusable)
+
+	// Moving about 111 km in a minute is not plausible.
+	previous = &TimedLocation{Point: geo.Point{Latitude: 32.749, Longitude: -84.388}, Time: now.Add(-time.Minute)}
+	violation = validator.Validate(float(33.749), float(-84.388), nil, now, previous)
+	assert.Equal(t, RULE_IMPLAUSIBLE_JUMP, violation.Rule)
+	assert.Equal(t, FAILURE_LOCATION_IMPLAUSIBLE_JUMP, violation.Reason)
+
+	// Disabled and accepted rules are skipped.
+	validator.NullIsland = ""
+	assert.Nil(t, validator.Validate(float(0), float(0), nil, now, nil))
+	validator.ImplausibleJump = config.VALIDATION_ACCEPT
+	assert.Nil(t, validator.Validate(float(33.749), float(-84.388), nil, now, previous))
+	assert.Nil(t, NewLocationValidator(config.LocationValidationConfiguration{}))
+}
+
+// Test validation actions are checked when configuration is loaded.
+func TestValidationActions(t *testing.T) {
+	cfg := config.NewEventManagementConfiguration()
+	assert.Nil(t, cfg.Validate())
+	cfg.LocationValidation.NullIsland = ""
+	assert.Nil(t, cfg.Validate())
+	cfg.LocationValidation.NullIsland = "flagged"
+	assert.NotNil(t, cfg.Validate())
+	cfg.LocationValidation.NullIsland = config.VALIDATION_FLAG
+	cfg.Measurements.InvalidValues = "rejct"
+	assert.NotNil(t, cfg.Validate())
+}
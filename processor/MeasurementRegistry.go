@@ -0,0 +1,166 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/config"
+	"github.com/devicechain-io/dc-event-management/model"
+)
+
+// Failure reasons reported for rejected measurements.
+const (
+	FAILURE_MEASUREMENT_UNKNOWN uint = 200 + iota
+	FAILURE_MEASUREMENT_INVALID
+)
+
+// Names of measurement validation rules (stored as the reason for suspect measurements).
+const (
+	RULE_UNKNOWN_MEASUREMENT = "unknown-measurement"
+	RULE_INVALID_VALUE       = "invalid-value"
+	RULE_INVALID_UNIT        = "invalid-unit"
+)
+
+// Describes a measurement that failed a validation rule.
+type MeasurementViolation struct {
+	Rule    string
+	Reason  uint
+	Action  string
+	Message string
+}
+
+func (v *MeasurementViolation) Error() string {
+	return v.Message
+}
+
+// Reason reported on the failed events topic.
+func (v *MeasurementViolation) FailureReason() uint {
+	return v.Reason
+}
+
+// Measurement value to be stored. Values are converted to the unit of their definition, in which
+// case the reported value and unit are kept.
+type MeasurementValue struct {
+	Value    float64
+	Unit     string
+	RawValue *float64
+	RawUnit  *string
+}
+
+// Checks measurements against registered definitions. A single registry is shared by all workers.
+type MeasurementRegistry struct {
+	Api             model.EventManagementApi
+	Units           *model.UnitConverter
+	RefreshInterval time.Duration
+	Unknown         string
+	InvalidValues   string
+
+	lock        sync.Mutex
+	definitions map[string]model.MeasurementDefinition
+	loaded      time.Time
+}
+
+// Create a new measurement registry.
+func NewMeasurementRegistry(api model.EventManagementApi, units *model.UnitConverter, refresh time.Duration,
+	unknown string, invalid string) *MeasurementRegistry {
+	return &MeasurementRegistry{
+		Api:             api,
+		Units:           units,
+		RefreshInterval: refresh,
+		Unknown:         unknown,
+		InvalidValues:   invalid,
+	}
+}
+
+// Get the definition for a measurement name, reloading definitions if the refresh interval has
+// elapsed (nil if not registered).
+func (mr *MeasurementRegistry) Definition(ctx context.Context, name string) (*model.MeasurementDefinition, error) {
+	mr.lock.Lock()
+	defer mr.lock.Unlock()
+	if mr.definitions == nil || time.Since(mr.loaded) >= mr.RefreshInterval {
+		found, err := mr.Api.AllMeasurementDefinitions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		definitions := make(map[string]model.MeasurementDefinition)
+		for _, definition := range found {
+			definitions[definition.Token] = definition
+		}
+		mr.definitions = definitions
+		mr.loaded = time.Now()
+	}
+	if definition, ok := mr.definitions[name]; ok {
+		return &definition, nil
+	}
+	return nil, nil
+}
+
+// Indicates whether an action requires a rule to be checked.
+func enforced(action string) bool {
+	return action != "" && action != config.VALIDATION_ACCEPT
+}
+
+// Validate a measurement value reported in the given unit (empty if none) against its definition.
+// Values reported in another unit than the definition are converted first; units that can not be
+// converted are handled as invalid values and stored as reported. Returns the definition (nil if
+// unknown), the value to store and the violation (nil if valid or the rule is not enforced).
+func (mr *MeasurementRegistry) Validate(ctx context.Context, name string, unit string,
+	value float64) (*model.MeasurementDefinition, MeasurementValue, *MeasurementViolation, error) {
+	measured := MeasurementValue{Value: value, Unit: unit}
+	definition, err := mr.Definition(ctx, name)
+	if err != nil {
+		return nil, measured, nil, err
+	}
+	if definition == nil {
+		if enforced(mr.Unknown) {
+			return nil, measured, &MeasurementViolation{Rule: RULE_UNKNOWN_MEASUREMENT, Reason: FAILURE_MEASUREMENT_UNKNOWN,
+				Action: mr.Unknown, Message: "unknown measurement: " + name}, nil
+		}
+		return nil, measured, nil, nil
+	}
+
+	// Convert to the unit of the definition.
+	if definition.Unit.Valid && definition.Unit.String != "" {
+		if unit != "" && !strings.EqualFold(unit, definition.Unit.String) {
+			converted, err := mr.Units.Convert(value, unit, definition.Unit.String)
+			if err != nil {
+				if enforced(mr.InvalidValues) {
+					return definition, measured, &MeasurementViolation{Rule: RULE_INVALID_UNIT, Reason: FAILURE_MEASUREMENT_INVALID,
+						Action: mr.InvalidValues, Message: fmt.Sprintf("measurement '%s' can not be converted from %s to %s: %s",
+							name, unit, definition.Unit.String, err.Error())}, nil
+				}
+				return definition, measured, nil, nil
+			}
+			raw, rawUnit := value, unit
+			measured = MeasurementValue{Value: converted, RawValue: &raw, RawUnit: &rawUnit}
+		}
+		measured.Unit = definition.Unit.String
+	}
+
+	if enforced(mr.InvalidValues) {
+		if err := definition.Validate(measured.Value); err != nil {
+			return definition, measured, &MeasurementViolation{Rule: RULE_INVALID_VALUE, Reason: FAILURE_MEASUREMENT_INVALID,
+				Action: mr.InvalidValues, Message: err.Error()}, nil
+		}
+	}
+	return definition, measured, nil, nil
+}
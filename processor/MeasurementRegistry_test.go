@@ -0,0 +1,119 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/config"
+	"github.com/devicechain-io/dc-event-management/model"
+	"github.com/devicechain-io/dc-event-management/test"
+	"github.com/devicechain-io/dc-microservice/rdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Test parsing of raw measurement names.
+func TestParseMeasurementName(t *testing.T) {
+	name, unit := model.ParseMeasurementName("temp:inDegreesCelcius")
+	assert.Equal(t, "temp", name)
+	assert.Equal(t, "degreesCelcius", unit)
+	name, unit = model.ParseMeasurementName("speed:mph")
+	assert.Equal(t, "speed", name)
+	assert.Equal(t, "mph", unit)
+	name, unit = model.ParseMeasurementName("humidity")
+	assert.Equal(t, "humidity", name)
+	assert.Equal(t, "", unit)
+	_, unit = model.ParseMeasurementName("level:index")
+	assert.Equal(t, "index", unit)
+
+	// Known units starting with "in" keep their prefix.
+	name, unit = model.ParseMeasurementName("pressure:inHg")
+	assert.Equal(t, "pressure", name)
+	assert.Equal(t, "inHg", unit)
+	_, unit = model.ParseMeasurementName("pressure:inInchesOfMercury")
+	assert.Equal(t, "inchesOfMercury", unit)
+}
+
+// Test measurements checked against registered definitions.
+func TestMeasurementRegistry(t *testing.T) {
+	api := &test.MockApi{}
+	definition := model.MeasurementDefinition{
+		TokenReference: rdb.TokenReference{Token: "temp"},
+		Unit:           sql.NullString{String: "degreesCelcius", Valid: true},
+		DataType:       model.MEASUREMENT_TYPE_FLOAT,
+		MinValue:       sql.NullFloat64{Float64: -40, Valid: true},
+		MaxValue:       sql.NullFloat64{Float64: 85, Valid: true},
+	}
+	api.Mock.On("AllMeasurementDefinitions", mock.Anything).Return([]model.MeasurementDefinition{definition}, nil)
+	registry := NewMeasurementRegistry(api, model.NewUnitConverter(), time.Hour, config.VALIDATION_REJECT,
+		config.VALIDATION_FLAG)
+	ctx := context.Background()
+
+	// Values within range are valid and take the unit of the definition if none is reported.
+	found, measured, violation, err := registry.Validate(ctx, "temp", "", 21.5)
+	assert.Nil(t, err)
+	assert.Nil(t, violation)
+	assert.Equal(t, "degreesCelcius", found.Unit.String)
+	assert.Equal(t, MeasurementValue{Value: 21.5, Unit: "degreesCelcius"}, measured)
+
+	// Values out of range are flagged.
+	_, _, violation, err = registry.Validate(ctx, "temp", "", 120)
+	assert.Nil(t, err)
+	assert.Equal(t, RULE_INVALID_VALUE, violation.Rule)
+	assert.Equal(t, config.VALIDATION_FLAG, violation.Action)
+
+	// Values in other units are converted before the range is checked, keeping the reported value.
+	_, measured, violation, err = registry.Validate(ctx, "temp", "fahrenheit", 194)
+	assert.Nil(t, err)
+	assert.Equal(t, RULE_INVALID_VALUE, violation.Rule)
+	assert.InDelta(t, 90, measured.Value, 1e-9)
+	_, measured, violation, err = registry.Validate(ctx, "temp", "fahrenheit", 98.6)
+	assert.Nil(t, err)
+	assert.Nil(t, violation)
+	assert.InDelta(t, 37, measured.Value, 1e-9)
+	assert.Equal(t, "degreesCelcius", measured.Unit)
+	assert.Equal(t, 98.6, *measured.RawValue)
+	assert.Equal(t, "fahrenheit", *measured.RawUnit)
+
+	// Units that can not be converted are handled as invalid values and stored as reported.
+	_, measured, violation, err = registry.Validate(ctx, "temp", "psi", 30)
+	assert.Nil(t, err)
+	assert.Equal(t, RULE_INVALID_UNIT, violation.Rule)
+	assert.Equal(t, config.VALIDATION_FLAG, violation.Action)
+	assert.Equal(t, MeasurementValue{Value: 30, Unit: "psi"}, measured)
+	_, _, violation, _ = registry.Validate(ctx, "temp", "furlongs", 30)
+	assert.Equal(t, RULE_INVALID_UNIT, violation.Rule)
+
+	// Unknown measurements are rejected.
+	found, measured, violation, err = registry.Validate(ctx, "pressure", "hpa", 1013)
+	assert.Nil(t, err)
+	assert.Nil(t, found)
+	assert.Equal(t, FAILURE_MEASUREMENT_UNKNOWN, violation.FailureReason())
+	assert.Equal(t, MeasurementValue{Value: 1013, Unit: "hpa"}, measured)
+
+	// Accepted measurements are not checked.
+	registry.Unknown = config.VALIDATION_ACCEPT
+	_, _, violation, _ = registry.Validate(ctx, "pressure", "", 1013)
+	assert.Nil(t, violation)
+
+	// Definitions are only loaded once per refresh interval.
+	api.Mock.AssertNumberOfCalls(t, "AllMeasurementDefinitions", 1)
+}
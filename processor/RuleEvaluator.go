@@ -0,0 +1,225 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	esmodel "github.com/devicechain-io/dc-event-sources/model"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	RULE_ALERT_SOURCE = "threshold-rule" // Source recorded on alerts generated by threshold rules
+)
+
+// Evaluates measurements against threshold rules, generating alerts when rules are raised or
+// cleared. A single evaluator is shared by all workers so that device state stays consistent.
+// Updates are serialized per device and state for idle devices is released (it is persisted,
+// so it is reloaded on next use).
+type RuleEvaluator struct {
+	Api             model.EventManagementApi
+	RefreshInterval time.Duration
+
+	lock    sync.Mutex
+	rules   []model.ThresholdRule
+	loaded  time.Time
+	devices *deviceStates
+}
+
+// Create a new rule evaluator.
+func NewRuleEvaluator(api model.EventManagementApi, refresh time.Duration) *RuleEvaluator {
+	return &RuleEvaluator{
+		Api:             api,
+		RefreshInterval: refresh,
+		devices:         newDeviceStates(DEVICE_STATE_IDLE_TIME),
+	}
+}
+
+// Get rules, reloading them if the refresh interval has elapsed. Rules are loaded without
+// holding the lock so that evaluation for other devices is not blocked.
+func (re *RuleEvaluator) refreshRules(ctx context.Context) ([]model.ThresholdRule, error) {
+	re.lock.Lock()
+	rules, loaded := re.rules, re.loaded
+	re.lock.Unlock()
+	if rules != nil && time.Since(loaded) < re.RefreshInterval {
+		return rules, nil
+	}
+
+	found, err := re.Api.ActiveThresholdRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	re.lock.Lock()
+	re.rules = found
+	re.loaded = time.Now()
+	re.lock.Unlock()
+	return found, nil
+}
+
+// Get rule states for a device (with its entry locked), loading persisted state on first use.
+func (re *RuleEvaluator) deviceStates(ctx context.Context, entry *deviceEntry,
+	deviceId uint) (map[uint]*model.ThresholdRuleState, error) {
+	if states, ok := entry.State.(map[uint]*model.ThresholdRuleState); ok {
+		return states, nil
+	}
+	found, err := re.Api.ThresholdRuleStates(ctx, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[uint]*model.ThresholdRuleState)
+	for i := range found {
+		states[found[i].RuleId] = &found[i]
+	}
+	entry.State = states
+	return states, nil
+}
+
+// Get a measurement value in the unit of a rule. Returns false if the rule has a unit the
+// measurement can not be converted to.
+func (re *RuleEvaluator) ruleValue(rule model.ThresholdRule, value float64, unit string) (float64, bool) {
+	if !rule.Unit.Valid {
+		return value, true
+	}
+	if unit == "" {
+		return 0, false
+	}
+	converted, err := re.Api.UnitConverter().Convert(value, unit, rule.Unit.String)
+	if err != nil {
+		return 0, false
+	}
+	return converted, true
+}
+
+// Build the message for an alert generated by a rule.
+func ruleAlertMessage(rule model.ThresholdRule, transition string, value float64) string {
+	threshold := rule.Threshold
+	if transition == model.RULE_ALERT_CLEARED && rule.ClearThreshold.Valid {
+		threshold = rule.ClearThreshold.Float64
+	}
+	message := fmt.Sprintf("Rule '%s' %s: %s %s %v", rule.Token, transition, rule.Measurement,
+		model.RuleOperatorSymbols[rule.Operator], threshold)
+	if rule.Unit.Valid {
+		return fmt.Sprintf("%s %s (value %v %s)", message, rule.Unit.String, value, rule.Unit.String)
+	}
+	return fmt.Sprintf("%s (value %v)", message, value)
+}
+
+// Evaluate a measurement (in the given unit) against rules that apply to it. A rule is raised
+// once its condition has held for the rule duration and cleared once the clear condition has
+// held for the clear duration. Measurements older than the last one evaluated for a rule are
+// ignored, as are measurements that can not be converted to the unit of a rule. Generated
+// alerts are stored together with the updated rule state.
+func (re *RuleEvaluator) Evaluate(ctx context.Context, event model.Event, name string,
+	value float64, unit string) ([]*model.AlertEvent, error) {
+	rules, err := re.refreshRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entry := re.devices.acquire(event.DeviceId)
+	defer re.devices.release(entry)
+
+	var states map[uint]*model.ThresholdRuleState
+	generated := make([]*model.AlertEvent, 0)
+	for _, rule := range rules {
+		if !rule.Applies(event, name) {
+			continue
+		}
+		compared, ok := re.ruleValue(rule, value, unit)
+		if !ok {
+			log.Debug().Str("rule", rule.Token).Str("unit", unit).Msg("Skipping rule for measurement in incompatible unit.")
+			continue
+		}
+		if states == nil {
+			states, err = re.deviceStates(ctx, entry, event.DeviceId)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Changes are made to a copy which replaces the held state once persisted.
+		state := model.ThresholdRuleState{DeviceId: event.DeviceId, RuleId: rule.ID}
+		if previous := states[rule.ID]; previous != nil {
+			state = *previous
+		}
+		if event.OccurredTime.Before(state.OccurredTime) {
+			continue
+		}
+		state.OccurredTime = event.OccurredTime
+
+		// Condition to wait for depends on whether the rule is currently raised.
+		met := rule.Triggered(compared)
+		required := time.Duration(rule.Duration) * time.Second
+		transition := model.RULE_ALERT_RAISED
+		if state.Active {
+			met = rule.Cleared(compared)
+			required = time.Duration(rule.ClearDuration) * time.Second
+			transition = model.RULE_ALERT_CLEARED
+		}
+
+		changed := false
+		var alert *model.AlertEventCreateRequest
+		if !met {
+			changed = state.PendingSince.Valid
+			state.PendingSince = sql.NullTime{}
+		} else {
+			if !state.PendingSince.Valid {
+				state.PendingSince = sql.NullTime{Time: event.OccurredTime, Valid: true}
+				changed = true
+			}
+			if event.OccurredTime.Sub(state.PendingSince.Time) >= required {
+				aevent := event
+				aevent.EventType = esmodel.Alert
+				ruleId := rule.ID
+				trigger := compared
+				alert = &model.AlertEventCreateRequest{
+					Event:          aevent,
+					Type:           rule.AlertType,
+					Level:          rule.AlertLevel,
+					Message:        ruleAlertMessage(rule, transition, compared),
+					Source:         RULE_ALERT_SOURCE,
+					RuleId:         &ruleId,
+					TriggerValue:   &trigger,
+					RuleTransition: &transition,
+				}
+				state.Active = !state.Active
+				state.PendingSince = sql.NullTime{}
+				changed = true
+			}
+		}
+
+		if alert != nil {
+			created, err := re.Api.CreateThresholdRuleAlert(ctx, alert, &state)
+			if err != nil {
+				return generated, err
+			}
+			generated = append(generated, created)
+		} else if changed {
+			err = re.Api.SaveThresholdRuleState(ctx, &state)
+			if err != nil {
+				return generated, err
+			}
+		}
+		states[rule.ID] = &state
+	}
+	return generated, nil
+}
@@ -0,0 +1,119 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	"github.com/devicechain-io/dc-event-management/test"
+	"github.com/devicechain-io/dc-microservice/rdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+// Test raising and clearing a rule with a duration and hysteresis.
+func TestThresholdRuleEvaluation(t *testing.T) {
+	group := uint(7)
+	rule := model.ThresholdRule{
+		Model:          gorm.Model{ID: 1},
+		TokenReference: rdb.TokenReference{Token: "high-temp"},
+		Measurement:    "temp",
+		Operator:       model.RULE_OPERATOR_GREATER_THAN,
+		Threshold:      80,
+		ClearThreshold: sql.NullFloat64{Float64: 75, Valid: true},
+		Duration:       300,
+		DeviceGroupId:  &group,
+		AlertType:      "high-temp",
+		Enabled:        true,
+	}
+	api := &test.MockApi{}
+	api.Mock.On("ActiveThresholdRules", mock.Anything).Return([]model.ThresholdRule{rule}, nil)
+	api.Mock.On("ThresholdRuleStates", mock.Anything).Return([]model.ThresholdRuleState{}, nil)
+	api.Mock.On("SaveThresholdRuleState", mock.Anything).Return(nil)
+	api.Mock.On("CreateThresholdRuleAlert", mock.Anything).Return(&model.AlertEvent{}, nil)
+
+	evaluator := NewRuleEvaluator(api, time.Hour)
+	ctx := context.Background()
+	start := time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC)
+	evaluate := func(offset time.Duration, value float64) int {
+		event := model.Event{DeviceId: 1, OccurredTime: start.Add(offset), RelDeviceGroupId: &group}
+		generated, err := evaluator.Evaluate(ctx, event, "temp", value, "")
+		assert.Nil(t, err)
+		return len(generated)
+	}
+
+	// Condition must hold for the full duration before the alert is raised.
+	assert.Equal(t, 0, evaluate(0, 82))
+	assert.Equal(t, 0, evaluate(2*time.Minute, 79))
+	assert.Equal(t, 0, evaluate(3*time.Minute, 85))
+	assert.Equal(t, 0, evaluate(7*time.Minute, 86))
+	assert.Equal(t, 1, evaluate(8*time.Minute, 84))
+
+	// Values between the clear threshold and threshold do not clear the alert.
+	assert.Equal(t, 0, evaluate(9*time.Minute, 78))
+	assert.Equal(t, 1, evaluate(10*time.Minute, 74))
+	assert.Equal(t, 0, evaluate(11*time.Minute, 70))
+
+	// Late measurements and measurements outside the group are ignored.
+	assert.Equal(t, 0, evaluate(-time.Hour, 90))
+	generated, err := evaluator.Evaluate(ctx, model.Event{DeviceId: 2, OccurredTime: start}, "temp", 90, "")
+	assert.Nil(t, err)
+	assert.Empty(t, generated)
+
+	api.Mock.AssertNumberOfCalls(t, "CreateThresholdRuleAlert", 2)
+}
+
+// Test measurements are converted to the rule unit and skipped if they can not be converted.
+func TestThresholdRuleUnits(t *testing.T) {
+	rule := model.ThresholdRule{
+		Model:          gorm.Model{ID: 1},
+		TokenReference: rdb.TokenReference{Token: "high-temp"},
+		Measurement:    "temp",
+		Operator:       model.RULE_OPERATOR_GREATER_THAN,
+		Threshold:      30,
+		Unit:           sql.NullString{String: "C", Valid: true},
+		AlertType:      "high-temp",
+		Enabled:        true,
+	}
+	api := &test.MockApi{}
+	api.Mock.On("ActiveThresholdRules", mock.Anything).Return([]model.ThresholdRule{rule}, nil)
+	api.Mock.On("ThresholdRuleStates", mock.Anything).Return([]model.ThresholdRuleState{}, nil)
+	api.Mock.On("SaveThresholdRuleState", mock.Anything).Return(nil)
+	api.Mock.On("CreateThresholdRuleAlert", mock.Anything).Return(&model.AlertEvent{}, nil)
+
+	evaluator := NewRuleEvaluator(api, time.Hour)
+	ctx := context.Background()
+	start := time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC)
+	evaluate := func(offset time.Duration, value float64, unit string) int {
+		event := model.Event{DeviceId: 1, OccurredTime: start.Add(offset)}
+		generated, err := evaluator.Evaluate(ctx, event, "temp", value, unit)
+		assert.Nil(t, err)
+		return len(generated)
+	}
+
+	// 80F is below 30C, 95F is above it. Missing or incompatible units are ignored.
+	assert.Equal(t, 0, evaluate(0, 80, "F"))
+	assert.Equal(t, 0, evaluate(time.Minute, 95, ""))
+	assert.Equal(t, 0, evaluate(2*time.Minute, 95, "psi"))
+	assert.Equal(t, 1, evaluate(3*time.Minute, 95, "F"))
+	api.Mock.AssertNumberOfCalls(t, "CreateThresholdRuleAlert", 1)
+}
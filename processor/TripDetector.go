@@ -0,0 +1,229 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/geo"
+	"github.com/devicechain-io/dc-event-management/model"
+)
+
+// Thresholds used to split location streams into trips and stops.
+type TripSettings struct {
+	MovingSpeed  float64       // Speed (meters per second) at or above which a device is moving
+	StopRadius   float64       // Distance (meters) a device may drift while stopped
+	StopDuration time.Duration // Time a device must dwell within the stop radius to end a trip
+}
+
+// Trip detection state and in-progress trip/stop for a device.
+type deviceTrips struct {
+	State *model.TripDetectorState
+	Trip  *model.Trip
+	Stop  *model.Stop
+}
+
+// Create a new trip starting at the given point.
+func newTrip(event model.Event, point geo.Point, start time.Time) *model.Trip {
+	return &model.Trip{
+		DeviceId:           event.DeviceId,
+		RelCustomerId:      event.RelCustomerId,
+		RelCustomerGroupId: event.RelCustomerGroupId,
+		RelAreaId:          event.RelAreaId,
+		RelAssetId:         event.RelAssetId,
+		StartTime:          start,
+		EndTime:            start,
+		StartLatitude:      point.Latitude,
+		StartLongitude:     point.Longitude,
+		EndLatitude:        point.Latitude,
+		EndLongitude:       point.Longitude,
+		Active:             true,
+	}
+}
+
+// Create a new stop at the given point.
+func newStop(event model.Event, point geo.Point, start time.Time) *model.Stop {
+	return &model.Stop{
+		DeviceId:           event.DeviceId,
+		RelCustomerId:      event.RelCustomerId,
+		RelCustomerGroupId: event.RelCustomerGroupId,
+		RelAreaId:          event.RelAreaId,
+		RelAssetId:         event.RelAssetId,
+		StartTime:          start,
+		EndTime:            start,
+		Latitude:           point.Latitude,
+		Longitude:          point.Longitude,
+		Active:             true,
+	}
+}
+
+// Advance trip detection with a new location. A stationary device starts a trip once it leaves
+// the stop radius. A moving device ends its trip once it has stayed within the stop radius at
+// low speed for the stop duration; the trip ends (and the stop begins) where dwelling started.
+// Returns trips and stops that changed. Locations older than the last one are ignored.
+func (dt *deviceTrips) update(event model.Event, point geo.Point, at time.Time, settings TripSettings) []interface{} {
+	changed := make([]interface{}, 0)
+	state := dt.State
+	if state == nil {
+		dt.State = &model.TripDetectorState{
+			DeviceId:        event.DeviceId,
+			LastTime:        at,
+			LastLatitude:    point.Latitude,
+			LastLongitude:   point.Longitude,
+			AnchorTime:      at,
+			AnchorLatitude:  point.Latitude,
+			AnchorLongitude: point.Longitude,
+		}
+		dt.Stop = newStop(event, point, at)
+		return append(changed, dt.Stop)
+	}
+	if !at.After(state.LastTime) {
+		return changed
+	}
+
+	last := geo.Point{Latitude: state.LastLatitude, Longitude: state.LastLongitude}
+	anchor := geo.Point{Latitude: state.AnchorLatitude, Longitude: state.AnchorLongitude}
+	distance := geo.Distance(last, point)
+	speed := distance / at.Sub(state.LastTime).Seconds()
+	setAnchor := func(point geo.Point, at time.Time) {
+		state.AnchorTime, state.AnchorLatitude, state.AnchorLongitude = at, point.Latitude, point.Longitude
+	}
+
+	if !state.Moving {
+		if geo.Distance(anchor, point) > settings.StopRadius {
+			// Device departed, so close the stop and start a trip from the last location.
+			if dt.Stop != nil {
+				dt.Stop.EndTime = state.LastTime
+				dt.Stop.Active = false
+				changed = append(changed, dt.Stop)
+				dt.Stop = nil
+			}
+			dt.Trip = newTrip(event, last, state.LastTime)
+			dt.Trip.Distance = distance
+			dt.Trip.EndTime, dt.Trip.EndLatitude, dt.Trip.EndLongitude = at, point.Latitude, point.Longitude
+			changed = append(changed, dt.Trip)
+			state.Moving = true
+			setAnchor(point, at)
+		} else if dt.Stop != nil {
+			dt.Stop.EndTime = at
+			changed = append(changed, dt.Stop)
+		}
+	} else {
+		if dt.Trip == nil {
+			dt.Trip = newTrip(event, last, state.LastTime)
+		}
+		dt.Trip.Distance += distance
+		dt.Trip.EndTime, dt.Trip.EndLatitude, dt.Trip.EndLongitude = at, point.Latitude, point.Longitude
+
+		if speed >= settings.MovingSpeed || geo.Distance(anchor, point) > settings.StopRadius {
+			setAnchor(point, at)
+			changed = append(changed, dt.Trip)
+		} else if at.Sub(state.AnchorTime) >= settings.StopDuration {
+			// Device dwelled long enough, so end the trip where dwelling started.
+			dt.Trip.EndTime, dt.Trip.EndLatitude, dt.Trip.EndLongitude = state.AnchorTime, anchor.Latitude, anchor.Longitude
+			dt.Trip.Active = false
+			changed = append(changed, dt.Trip)
+			dt.Trip = nil
+
+			dt.Stop = newStop(event, anchor, state.AnchorTime)
+			dt.Stop.EndTime = at
+			changed = append(changed, dt.Stop)
+			state.Moving = false
+		} else {
+			changed = append(changed, dt.Trip)
+		}
+	}
+
+	state.LastTime, state.LastLatitude, state.LastLongitude = at, point.Latitude, point.Longitude
+	return changed
+}
+
+// Splits device location streams into trips and stops, persisting them incrementally. A single
+// detector is shared by all workers so that device state stays consistent. Updates are
+// serialized per device and state for idle devices is released and reloaded on next use.
+type TripDetector struct {
+	Api      model.EventManagementApi
+	Settings TripSettings
+
+	devices *deviceStates
+}
+
+// Create a new trip detector.
+func NewTripDetector(api model.EventManagementApi, settings TripSettings) *TripDetector {
+	return &TripDetector{
+		Api:      api,
+		Settings: settings,
+		devices:  newDeviceStates(DEVICE_STATE_IDLE_TIME),
+	}
+}
+
+// Get trip state for a device (with its entry locked), loading persisted state on first use.
+func (td *TripDetector) deviceTrips(ctx context.Context, entry *deviceEntry, deviceId uint) (*deviceTrips, error) {
+	if trips, ok := entry.State.(*deviceTrips); ok {
+		return trips, nil
+	}
+	state, err := td.Api.TripDetectorState(ctx, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	trip, err := td.Api.ActiveTrip(ctx, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	stop, err := td.Api.ActiveStop(ctx, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	trips := &deviceTrips{State: state, Trip: trip, Stop: stop}
+	entry.State = trips
+	return trips, nil
+}
+
+// Update trips and stops for a device with a new location.
+func (td *TripDetector) Update(ctx context.Context, event model.Event, latitude float64, longitude float64) error {
+	entry := td.devices.acquire(event.DeviceId)
+	defer td.devices.release(entry)
+
+	trips, err := td.deviceTrips(ctx, entry, event.DeviceId)
+	if err != nil {
+		return err
+	}
+	changed := trips.update(event, geo.Point{Latitude: latitude, Longitude: longitude}, event.OccurredTime, td.Settings)
+	if len(changed) == 0 {
+		return nil
+	}
+	for _, current := range changed {
+		switch entity := current.(type) {
+		case *model.Trip:
+			err = td.Api.SaveTrip(ctx, entity)
+		case *model.Stop:
+			err = td.Api.SaveStop(ctx, entity)
+		}
+		if err != nil {
+			// Reload from the datastore on next update since memory may no longer match.
+			entry.State = nil
+			return err
+		}
+	}
+	err = td.Api.SaveTripDetectorState(ctx, trips.State)
+	if err != nil {
+		entry.State = nil
+		return err
+	}
+	return nil
+}
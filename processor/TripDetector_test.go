@@ -0,0 +1,106 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/geo"
+	"github.com/devicechain-io/dc-event-management/model"
+	"github.com/devicechain-io/dc-event-management/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test splitting a location stream into stops and trips.
+func TestTripDetection(t *testing.T) {
+	settings := TripSettings{MovingSpeed: 1.5, StopRadius: 50, StopDuration: 5 * time.Minute}
+	event := model.Event{DeviceId: 1}
+	start := time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC)
+	dt := &deviceTrips{}
+
+	// First location starts a stop.
+	changed := dt.update(event, geo.Point{Latitude: 33.7490, Longitude: -84.3880}, start, settings)
+	assert.Len(t, changed, 1)
+	assert.NotNil(t, dt.Stop)
+
+	// Jitter within the stop radius extends the stop.
+	dt.update(event, geo.Point{Latitude: 33.7491, Longitude: -84.3880}, start.Add(time.Minute), settings)
+	assert.False(t, dt.State.Moving)
+	assert.Equal(t, start.Add(time.Minute), dt.Stop.EndTime)
+
+	// Driving north (~111 meters per 0.001 degrees) starts a trip.
+	stop := dt.Stop
+	at := start.Add(time.Minute)
+	lat := 33.7491
+	for i := 0; i < 10; i++ {
+		at = at.Add(10 * time.Second)
+		lat += 0.001
+		dt.update(event, geo.Point{Latitude: lat, Longitude: -84.3880}, at, settings)
+	}
+	assert.True(t, dt.State.Moving)
+	assert.False(t, stop.Active)
+	assert.NotNil(t, dt.Trip)
+	assert.InDelta(t, 1112, dt.Trip.Distance, 20)
+
+	// Dwelling at the destination ends the trip where dwelling started.
+	trip := dt.Trip
+	arrived := at
+	for i := 0; i < 6; i++ {
+		at = at.Add(time.Minute)
+		dt.update(event, geo.Point{Latitude: lat, Longitude: -84.3880}, at, settings)
+	}
+	assert.False(t, dt.State.Moving)
+	assert.False(t, trip.Active)
+	assert.Equal(t, arrived, trip.EndTime)
+	assert.Equal(t, arrived, dt.Stop.StartTime)
+
+	// Late locations are ignored.
+	assert.Len(t, dt.update(event, geo.Point{Latitude: 0, Longitude: 0}, start, settings), 0)
+}
+
+// Test trip state is released once idle and reloaded on next use.
+func TestTripStateEviction(t *testing.T) {
+	api := &test.MockApi{}
+	api.Mock.On("TripDetectorState").Return((*model.TripDetectorState)(nil), nil)
+	api.Mock.On("ActiveTrip").Return((*model.Trip)(nil), nil)
+	api.Mock.On("ActiveStop").Return((*model.Stop)(nil), nil)
+	api.Mock.On("SaveStop").Return(nil)
+	api.Mock.On("SaveTripDetectorState").Return(nil)
+
+	detector := NewTripDetector(api, TripSettings{MovingSpeed: 1.5, StopRadius: 50, StopDuration: 5 * time.Minute})
+	ctx := context.Background()
+	start := time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC)
+	for device := uint(1); device <= 3; device++ {
+		assert.Nil(t, detector.Update(ctx, model.Event{DeviceId: device, OccurredTime: start}, 33.7490, -84.3880))
+	}
+	assert.Equal(t, 3, detector.devices.size())
+	api.Mock.AssertNumberOfCalls(t, "TripDetectorState", 3)
+
+	// State for idle devices is released.
+	for _, entry := range detector.devices.entries {
+		entry.used = time.Now().Add(-2 * time.Hour)
+	}
+	detector.devices.swept = time.Now().Add(-2 * time.Hour)
+	assert.Nil(t, detector.Update(ctx, model.Event{DeviceId: 4, OccurredTime: start}, 33.7490, -84.3880))
+	assert.Equal(t, 1, detector.devices.size())
+
+	// Released state is reloaded on next use.
+	assert.Nil(t, detector.Update(ctx, model.Event{DeviceId: 1, OccurredTime: start.Add(time.Minute)}, 33.7490, -84.3880))
+	api.Mock.AssertNumberOfCalls(t, "TripDetectorState", 5)
+}
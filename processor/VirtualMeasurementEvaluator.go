@@ -0,0 +1,134 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	"github.com/rs/zerolog/log"
+)
+
+// Virtual measurement with its parsed expression.
+type compiledVirtualMeasurement struct {
+	Definition model.VirtualMeasurement
+	Expression *model.Expression
+}
+
+// Value computed for a virtual measurement.
+type VirtualMeasurementValue struct {
+	Definition model.VirtualMeasurement
+	Value      float64
+}
+
+// Computes virtual measurements from measurements reported together. A single evaluator is
+// shared by all workers.
+type VirtualMeasurementEvaluator struct {
+	Api             model.EventManagementApi
+	RefreshInterval time.Duration
+
+	lock     sync.Mutex
+	virtuals []compiledVirtualMeasurement
+	loaded   time.Time
+}
+
+// Create a new virtual measurement evaluator.
+func NewVirtualMeasurementEvaluator(api model.EventManagementApi, refresh time.Duration) *VirtualMeasurementEvaluator {
+	return &VirtualMeasurementEvaluator{
+		Api:             api,
+		RefreshInterval: refresh,
+	}
+}
+
+// Get virtual measurements, reloading them if the refresh interval has elapsed. Virtual
+// measurements with invalid expressions are skipped.
+func (ve *VirtualMeasurementEvaluator) compiled(ctx context.Context) ([]compiledVirtualMeasurement, error) {
+	ve.lock.Lock()
+	defer ve.lock.Unlock()
+	if ve.virtuals != nil && time.Since(ve.loaded) < ve.RefreshInterval {
+		return ve.virtuals, nil
+	}
+	found, err := ve.Api.ActiveVirtualMeasurements(ctx)
+	if err != nil {
+		return nil, err
+	}
+	virtuals := make([]compiledVirtualMeasurement, 0)
+	for _, virtual := range found {
+		expr, err := model.ParseExpression(virtual.Expression)
+		if err != nil {
+			log.Warn().Err(err).Str("measurement", virtual.Token).Msg("Skipping virtual measurement with invalid expression.")
+			continue
+		}
+		virtuals = append(virtuals, compiledVirtualMeasurement{Definition: virtual, Expression: expr})
+	}
+	ve.virtuals = virtuals
+	ve.loaded = time.Now()
+	return virtuals, nil
+}
+
+// Compute virtual measurements for which all inputs are present in the given values (keyed by
+// measurement name). Virtual measurements may use other computed values as inputs. Measurements
+// that were reported directly are not computed.
+func (ve *VirtualMeasurementEvaluator) Evaluate(ctx context.Context,
+	values map[string]float64) ([]VirtualMeasurementValue, error) {
+	virtuals, err := ve.compiled(ctx)
+	if err != nil {
+		return nil, err
+	}
+	available := make(map[string]float64)
+	for name, value := range values {
+		available[name] = value
+	}
+
+	results := make([]VirtualMeasurementValue, 0)
+	done := make(map[string]bool)
+	for progress := true; progress; {
+		progress = false
+		for _, virtual := range virtuals {
+			token := virtual.Definition.Token
+			if done[token] {
+				continue
+			}
+			if _, ok := available[token]; ok {
+				done[token] = true
+				continue
+			}
+			complete := true
+			for _, variable := range virtual.Expression.Variables {
+				if _, ok := available[variable]; !ok {
+					complete = false
+					break
+				}
+			}
+			if !complete {
+				continue
+			}
+			done[token] = true
+			value, err := virtual.Expression.Evaluate(available)
+			if err != nil {
+				log.Debug().Err(err).Str("measurement", token).Msg("Unable to compute virtual measurement.")
+				continue
+			}
+			available[token] = value
+			results = append(results, VirtualMeasurementValue{Definition: virtual.Definition, Value: value})
+			progress = true
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,81 @@
+/**
+ * Copyright © 2022 DeviceChain
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/devicechain-io/dc-event-management/model"
+	"github.com/devicechain-io/dc-event-management/test"
+	"github.com/devicechain-io/dc-microservice/rdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Create a virtual measurement for testing.
+func virtualMeasurement(token string, expression string) model.VirtualMeasurement {
+	return model.VirtualMeasurement{
+		TokenReference: rdb.TokenReference{Token: token},
+		Expression:     expression,
+		Enabled:        true,
+	}
+}
+
+// Test computing virtual measurements from values reported together.
+func TestVirtualMeasurementEvaluation(t *testing.T) {
+	api := &test.MockApi{}
+	api.Mock.On("ActiveVirtualMeasurements", mock.Anything).Return([]model.VirtualMeasurement{
+		virtualMeasurement("energy", "power * hours"),
+		virtualMeasurement("power", "voltage * current"),
+		virtualMeasurement("dewpoint", "dewpoint(temp, humidity)"),
+		virtualMeasurement("broken", "temp *"),
+		virtualMeasurement("ratio", "voltage / zero"),
+	}, nil)
+
+	evaluator := NewVirtualMeasurementEvaluator(api, time.Hour)
+	ctx := context.Background()
+	computed := func(values map[string]float64) map[string]float64 {
+		results, err := evaluator.Evaluate(ctx, values)
+		assert.Nil(t, err)
+		found := make(map[string]float64)
+		for _, result := range results {
+			found[result.Definition.Token] = result.Value
+		}
+		return found
+	}
+
+	// Computed values may be used as inputs to other virtual measurements.
+	found := computed(map[string]float64{"voltage": 230, "current": 2, "hours": 0.5, "zero": 0})
+	assert.Len(t, found, 2)
+	assert.InDelta(t, 460, found["power"], 1e-9)
+	assert.InDelta(t, 230, found["energy"], 1e-9)
+
+	// Virtual measurements are only computed when all inputs are present.
+	found = computed(map[string]float64{"temp": 25, "voltage": 230})
+	assert.Empty(t, found)
+	found = computed(map[string]float64{"temp": 25, "humidity": 60})
+	assert.InDelta(t, 16.69, found["dewpoint"], 0.01)
+
+	// Reported values are not replaced.
+	found = computed(map[string]float64{"voltage": 230, "current": 2, "power": 455})
+	assert.Empty(t, found)
+
+	// Definitions are cached until the refresh interval elapses.
+	api.Mock.AssertNumberOfCalls(t, "ActiveVirtualMeasurements", 1)
+}
@@ -48,6 +48,132 @@ type MockApi struct {
 }
 
 func (api *MockApi) CreateLocationEvent(ctx context.Context, request *emmodel.LocationEventCreateRequest) (*emmodel.LocationEvent, error) {
+	args := api.Mock.Called(ctx, request)
+	return args.Get(0).(*emmodel.LocationEvent), args.Error(1)
+}
+
+func (api *MockApi) LastLocation(ctx context.Context, deviceId uint, before time.Time) (*emmodel.LocationEvent, error) {
 	args := api.Mock.Called()
 	return args.Get(0).(*emmodel.LocationEvent), args.Error(1)
 }
+
+func (api *MockApi) CreateMeasurementEvent(ctx context.Context, request *emmodel.MeasurementEventCreateRequest) (*emmodel.MeasurementEvent, error) {
+	args := api.Mock.Called(ctx, request)
+	return args.Get(0).(*emmodel.MeasurementEvent), args.Error(1)
+}
+
+func (api *MockApi) CreateAlertEvent(ctx context.Context, request *emmodel.AlertEventCreateRequest) (*emmodel.AlertEvent, error) {
+	args := api.Mock.Called()
+	return args.Get(0).(*emmodel.AlertEvent), args.Error(1)
+}
+
+func (api *MockApi) CreateGeofenceEvent(ctx context.Context, request *emmodel.GeofenceEventCreateRequest) (*emmodel.GeofenceEvent, error) {
+	args := api.Mock.Called()
+	return args.Get(0).(*emmodel.GeofenceEvent), args.Error(1)
+}
+
+func (api *MockApi) ActiveGeofences(ctx context.Context) ([]emmodel.Geofence, error) {
+	args := api.Mock.Called()
+	return args.Get(0).([]emmodel.Geofence), args.Error(1)
+}
+
+func (api *MockApi) GeofenceStates(ctx context.Context, deviceId uint) ([]emmodel.GeofenceState, error) {
+	args := api.Mock.Called()
+	return args.Get(0).([]emmodel.GeofenceState), args.Error(1)
+}
+
+func (api *MockApi) SaveGeofenceState(ctx context.Context, state *emmodel.GeofenceState) error {
+	args := api.Mock.Called()
+	return args.Error(0)
+}
+
+func (api *MockApi) TripDetectorState(ctx context.Context, deviceId uint) (*emmodel.TripDetectorState, error) {
+	args := api.Mock.Called()
+	return args.Get(0).(*emmodel.TripDetectorState), args.Error(1)
+}
+
+func (api *MockApi) SaveTripDetectorState(ctx context.Context, state *emmodel.TripDetectorState) error {
+	args := api.Mock.Called()
+	return args.Error(0)
+}
+
+func (api *MockApi) ActiveTrip(ctx context.Context, deviceId uint) (*emmodel.Trip, error) {
+	args := api.Mock.Called()
+	return args.Get(0).(*emmodel.Trip), args.Error(1)
+}
+
+func (api *MockApi) ActiveStop(ctx context.Context, deviceId uint) (*emmodel.Stop, error) {
+	args := api.Mock.Called()
+	return args.Get(0).(*emmodel.Stop), args.Error(1)
+}
+
+func (api *MockApi) SaveTrip(ctx context.Context, trip *emmodel.Trip) error {
+	args := api.Mock.Called()
+	return args.Error(0)
+}
+
+func (api *MockApi) SaveStop(ctx context.Context, stop *emmodel.Stop) error {
+	args := api.Mock.Called()
+	return args.Error(0)
+}
+
+func (api *MockApi) DwellPosition(ctx context.Context, deviceId uint) (*emmodel.DwellPosition, error) {
+	args := api.Mock.Called()
+	return args.Get(0).(*emmodel.DwellPosition), args.Error(1)
+}
+
+func (api *MockApi) AddAreaDwells(ctx context.Context, position *emmodel.DwellPosition, dwells []emmodel.AreaDwell) error {
+	args := api.Mock.Called(position, dwells)
+	return args.Error(0)
+}
+
+func (api *MockApi) AllMeasurementDefinitions(ctx context.Context) ([]emmodel.MeasurementDefinition, error) {
+	args := api.Mock.Called()
+	return args.Get(0).([]emmodel.MeasurementDefinition), args.Error(1)
+}
+
+func (api *MockApi) ActiveThresholdRules(ctx context.Context) ([]emmodel.ThresholdRule, error) {
+	args := api.Mock.Called()
+	return args.Get(0).([]emmodel.ThresholdRule), args.Error(1)
+}
+
+func (api *MockApi) ThresholdRuleStates(ctx context.Context, deviceId uint) ([]emmodel.ThresholdRuleState, error) {
+	args := api.Mock.Called()
+	return args.Get(0).([]emmodel.ThresholdRuleState), args.Error(1)
+}
+
+func (api *MockApi) SaveThresholdRuleState(ctx context.Context, state *emmodel.ThresholdRuleState) error {
+	args := api.Mock.Called()
+	return args.Error(0)
+}
+
+func (api *MockApi) CreateThresholdRuleAlert(ctx context.Context, request *emmodel.AlertEventCreateRequest,
+	state *emmodel.ThresholdRuleState) (*emmodel.AlertEvent, error) {
+	args := api.Mock.Called()
+	return args.Get(0).(*emmodel.AlertEvent), args.Error(1)
+}
+
+func (api *MockApi) MeasurementStatistics(ctx context.Context, deviceId uint) ([]emmodel.MeasurementStatistics, error) {
+	args := api.Mock.Called()
+	return args.Get(0).([]emmodel.MeasurementStatistics), args.Error(1)
+}
+
+func (api *MockApi) SaveMeasurementStatistics(ctx context.Context, stats []emmodel.MeasurementStatistics) error {
+	args := api.Mock.Called()
+	return args.Error(0)
+}
+
+func (api *MockApi) CreateAnomalyEvent(ctx context.Context, request *emmodel.AnomalyEventCreateRequest) (*emmodel.AnomalyEvent, error) {
+	args := api.Mock.Called()
+	return args.Get(0).(*emmodel.AnomalyEvent), args.Error(1)
+}
+
+func (api *MockApi) ActiveVirtualMeasurements(ctx context.Context) ([]emmodel.VirtualMeasurement, error) {
+	args := api.Mock.Called()
+	return args.Get(0).([]emmodel.VirtualMeasurement), args.Error(1)
+}
+
+// Built-in units are used so that tests do not need to mock conversions.
+func (api *MockApi) UnitConverter() *emmodel.UnitConverter {
+	return emmodel.NewUnitConverter()
+}